@@ -0,0 +1,11 @@
+// Package api bundles the OpenAPI document generated by cmd/openapi, so functions can embed it.
+package api
+
+import (
+	_ "embed"
+)
+
+// OpenAPI is the content of openapi.yaml
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
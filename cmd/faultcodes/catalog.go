@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+// run writes the public definitions to the markdown file md and the JSON file js, skipping an empty path
+func run(definitions []fault.Definition, md, js string) error {
+	definitions = public(definitions)
+	if md != "" {
+		if err := os.WriteFile(md, markdown(definitions), 0o600); err != nil {
+			return err
+		}
+	}
+	if js != "" {
+		content, err := encode(definitions)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(js, content, 0o600)
+	}
+	return nil
+}
+
+// public returns the definitions of the codes which can reach a response, sorted by status then code
+func public(definitions []fault.Definition) []fault.Definition {
+	var filtered []fault.Definition
+	for _, d := range definitions {
+		if !d.Internal {
+			filtered = append(filtered, d)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].Status != filtered[j].Status {
+			return filtered[i].Status < filtered[j].Status
+		}
+		return filtered[i].Code < filtered[j].Code
+	})
+	return filtered
+}
+
+// markdown returns the definitions as a table per status
+func markdown(definitions []fault.Definition) []byte {
+	var b bytes.Buffer
+	b.WriteString("<!-- Code generated by cmd/faultcodes. DO NOT EDIT. -->\n\n")
+	b.WriteString("# Fault codes\n\n")
+	b.WriteString("The KO responses have a `code` telling why the request failed, with its `statusCode`, a `message` and `metadata`.\n")
+	b.WriteString("Retrying a request is worth it only for the retryable codes.\n")
+	status := 0
+	for _, d := range definitions {
+		if d.Status != status {
+			status = d.Status
+			fmt.Fprintf(&b, "\n## %v %v\n\n", status, http.StatusText(status))
+			b.WriteString("| Code | Message | Retryable | Description |\n")
+			b.WriteString("| --- | --- | --- | --- |\n")
+		}
+		retryable := "no"
+		if d.Retryable {
+			retryable = "yes"
+		}
+		fmt.Fprintf(&b, "| `%v` | %v | %v | %v |\n", d.Code, cell(d.Message), retryable, cell(d.Description))
+	}
+	return b.Bytes()
+}
+
+// encode returns the definitions as an indented JSON array
+func encode(definitions []fault.Definition) ([]byte, error) {
+	if definitions == nil {
+		definitions = []fault.Definition{}
+	}
+	content, err := json.MarshalIndent(definitions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// cell escapes the pipes of a markdown table cell
+func cell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
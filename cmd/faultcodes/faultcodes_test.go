@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var definitions = []fault.Definition{
+	{Code: "PET_NOT_FOUND", Status: 404, Message: "Pet not found", Description: "No pet has this id."},
+	{Code: "TOO_MANY_REQUESTS", Status: 429, Message: "Too many requests, retry later", Retryable: true, Description: "Wait | retry"},
+	{Code: "BAD_REQUEST", Status: 400, Message: "Bad request", Description: "Invalid request."},
+	{Code: "NOT_FOUND", Status: 500, Internal: true, Message: "Not found", Description: "Repository only."},
+	{Code: "INVALID_CURSOR", Status: 400, Message: "Invalid cursor", Description: "Invalid cursor."},
+}
+
+func Test_Public(t *testing.T) {
+	var codes []string
+	for _, d := range public(definitions) {
+		codes = append(codes, d.Code)
+	}
+	assert.Equal(t, []string{"BAD_REQUEST", "INVALID_CURSOR", "PET_NOT_FOUND", "TOO_MANY_REQUESTS"}, codes)
+}
+
+func Test_Markdown(t *testing.T) {
+	md := string(markdown(public(definitions)))
+
+	assert.Contains(t, md, "## 400 Bad Request\n")
+	assert.Contains(t, md, "## 429 Too Many Requests\n")
+	assert.Contains(t, md, "| `TOO_MANY_REQUESTS` | Too many requests, retry later | yes | Wait \\| retry |\n")
+	assert.Contains(t, md, "| `PET_NOT_FOUND` | Pet not found | no | No pet has this id. |\n")
+	assert.NotContains(t, md, "NOT_FOUND` | Not found")
+}
+
+func Test_Run(t *testing.T) {
+	dir := t.TempDir()
+	md, js := filepath.Join(dir, "fault-codes.md"), filepath.Join(dir, "fault-codes.json")
+	require.NoError(t, run(fault.Catalog(), md, js))
+
+	content, err := os.ReadFile(js)
+	require.NoError(t, err)
+	var decoded []map[string]any
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	require.NotEmpty(t, decoded)
+	for _, d := range decoded {
+		assert.NotEqual(t, "NOT_FOUND", d["code"])
+		assert.Contains(t, d, "retryable")
+	}
+	assert.FileExists(t, md)
+}
@@ -0,0 +1,27 @@
+//go:build !exclude
+
+// Command faultcodes documents the public fault codes of the catalog of internal/fault for API consumers,
+// as a markdown table and as JSON : the status of the responses having each code, their message, whether
+// retrying may succeed and what the code means.
+//
+//	go run ./cmd/faultcodes -md api/fault-codes.md -json api/fault-codes.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+func main() {
+	md := flag.String("md", "api/fault-codes.md", "markdown output file, none if empty")
+	js := flag.String("json", "api/fault-codes.json", "JSON output file, none if empty")
+	flag.Parse()
+
+	if err := run(fault.Catalog(), *md, *js); err != nil {
+		fmt.Fprintln(os.Stderr, "faultcodes:", err)
+		os.Exit(1)
+	}
+}
@@ -6,21 +6,29 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/google/uuid"
 	"github.com/lambadass-2024/backend/internal/adapters/repositories"
+	"github.com/lambadass-2024/backend/internal/commands/securityheaders"
 	validatorcommand "github.com/lambadass-2024/backend/internal/commands/validator"
+	"github.com/lambadass-2024/backend/internal/commands/versioning"
 	"github.com/lambadass-2024/backend/internal/fault"
 	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
 	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	metricsframework "github.com/lambadass-2024/backend/internal/frameworks/metrics"
+	reportframework "github.com/lambadass-2024/backend/internal/frameworks/report"
 	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
 	"github.com/lambadass-2024/backend/internal/usecases"
 )
 
 var (
-	Logger        = loggerframework.APIGatewayClient{}
-	Lambda        = lambdaframework.APIGatewayClient{}
-	SQL           = sqlframework.GenericClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
-	PetRepository = repositories.PetRepository[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{SQL: &SQL}
-	PetUseCase    = usecases.PetUseCase[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{Repository: &PetRepository}
-	Validator     = validatorcommand.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	Logger          = loggerframework.APIGatewayClient{}
+	Lambda          = lambdaframework.APIGatewayClient{EnableETag: true}
+	Metrics         = metricsframework.APIGatewayClient{}
+	Report          = reportframework.APIGatewayClient{}
+	SecurityHeaders = securityheaders.APIGatewayClient{}
+	Versioning      = versioning.APIGatewayClient{Versions: []versioning.Version{{Name: "1"}}}
+	SQL             = sqlframework.GenericClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	PetRepository   = repositories.PetRepository[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{SQL: &SQL}
+	PetUseCase      = usecases.PetUseCase[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{Repository: &PetRepository}
+	Validator       = validatorcommand.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
 )
 
 type Body struct {
@@ -36,27 +44,22 @@ func HandleRequest(
 
 	if len(request.QueryStringParameters) > 1 {
 		return Lambda.KOFromValidatorFault(
-			fault.NewValidatorFault(&Logger.Client.Logger, "BAD_REQUEST", "Provide only ID", nil, nil))
+			fault.NewValidatorFault("BAD_REQUEST", "Provide only ID", nil, nil))
 	}
 
 	if idstring, exists := request.QueryStringParameters["id"]; exists {
 		id, err = uuid.Parse(idstring)
 		if err != nil {
 			return Lambda.KOFromValidatorFault(
-				fault.NewValidatorFault(&Logger.Client.Logger, "BAD_REQUEST", "Can't parse ID", nil, err))
+				fault.NewValidatorFault("BAD_REQUEST", "Can't parse ID", nil, err))
 		}
 
 		pet, err2 := PetUseCase.Get(id)
 		if err2 == nil {
 			return Lambda.OK(pet)
 		}
-		switch err2.Code() {
-		case "PET_NOT_FOUND":
-			return Lambda.KOFromFault(404, err2)
-		default:
-			return Lambda.KOFromFault(500, err2)
-		}
+		return Lambda.KOFromCatalog(err2)
 	}
 	return Lambda.KOFromValidatorFault(
-		fault.NewValidatorFault(&Logger.Client.Logger, "BAD_REQUEST", "Provide ID", nil, nil))
+		fault.NewValidatorFault("BAD_REQUEST", "Provide ID", nil, nil))
 }
@@ -27,13 +27,20 @@ func Before() *lambdaframework.Lambda[events.APIGatewayProxyRequest, events.APIG
 
 	return Lambda.
 		Use(&Logger).
+		Use(&Metrics).
 		Use(&Lambda).
+		Use(&Report).
+		Use(&SecurityHeaders).
+		Use(&Versioning).
 		Use(&sqlMock).
 		Use(&PetRepository).
 		Use(&PetUseCase).
 		Use(&Validator)
 }
 
+// traceHeaders make the correlationId and the traceId of the KO bodies predictable
+var traceHeaders = map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "X-Request-Id": "test"}
+
 func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
@@ -57,11 +64,13 @@ func TestPetPostOKWithID(t *testing.T) {
 	key := sqlframework.SelectMapKey{Q: repositories.PetSQLGet, DA: petIn}
 	sqlMock.MockSelectMap(key, nil, petOutReal)
 
-	request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": id}}
+	request := events.APIGatewayProxyRequest{Headers: traceHeaders, QueryStringParameters: map[string]string{"id": id}}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
 	assert.Equal(t, "{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"name\":\"bang\",\"race\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf000\",\"name\":\"hbzf\"}}", response.Body)
+	assert.Equal(t, "application/json", response.Headers["Content-Type"])
+	assert.Equal(t, "nosniff", response.Headers["X-Content-Type-Options"])
 
 	assert.NoError(t, f)
 }
@@ -77,11 +86,13 @@ func TestPetPostKONoResult(t *testing.T) {
 	key := sqlframework.SelectMapKey{Q: repositories.PetSQLGet, DA: petIn}
 	sqlMock.MockSelectMap(key, nil, petOutReal)
 
-	request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": id}}
+	request := events.APIGatewayProxyRequest{Headers: traceHeaders, QueryStringParameters: map[string]string{"id": id}}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":404,\"code\":\"PET_NOT_FOUND\",\"message\":\"Pet not found\",\"metadata\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":404,\"code\":\"PET_NOT_FOUND\",\"message\":\"Pet not found\",\"metadata\":{\"correlationId\":\"test\",\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
+	assert.Equal(t, "application/json", response.Headers["Content-Type"])
+	assert.Contains(t, response.Headers, "Strict-Transport-Security")
 
 	assert.NoError(t, f)
 }
@@ -97,11 +108,11 @@ func TestPetPostKONoID(t *testing.T) {
 	key := sqlframework.SelectMapKey{Q: repositories.PetSQLGet, DA: petIn}
 	sqlMock.MockSelectMap(key, nil, petOutReal)
 
-	request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{}}
+	request := events.APIGatewayProxyRequest{Headers: traceHeaders, QueryStringParameters: map[string]string{}}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Provide ID\",\"metadata\":{\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Provide ID\",\"metadata\":{\"correlationId\":\"test\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
 
 	assert.NoError(t, f)
 }
@@ -118,11 +129,11 @@ func TestPetPostKOWithIDAndOtherFields(t *testing.T) {
 	key := sqlframework.SelectMapKey{Q: repositories.PetSQLGet, DA: petIn}
 	sqlMock.MockSelectMap(key, nil, petOutReal)
 
-	request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": id, "string1": "dad"}}
+	request := events.APIGatewayProxyRequest{Headers: traceHeaders, QueryStringParameters: map[string]string{"id": id, "string1": "dad"}}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Provide only ID\",\"metadata\":{\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Provide only ID\",\"metadata\":{\"correlationId\":\"test\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
 
 	assert.NoError(t, f)
 }
@@ -140,11 +151,11 @@ func TestPetPostKOWithInternalProblem(t *testing.T) {
 	key := sqlframework.SelectMapKey{Q: repositories.PetSQLGet, DA: petIn}
 	sqlMock.MockSelectMap(key, nil, petOutReal)
 
-	request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": id}}
+	request := events.APIGatewayProxyRequest{Headers: traceHeaders, QueryStringParameters: map[string]string{"id": id}}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":500,\"code\":\"PET_GET_FAILED\",\"message\":\"Cannot get this pet\",\"metadata\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":500,\"code\":\"PET_GET_FAILED\",\"message\":\"Cannot get this pet\",\"metadata\":{\"correlationId\":\"test\",\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
 
 	assert.NoError(t, f)
 }
@@ -161,11 +172,11 @@ func TestPetPostKOWithIDMalformed(t *testing.T) {
 	key := sqlframework.SelectMapKey{Q: repositories.PetSQLGet, DA: petIn}
 	sqlMock.MockSelectMap(key, nil, petOutReal)
 
-	request := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"id": "752cd6644267493eb8311d4587abf5b3aaaaaaaaaaa"}}
+	request := events.APIGatewayProxyRequest{Headers: traceHeaders, QueryStringParameters: map[string]string{"id": "752cd6644267493eb8311d4587abf5b3aaaaaaaaaaa"}}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Can't parse ID\",\"metadata\":{\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Can't parse ID\",\"metadata\":{\"correlationId\":\"test\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
 
 	assert.NoError(t, f)
 }
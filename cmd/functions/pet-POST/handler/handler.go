@@ -6,21 +6,29 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/google/uuid"
 	"github.com/lambadass-2024/backend/internal/adapters/repositories"
+	"github.com/lambadass-2024/backend/internal/commands/securityheaders"
 	validatorcommand "github.com/lambadass-2024/backend/internal/commands/validator"
+	"github.com/lambadass-2024/backend/internal/commands/versioning"
 	"github.com/lambadass-2024/backend/internal/fault"
 	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
 	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	metricsframework "github.com/lambadass-2024/backend/internal/frameworks/metrics"
+	reportframework "github.com/lambadass-2024/backend/internal/frameworks/report"
 	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
 	"github.com/lambadass-2024/backend/internal/usecases"
 )
 
 var (
-	Logger        = loggerframework.APIGatewayClient{}
-	Lambda        = lambdaframework.APIGatewayClient{}
-	SQL           = sqlframework.GenericClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
-	PetRepository = repositories.PetRepository[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{SQL: &SQL}
-	PetUseCase    = usecases.PetUseCase[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{Repository: &PetRepository}
-	Validator     = validatorcommand.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	Logger          = loggerframework.APIGatewayClient{}
+	Lambda          = lambdaframework.APIGatewayClient{}
+	Metrics         = metricsframework.APIGatewayClient{}
+	Report          = reportframework.APIGatewayClient{}
+	SecurityHeaders = securityheaders.APIGatewayClient{}
+	Versioning      = versioning.APIGatewayClient{Versions: []versioning.Version{{Name: "1"}}}
+	SQL             = sqlframework.GenericClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	PetRepository   = repositories.PetRepository[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{SQL: &SQL}
+	PetUseCase      = usecases.PetUseCase[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{Repository: &PetRepository}
+	Validator       = validatorcommand.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
 )
 
 type Body struct {
@@ -34,7 +42,7 @@ func HandleRequest(
 	request events.APIGatewayProxyRequest, //nolint: gocritic // provided by aws
 ) (events.APIGatewayProxyResponse, fault.Fault) {
 	var data Body
-	err := Validator.ValidateJSONIntoStruct(request.Body, &data)
+	err := Validator.ValidateRequestIntoStruct(&request, &data)
 	if err != nil {
 		return Lambda.KOFromValidatorFault(err)
 	}
@@ -45,10 +53,5 @@ func HandleRequest(
 		return Lambda.OK(pet)
 	}
 
-	switch err2.Code() {
-	case "PET_ID_NOT_UNIQUE":
-		return Lambda.KOFromFault(422, err2)
-	default:
-		return Lambda.KOFromFault(500, err2)
-	}
+	return Lambda.KOFromCatalog(err2)
 }
@@ -3,13 +3,24 @@
 package main
 
 import (
+	_ "embed"
+
 	. "github.com/lambadass-2024/backend/cmd/functions/pet-POST/handler"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
 )
 
+//go:embed config.json
+var config []byte
+
 func main() {
+	Logger.Level = loggerframework.LevelFromConfig(config)
 	Lambda.
 		Use(&Logger).
+		Use(&Metrics).
 		Use(&Lambda).
+		Use(&Report).
+		Use(&SecurityHeaders).
+		Use(&Versioning).
 		Use(&SQL).
 		Use(&PetRepository).
 		Use(&PetUseCase).
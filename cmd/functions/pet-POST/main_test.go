@@ -2,6 +2,7 @@ package main_test
 
 import (
 	"context"
+	"encoding/base64"
 	"os"
 	"testing"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/lambadass-2024/backend/internal/entities"
 	"github.com/lambadass-2024/backend/internal/fault"
 	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	"github.com/lambadass-2024/backend/internal/frameworks/logger/logtest"
 	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
 	"github.com/lambadass-2024/backend/internal/utils"
 	"github.com/rs/zerolog"
@@ -28,10 +30,15 @@ func Before() *lambdaframework.Lambda[events.APIGatewayProxyRequest, events.APIG
 	//zerolog.SetGlobalLevel(zerolog.Disabled)
 	PetRepository.SQL = &sqlMock
 	PetUseCase.Repository = &PetRepository
+	Logger.Output = nil
 
 	return Lambda.
 		Use(&Logger).
+		Use(&Metrics).
 		Use(&Lambda).
+		Use(&Report).
+		Use(&SecurityHeaders).
+		Use(&Versioning).
 		Use(&sqlMock).
 		Use(&PetRepository).
 		Use(&PetUseCase).
@@ -56,6 +63,13 @@ func (u Mockerie[T, U]) OnAfter(_ *U, err fault.Fault) fault.Fault {
 func (u Mockerie[T, U]) OnShutdown() {
 }
 
+// jsonHeaders also make the correlationId and the traceId of the KO bodies predictable
+var jsonHeaders = map[string]string{
+	"Content-Type": "application/json",
+	"traceparent":  "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	"X-Request-Id": "test",
+}
+
 func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
@@ -74,7 +88,7 @@ func TestPetPostOKWithID(t *testing.T) {
 	value := sqlframework.ExecOneRowAffectedMapValue{F: nil}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"a", "raceId": "752cd6644267493eb8311d4587abf000"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"a", "raceId": "752cd6644267493eb8311d4587abf000"}`}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
@@ -92,7 +106,7 @@ func TestPetPostOKWithoutID(t *testing.T) {
 	value := sqlframework.ExecOneRowAffectedMapValue{F: nil}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"name":"a", "raceId": "752cd6644267493eb8311d4587abf000"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"name":"a", "raceId": "752cd6644267493eb8311d4587abf000"}`}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
@@ -103,38 +117,43 @@ func TestPetPostOKWithoutID(t *testing.T) {
 
 func TestPetPostKONotUnique(t *testing.T) {
 	lambda := Before()
-	logger := zerolog.Logger{}
+	sink := logtest.New(t)
+	Logger.Output = sink
 
 	pet := entities.Pet{ID: uuid.MustParse("752cd6644267493eb8311d4587abf5b3"), Name: "a", Race: entities.Race{ID: uuid.MustParse("752cd6644267493eb8311d4587abf5b3")}}
 
 	key := sqlframework.ExecMapKey{Q: repositories.PetSQLCreate, D: pet}
-	value := sqlframework.ExecOneRowAffectedMapValue{F: fault.NewSQL(&logger, "UNIQUE_VIOLATION", "", nil, nil)}
+	value := sqlframework.ExecOneRowAffectedMapValue{F: fault.NewSQL("UNIQUE_VIOLATION", "", nil, nil)}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"a", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"a", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":422,\"code\":\"PET_ID_NOT_UNIQUE\",\"message\":\"Pet id not unique\",\"metadata\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":422,\"code\":\"PET_ID_NOT_UNIQUE\",\"message\":\"Pet id not unique\",\"metadata\":{\"correlationId\":\"test\",\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
+	// The fault chain is logged once, at the level of its status code
+	assert.Len(t, sink.Find(zerolog.WarnLevel, "APIGatewayProxyFault [PET_ID_NOT_UNIQUE] : Pet id not unique", map[string]any{
+		"code": "PET_ID_NOT_UNIQUE", "status": 422, "correlationId": "test",
+	}), 1)
+	assert.Empty(t, sink.Find(zerolog.ErrorLevel, "", nil))
 
 	assert.NoError(t, f)
 }
 
 func TestPetPostKOUnexpectedError(t *testing.T) {
 	lambda := Before()
-	logger := zerolog.Logger{}
 
 	pet := entities.Pet{ID: uuid.MustParse("752cd6644267493eb8311d4587abf5b3"), Name: "a", Race: entities.Race{ID: uuid.MustParse("752cd6644267493eb8311d4587abf5b3")}}
 
 	key := sqlframework.ExecMapKey{Q: repositories.PetSQLCreate, D: pet}
-	value := sqlframework.ExecOneRowAffectedMapValue{F: fault.NewSQL(&logger, "DUMMY_ERROR_UNEXPECTED", "", nil, nil)}
+	value := sqlframework.ExecOneRowAffectedMapValue{F: fault.NewSQL("DUMMY_ERROR_UNEXPECTED", "", nil, nil)}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"a", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"a", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":500,\"code\":\"PET_CREATION_FAILED\",\"message\":\"Pet creation failed\",\"metadata\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\"}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":500,\"code\":\"PET_CREATION_FAILED\",\"message\":\"Pet creation failed\",\"metadata\":{\"correlationId\":\"test\",\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\"}}", response.Body)
 
 	assert.NoError(t, f)
 }
@@ -148,11 +167,11 @@ func TestPetPostKOValidationBadUUID(t *testing.T) {
 	value := sqlframework.ExecOneRowAffectedMapValue{F: nil}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"id": "752cd6644267493eb8311d4587abf5b3_____", "name":"a", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"id": "752cd6644267493eb8311d4587abf5b3_____", "name":"a", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Cannot unmarshall the provided JSON\",\"metadata\":{\"requestId\":\"\",\"requestTime\":\"\",\"unmarshall\":{\"message\":\"invalid UUID length: 37\"}}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Cannot unmarshall the provided JSON\",\"metadata\":{\"correlationId\":\"test\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\",\"unmarshall\":{\"message\":\"invalid UUID length: 37\"}}}", response.Body)
 
 	assert.NoError(t, f)
 }
@@ -166,11 +185,11 @@ func TestPetPostKOValidationNoName(t *testing.T) {
 	value := sqlframework.ExecOneRowAffectedMapValue{F: nil}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "raceId": "752cd6644267493eb8311d4587abf5b3"}`}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Validation failed\",\"metadata\":{\"requestId\":\"\",\"requestTime\":\"\",\"validation\":[{\"message\":\"Key: 'Body.Name' Error:Field validation for 'Name' failed on the 'required' tag\",\"field\":\"Name\",\"namespace\":\"Body.Name\",\"tag\":\"required\",\"value\":\"\"}]}}", response.Body)
+	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Validation failed\",\"metadata\":{\"correlationId\":\"test\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\",\"validation\":[{\"message\":\"Key: 'Body.Name' Error:Field validation for 'Name' failed on the 'required' tag\",\"field\":\"Name\",\"namespace\":\"Body.Name\",\"tag\":\"required\",\"value\":\"\"}]}}", response.Body)
 
 	assert.NoError(t, f)
 }
@@ -184,11 +203,37 @@ func TestPetPostKOValidationNoRace(t *testing.T) {
 	value := sqlframework.ExecOneRowAffectedMapValue{F: nil}
 	sqlMock.MockExecOneRowAffectedMap(key, value)
 
-	request := events.APIGatewayProxyRequest{Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name": "ziiugf"}`}
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, Body: `{"id": "752cd6644267493eb8311d4587abf5b3", "name": "ziiugf"}`}
+
+	response, f := lambda.TestHandleRequest(HandleRequest, &request)
+	assert.NotNil(t, response)
+	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Validation failed\",\"metadata\":{\"correlationId\":\"test\",\"requestId\":\"\",\"requestTime\":\"\",\"traceId\":\"0af7651916cd43dd8448eb211c80319c\",\"validation\":[{\"message\":\"Key: 'Body.RaceID' Error:Field validation for 'RaceID' failed on the 'required' tag\",\"field\":\"RaceID\",\"namespace\":\"Body.RaceID\",\"tag\":\"required\",\"value\":\"00000000-0000-0000-0000-000000000000\"}]}}", response.Body)
+
+	assert.NoError(t, f)
+}
+
+func TestPetPostKOWrongContentType(t *testing.T) {
+	lambda := Before()
+
+	request := events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Type": "text/plain"}, Body: `{"name":"a", "raceId": "752cd6644267493eb8311d4587abf000"}`}
+
+	response, f := lambda.TestHandleRequest(HandleRequest, &request)
+	assert.NotNil(t, response)
+	assert.Equal(t, 415, response.StatusCode)
+	assert.Contains(t, response.Body, "\"code\":\"UNSUPPORTED_MEDIA_TYPE\"")
+
+	assert.NoError(t, f)
+}
+
+func TestPetPostKOBase64Body(t *testing.T) {
+	lambda := Before()
+
+	request := events.APIGatewayProxyRequest{Headers: jsonHeaders, IsBase64Encoded: true, Body: base64.StdEncoding.EncodeToString([]byte(`{"name":"a"}`))}
 
 	response, f := lambda.TestHandleRequest(HandleRequest, &request)
 	assert.NotNil(t, response)
-	assert.Equal(t, "{\"statusCode\":400,\"code\":\"BAD_REQUEST\",\"message\":\"Validation failed\",\"metadata\":{\"requestId\":\"\",\"requestTime\":\"\",\"validation\":[{\"message\":\"Key: 'Body.RaceID' Error:Field validation for 'RaceID' failed on the 'required' tag\",\"field\":\"RaceID\",\"namespace\":\"Body.RaceID\",\"tag\":\"required\",\"value\":\"00000000-0000-0000-0000-000000000000\"}]}}", response.Body)
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Contains(t, response.Body, "\"tag\":\"required\"")
 
 	assert.NoError(t, f)
 }
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/adapters/repositories"
+	"github.com/lambadass-2024/backend/internal/commands/securityheaders"
+	validatorcommand "github.com/lambadass-2024/backend/internal/commands/validator"
+	"github.com/lambadass-2024/backend/internal/commands/versioning"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	metricsframework "github.com/lambadass-2024/backend/internal/frameworks/metrics"
+	reportframework "github.com/lambadass-2024/backend/internal/frameworks/report"
+	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
+	"github.com/lambadass-2024/backend/internal/usecases"
+)
+
+var (
+	Logger          = loggerframework.APIGatewayClient{}
+	Lambda          = lambdaframework.APIGatewayClient{EnableETag: true}
+	Metrics         = metricsframework.APIGatewayClient{}
+	Report          = reportframework.APIGatewayClient{}
+	SecurityHeaders = securityheaders.APIGatewayClient{}
+	Versioning      = versioning.APIGatewayClient{Versions: []versioning.Version{{Name: "1"}}}
+	SQL             = sqlframework.GenericClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	PetRepository   = repositories.PetRepository[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{SQL: &SQL}
+	PetUseCase      = usecases.PetUseCase[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{Repository: &PetRepository}
+	Validator       = validatorcommand.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+)
+
+type Body struct {
+	ID     uuid.UUID `json:"id"     validate:"required,uuid"`
+	RaceID uuid.UUID `json:"raceId" validate:"uuid,required"`
+	Name   string    `json:"name"   validate:"required"`
+}
+
+func HandleRequest(
+	_ context.Context,
+	request events.APIGatewayProxyRequest, //nolint: gocritic // provided by aws
+) (events.APIGatewayProxyResponse, fault.Fault) {
+	var data Body
+	err := Validator.ValidateRequestIntoStruct(&request, &data)
+	if err != nil {
+		return Lambda.KOFromValidatorFault(err)
+	}
+
+	// The pet is locked until the update, so that it cannot change between the check of If-Match and the update
+	current, err2 := PetUseCase.GetForUpdate(data.ID)
+	if err2 != nil {
+		return Lambda.KOFromCatalog(err2)
+	}
+	etag, err2 := Lambda.ETagOf(current)
+	if err2 != nil {
+		return events.APIGatewayProxyResponse{}, err2
+	}
+	if err2 = Lambda.CheckIfMatch(etag); err2 != nil {
+		return events.APIGatewayProxyResponse{}, err2
+	}
+
+	pet, err2 := PetUseCase.Update(data.ID, data.Name, data.RaceID)
+	if err2 == nil {
+		return Lambda.OK(pet)
+	}
+
+	return Lambda.KOFromCatalog(err2)
+}
@@ -0,0 +1,29 @@
+//go:build !exclude
+
+package main
+
+import (
+	_ "embed"
+
+	. "github.com/lambadass-2024/backend/cmd/functions/pet-PUT/handler"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+)
+
+//go:embed config.json
+var config []byte
+
+func main() {
+	Logger.Level = loggerframework.LevelFromConfig(config)
+	Lambda.
+		Use(&Logger).
+		Use(&Metrics).
+		Use(&Lambda).
+		Use(&Report).
+		Use(&SecurityHeaders).
+		Use(&Versioning).
+		Use(&SQL).
+		Use(&PetRepository).
+		Use(&PetUseCase).
+		Use(&Validator).
+		Start(HandleRequest)
+}
@@ -0,0 +1,130 @@
+package main_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	. "github.com/lambadass-2024/backend/cmd/functions/pet-PUT/handler"
+	"github.com/lambadass-2024/backend/internal/adapters/repositories"
+	"github.com/lambadass-2024/backend/internal/entities"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/******************************************************************************
+***** Tests preparation
+******************************************************************************/
+
+var sqlMock = sqlframework.MockClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+
+func Before() *lambdaframework.Lambda[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse] {
+	PetRepository.SQL = &sqlMock
+	PetUseCase.Repository = &PetRepository
+
+	return Lambda.
+		Use(&Logger).
+		Use(&Metrics).
+		Use(&Lambda).
+		Use(&Report).
+		Use(&SecurityHeaders).
+		Use(&Versioning).
+		Use(&sqlMock).
+		Use(&PetRepository).
+		Use(&PetUseCase).
+		Use(&Validator)
+}
+
+const id = "752cd6644267493eb8311d4587abf5b3"
+
+var (
+	current = entities.Pet{ID: uuid.MustParse(id), Name: "a",
+		Race: entities.Race{ID: uuid.MustParse("752cd6644267493eb8311d4587abf000"), Name: "hbzf"}}
+	updated = entities.Pet{ID: uuid.MustParse(id), Name: "b", Race: entities.Race{ID: uuid.MustParse("752cd6644267493eb8311d4587abf000")}}
+	body    = `{"id": "752cd6644267493eb8311d4587abf5b3", "name":"b", "raceId": "752cd6644267493eb8311d4587abf000"}`
+)
+
+// mockPet mocks the current pet and its update, returning the ETag of the current pet
+func mockPet(t *testing.T, update fault.Fault) string {
+	sqlMock.MockSelectMap(sqlframework.SelectMapKey{Q: repositories.PetSQLGetForUpdate, DA: entities.Pet{ID: current.ID}}, nil, []entities.Pet{current})
+	sqlMock.MockExecOneRowAffectedMap(sqlframework.ExecMapKey{Q: repositories.PetSQLUpdate, D: updated}, sqlframework.ExecOneRowAffectedMapValue{F: update})
+	etag, err := Lambda.ETagOf(current)
+	require.NoError(t, err)
+	return etag
+}
+
+// headers make the correlationId and the traceId of the KO bodies predictable
+func headers(ifMatch string) map[string]string {
+	return map[string]string{
+		"Content-Type": "application/json",
+		"If-Match":     ifMatch,
+		"traceparent":  "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"X-Request-Id": "test",
+	}
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+/******************************************************************************
+***** Tests
+******************************************************************************/
+
+func TestPetPutOKIfMatch(t *testing.T) {
+	lambda := Before()
+	etag := mockPet(t, nil)
+
+	request := events.APIGatewayProxyRequest{Headers: headers(etag), Body: body}
+
+	response, f := lambda.TestHandleRequest(HandleRequest, &request)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"name\":\"b\",\"race\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf000\"}}", response.Body)
+	assert.NotEqual(t, etag, response.Headers["ETag"])
+
+	assert.NoError(t, f)
+}
+
+func TestPetPutOKIfMatchAny(t *testing.T) {
+	lambda := Before()
+	mockPet(t, nil)
+
+	request := events.APIGatewayProxyRequest{Headers: headers("*"), Body: body}
+
+	response, f := lambda.TestHandleRequest(HandleRequest, &request)
+	assert.Equal(t, 200, response.StatusCode)
+
+	assert.NoError(t, f)
+}
+
+func TestPetPutKOIfMatchStale(t *testing.T) {
+	lambda := Before()
+	etag := mockPet(t, nil)
+
+	request := events.APIGatewayProxyRequest{Headers: headers(`"stale"`), Body: body}
+
+	response, f := lambda.TestHandleRequest(HandleRequest, &request)
+	assert.Equal(t, 412, response.StatusCode)
+	assert.Contains(t, response.Body, "\"code\":\"PRECONDITION_FAILED\"")
+	assert.Equal(t, etag, response.Headers["ETag"])
+
+	assert.NoError(t, f)
+}
+
+func TestPetPutKONotFound(t *testing.T) {
+	lambda := Before()
+	mockPet(t, fault.NewSQL(sqlframework.ErrRowAffectedNotOne.Code(), "", nil, nil))
+
+	request := events.APIGatewayProxyRequest{Headers: headers("*"), Body: body}
+
+	response, f := lambda.TestHandleRequest(HandleRequest, &request)
+	assert.Equal(t, 404, response.StatusCode)
+	assert.Contains(t, response.Body, "\"code\":\"PET_NOT_FOUND\"")
+
+	assert.NoError(t, f)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lambadass-2024/backend/internal/commands/pagination"
+	"github.com/lambadass-2024/backend/internal/frameworks/lambda"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+type Document struct {
+	OpenAPI    string                           `yaml:"openapi"`
+	Info       Info                             `yaml:"info"`
+	Paths      map[string]map[string]*Operation `yaml:"paths"`
+	Components Components                       `yaml:"components"`
+}
+
+type Info struct {
+	Title   string `yaml:"title"`
+	Version string `yaml:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `yaml:"schemas"`
+}
+
+type Operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary,omitempty"`
+	Description string               `yaml:"description,omitempty"`
+	Tags        []string             `yaml:"tags,omitempty"`
+	Parameters  []Parameter          `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `yaml:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required,omitempty"`
+	Schema   *Schema `yaml:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `yaml:"required"`
+	Content  map[string]MediaType `yaml:"content"`
+}
+
+type Response struct {
+	Description string               `yaml:"description"`
+	Content     map[string]MediaType `yaml:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// generate builds the OpenAPI document of the functions of prog
+func generate(prog *program, title, version string) (*Document, error) {
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]map[string]*Operation{},
+	}
+	sc := newSchemas()
+
+	lambdaPkg, err := prog.Importer.Import(lambdaPackage)
+	if err != nil {
+		return nil, err
+	}
+	koBody, ok := lambdaPkg.Scope().Lookup("HTTPResponseKOBody").Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s.HTTPResponseKOBody not found", lambdaPackage)
+	}
+	koRef := sc.of(koBody)
+
+	for _, fn := range prog.Functions {
+		sc.local = fn.Package
+		if doc.Paths[fn.Path] == nil {
+			doc.Paths[fn.Path] = map[string]*Operation{}
+		}
+		doc.Paths[fn.Path][strings.ToLower(fn.Method)] = newOperation(fn, sc, koRef)
+	}
+	doc.Components.Schemas = sc.components
+	return doc, nil
+}
+
+func newOperation(fn function, sc *schemas, koRef *Schema) *Operation {
+	op := inspect(fn)
+	segments := strings.Split(strings.Trim(fn.Path, "/"), "/")
+	operation := &Operation{
+		OperationID: operationID(fn.Method, segments),
+		Tags:        segments[:1],
+		Responses:   map[string]*Response{},
+	}
+	operation.Summary, operation.Description = summary(op.Doc)
+
+	var body *Schema
+	if obj, ok := fn.Package.Scope().Lookup("Body").(*types.TypeName); ok {
+		body = sc.of(obj.Type())
+	}
+	if body != nil && slices.Contains([]string{http.MethodPost, http.MethodPut, http.MethodPatch}, fn.Method) {
+		operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: body}}}
+		if op.Forms {
+			operation.RequestBody.Content["application/x-www-form-urlencoded"] = MediaType{Schema: body}
+			operation.RequestBody.Content["multipart/form-data"] = MediaType{Schema: body}
+		}
+	}
+
+	for _, q := range op.Query {
+		operation.Parameters = append(operation.Parameters, queryParameterOf(q, body))
+	}
+
+	for _, s := range op.Success {
+		response := &Response{Description: http.StatusText(http.StatusOK), Content: map[string]MediaType{}}
+		if s.Type == nil {
+			response.Content[s.ContentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		} else {
+			for _, encoder := range lambda.DefaultEncoders() {
+				response.Content[encoder.MediaType()] = MediaType{Schema: sc.of(s.Type)}
+			}
+		}
+		operation.Responses[strconv.Itoa(http.StatusOK)] = response
+	}
+
+	for status, codes := range op.Errors {
+		operation.Responses[strconv.Itoa(status)] = errorResponse(status, codes, koRef)
+	}
+	return operation
+}
+
+// queryParameterOf returns the parameter q, documented by the field of body with the same JSON name if any
+func queryParameterOf(q queryParameter, body *Schema) Parameter {
+	parameter := Parameter{Name: q.Name, In: "query", Schema: q.Schema}
+	if parameter.Schema != nil {
+		return parameter
+	}
+	parameter.Schema = &Schema{Type: "string"}
+	if body == nil {
+		return parameter
+	}
+	for _, property := range body.Properties {
+		if property.Name == q.Name {
+			parameter.Schema = property.Schema
+			parameter.Required = slices.Contains(body.Required, q.Name)
+		}
+	}
+	return parameter
+}
+
+// errorResponse documents the HTTPResponseKOBody sent with status, restricting its code to the known ones
+func errorResponse(status int, codes *errorCodes, koRef *Schema) *Response {
+	constraints := &Schema{Properties: Properties{{Name: "statusCode", Schema: &Schema{Const: status}}}}
+	description := http.StatusText(status)
+	if len(codes.Codes) > 0 {
+		description += ". Codes : " + strings.Join(codes.Codes, ", ")
+		if !codes.Open {
+			constraints.Properties = append(constraints.Properties, Property{Name: "code", Schema: &Schema{Enum: codes.Codes}})
+		}
+	}
+	return &Response{
+		Description: description,
+		Content: map[string]MediaType{
+			"application/json": {Schema: &Schema{AllOf: []*Schema{koRef, constraints}}},
+		},
+	}
+}
+
+// operationID returns getPet for GET /pet
+func operationID(method string, segments []string) string {
+	id := strings.ToLower(method)
+	for _, segment := range segments {
+		if segment != "" {
+			id += strings.ToUpper(segment[:1]) + segment[1:]
+		}
+	}
+	return id
+}
+
+func paginationLimitSchema() *Schema {
+	minimum, maximum := float64(1), float64(pagination.MaxLimit)
+	return &Schema{Type: "integer", Minimum: &minimum, Maximum: &maximum, Default: pagination.DefaultLimit}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// sources reads the packages the handlers depend on, to find the fault codes of the methods they call
+type sources struct {
+	fset     *token.FileSet
+	packages map[string]listedPackage
+	files    map[string][]*ast.File // Parsed files, by import path
+}
+
+// method is a method of the named type recv of the package pkg
+type method struct {
+	pkg, recv, name string
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func newSources(fset *token.FileSet, listed []listedPackage) *sources {
+	s := &sources{fset: fset, packages: map[string]listedPackage{}, files: map[string][]*ast.File{}}
+	for _, p := range listed {
+		s.packages[p.ImportPath] = p
+	}
+	return s
+}
+
+// calledMethod returns the method called by call, if it is a method of a named type
+func calledMethod(info *types.Info, call *ast.CallExpr) (method, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return method{}, false
+	}
+	selection, ok := info.Selections[sel]
+	if !ok || selection.Kind() != types.MethodVal {
+		return method{}, false
+	}
+	t := selection.Recv()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return method{}, false
+	}
+	return method{pkg: named.Obj().Pkg().Path(), recv: named.Obj().Name(), name: sel.Sel.Name}, true
+}
+
+// faultCodes returns the public codes of the fault catalog written in the method m, and in the methods of the same
+// type it calls, which are the codes of the faults it can return
+func (s *sources) faultCodes(m method) []string {
+	methods := s.methods(m.pkg, m.recv)
+	var codes []string
+	visited := map[string]bool{}
+	var walk func(name string)
+	walk = func(name string) {
+		decl, ok := methods[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		ast.Inspect(decl.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BasicLit:
+				if code, err := strconv.Unquote(n.Value); n.Kind == token.STRING && err == nil && public(code) && !slices.Contains(codes, code) {
+					codes = append(codes, code)
+				}
+			case *ast.CallExpr:
+				if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+					if x, ok := sel.X.(*ast.Ident); ok && receiverName(decl) == x.Name {
+						walk(sel.Sel.Name)
+					}
+				}
+			}
+			return true
+		})
+	}
+	walk(m.name)
+	slices.Sort(codes)
+	return codes
+}
+
+// methods returns the declarations of the methods of the type recv of the package pkg, by name
+func (s *sources) methods(pkg, recv string) map[string]*ast.FuncDecl {
+	methods := map[string]*ast.FuncDecl{}
+	for _, f := range s.parse(pkg) {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil && receiverType(fd) == recv {
+				methods[fd.Name.Name] = fd
+			}
+		}
+	}
+	return methods
+}
+
+// parse returns the files of the package pkg, nil if it is not one of the listed packages
+func (s *sources) parse(pkg string) []*ast.File {
+	if files, ok := s.files[pkg]; ok {
+		return files
+	}
+	p, ok := s.packages[pkg]
+	var files []*ast.File
+	for _, name := range p.GoFiles {
+		if f, err := parser.ParseFile(s.fset, filepath.Join(p.Dir, name), nil, 0); ok && err == nil {
+			files = append(files, f)
+		}
+	}
+	s.files[pkg] = files
+	return files
+}
+
+// public tells if code is declared in the fault catalog for API consumers
+func public(code string) bool {
+	d, ok := fault.Lookup(code)
+	return ok && !d.Internal
+}
+
+// receiverType returns the name of the type of the receiver of fd, without pointer nor type parameters
+func receiverType(fd *ast.FuncDecl) string {
+	t := fd.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if ident, ok := t.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// receiverName returns the name of the receiver of fd, "" if it has none
+func receiverName(fd *ast.FuncDecl) string {
+	if names := fd.Recv.List[0].Names; len(names) > 0 {
+		return names[0].Name
+	}
+	return ""
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+const (
+	validatorPackage  = "github.com/lambadass-2024/backend/internal/commands/validator"
+	paginationPackage = "github.com/lambadass-2024/backend/internal/commands/pagination"
+	faultPackage      = "github.com/lambadass-2024/backend/internal/fault"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// operation is what HandleRequest tells about the API of a function
+type operation struct {
+	Doc     string
+	Forms   bool // The body is also read from forms, with ValidateRequestIntoStruct
+	Query   []queryParameter
+	Success []success
+	Errors  map[int]*errorCodes
+}
+
+// queryParameter is read by the handler, its schema comes from the Body field of the same name when nil
+type queryParameter struct {
+	Name   string
+	Schema *Schema
+}
+
+// success is a response sent with OK or OKBinary
+type success struct {
+	Type        types.Type // nil for OKBinary
+	ContentType string     // OKBinary only
+}
+
+// errorCodes are the fault codes sent with a status. Open is set when some codes cannot be known statically,
+// like the default branch of a switch on the code of a use case fault.
+type errorCodes struct {
+	Codes []string
+	Open  bool
+}
+
+// producer is a method returning ValidatorFaults, meant for KOFromValidatorFault
+type producer struct {
+	pkg, recv string
+	codes     []string
+	query     []queryParameter
+	forms     bool
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+var decoderCodes = []string{"UNKNOWN_FIELD", "MALFORMED_JSON", "EMPTY_JSON", "WRONG_TYPE", "BAD_REQUEST", "PAYLOAD_TOO_LARGE", "JSON_TOO_DEEP", "JSON_ARRAY_TOO_LONG"}
+
+var requestCodes = append([]string{"UNSUPPORTED_MEDIA_TYPE", "MALFORMED_BASE64", "MALFORMED_FORM", "FILE_TOO_LARGE", "UNSUPPORTED_FILE_TYPE"}, decoderCodes...)
+
+var producers = map[string]producer{
+	"ValidateJSONIntoStruct":    {pkg: validatorPackage, recv: "LambdaValidator", codes: decoderCodes},
+	"ValidateRequestIntoStruct": {pkg: validatorPackage, recv: "LambdaValidator", codes: requestCodes, forms: true},
+	"ValidateStruct":            {pkg: validatorPackage, recv: "LambdaValidator", codes: []string{"BAD_REQUEST"}},
+	"FromRequest": {pkg: paginationPackage, recv: "APIGatewayClient", codes: []string{"BAD_REQUEST", "INVALID_CURSOR"}, query: []queryParameter{
+		{Name: "limit", Schema: paginationLimitSchema()},
+		{Name: "cursor", Schema: &Schema{Type: "string", Description: "Opaque cursor from the links of a previous page"}},
+	}},
+}
+
+// inspect walks HandleRequest, looking for the query parameters it reads and the responses it sends
+// through the APIGatewayClient of the lambda framework
+func inspect(fn function) operation {
+	op := operation{Errors: map[int]*errorCodes{}}
+	decl := handleRequest(fn.Files)
+	if decl == nil {
+		return op
+	}
+	op.Doc = decl.Doc.Text()
+
+	origins := faultOrigins(fn.Info, decl)
+	reached := func(expr ast.Expr) []string {
+		var codes []string
+		if ident, ok := expr.(*ast.Ident); ok && fn.Sources != nil {
+			for _, m := range origins[fn.Info.Uses[ident]] {
+				codes = append(codes, fn.Sources.faultCodes(m)...)
+			}
+		}
+		return codes
+	}
+
+	var validatorFaults []string // Codes of the producers, for faults passed as variables to KOFromValidatorFault
+	pendingValidator := false
+	var stack []ast.Node
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			if sel, ok := n.X.(*ast.SelectorExpr); ok && sel.Sel.Name == "QueryStringParameters" {
+				if name, ok := constString(fn.Info, n.Index); ok {
+					op.addQuery(queryParameter{Name: name})
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if p, ok := producers[sel.Sel.Name]; ok && isMethodOf(fn.Info, sel, p.pkg, p.recv) {
+				validatorFaults = append(validatorFaults, p.codes...)
+				op.Forms = op.Forms || p.forms
+				for _, q := range p.query {
+					op.addQuery(q)
+				}
+			}
+			if isMethodOf(fn.Info, sel, lambdaPackage, "APIGatewayClient") {
+				pendingValidator = op.addResponse(fn.Info, sel.Sel.Name, n, stack, reached) || pendingValidator
+			}
+		}
+		return true
+	})
+
+	if pendingValidator {
+		for _, code := range validatorFaults {
+			op.addError(fault.CodeStatus(code), code)
+		}
+	}
+	for _, code := range middlewareCodes(fn) {
+		op.addError(fault.CodeStatus(code), code)
+	}
+	op.addError(500, "") // Middlewares and unexpected faults
+	return op
+}
+
+// middlewareCodes returns the public codes of the faults the middlewares of fn can return before the handler runs,
+// from the OnSetup and OnBefore methods of the types of the variables of its handler package. Versioning answers
+// a request for an unknown version with UNSUPPORTED_API_VERSION, for instance.
+func middlewareCodes(fn function) []string {
+	if fn.Sources == nil || fn.Package == nil {
+		return nil
+	}
+	var codes []string
+	scope := fn.Package.Scope()
+	for _, name := range scope.Names() {
+		v, ok := scope.Lookup(name).(*types.Var)
+		if !ok {
+			continue
+		}
+		named, ok := v.Type().(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			continue
+		}
+		for _, phase := range []string{"OnSetup", "OnBefore"} {
+			m := method{pkg: named.Obj().Pkg().Path(), recv: named.Obj().Name(), name: phase}
+			for _, code := range fn.Sources.faultCodes(m) {
+				if !slices.Contains(codes, code) {
+					codes = append(codes, code)
+				}
+			}
+		}
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// addResponse records the response sent by a method of APIGatewayClient. reached returns the public codes of the
+// faults an argument can hold. It returns true for a KOFromValidatorFault whose fault comes from a variable.
+func (op *operation) addResponse(info *types.Info, method string, call *ast.CallExpr, stack []ast.Node, reached func(ast.Expr) []string) bool {
+	switch method {
+	case "OK":
+		op.Success = append(op.Success, success{Type: info.TypeOf(call.Args[0])})
+		op.addError(406, "NOT_ACCEPTABLE")
+	case "OKBinary":
+		contentType, ok := constString(info, call.Args[1])
+		if !ok {
+			contentType = "application/octet-stream"
+		}
+		op.Success = append(op.Success, success{ContentType: contentType})
+	case "CheckIfMatch":
+		op.addError(412, "PRECONDITION_FAILED")
+	case "KO":
+		status, ok := constInt(info, call.Args[0])
+		if !ok {
+			return false
+		}
+		code, _ := constString(info, call.Args[1])
+		op.addError(status, code)
+	case "KOFromFault":
+		status, ok := constInt(info, call.Args[0])
+		if !ok {
+			return false
+		}
+		codes := enclosingCase(info, stack)
+		if len(codes) == 0 {
+			op.addError(status, "")
+		}
+		for _, code := range codes {
+			op.addError(status, code)
+		}
+	case "KOFromCatalog":
+		codes := reached(call.Args[0])
+		if len(codes) == 0 {
+			op.addError(500, "")
+		}
+		for _, code := range codes {
+			op.addError(fault.CodeStatus(code), code)
+		}
+	case "KOFromValidatorFault":
+		inner, ok := call.Args[0].(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := inner.Fun.(*ast.SelectorExpr); ok && isFunc(info, sel, faultPackage, "NewValidatorFault") && len(inner.Args) > 0 {
+			if code, ok := constString(info, inner.Args[0]); ok {
+				op.addError(fault.CodeStatus(code), code)
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// addError records that code is sent with status, an empty code meaning it is not known statically
+func (op *operation) addError(status int, code string) {
+	e, ok := op.Errors[status]
+	if !ok {
+		e = &errorCodes{}
+		op.Errors[status] = e
+	}
+	if code == "" {
+		e.Open = true
+		return
+	}
+	if !slices.Contains(e.Codes, code) {
+		e.Codes = append(e.Codes, code)
+		slices.Sort(e.Codes)
+	}
+}
+
+func (op *operation) addQuery(q queryParameter) {
+	for _, existing := range op.Query {
+		if existing.Name == q.Name {
+			return
+		}
+	}
+	op.Query = append(op.Query, q)
+}
+
+func handleRequest(files []*ast.File) *ast.FuncDecl {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == "HandleRequest" {
+				return fd
+			}
+		}
+	}
+	return nil
+}
+
+// faultOrigins returns the methods whose results are assigned to the variables of decl, as in
+// `pet, err := PetUseCase.Get(id)`, the faults of err coming from PetUseCase.Get
+func faultOrigins(info *types.Info, decl *ast.FuncDecl) map[types.Object][]method {
+	origins := map[types.Object][]method{}
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || len(assign.Rhs) != 1 {
+			return true
+		}
+		call, ok := assign.Rhs[0].(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		m, ok := calledMethod(info, call)
+		if !ok {
+			return true
+		}
+		for _, lhs := range assign.Lhs {
+			ident, ok := lhs.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			obj := info.Defs[ident]
+			if obj == nil {
+				obj = info.Uses[ident]
+			}
+			if obj != nil {
+				origins[obj] = append(origins[obj], m)
+			}
+		}
+		return true
+	})
+	return origins
+}
+
+// enclosingCase returns the codes of the innermost case clause around the current node,
+// as in `switch err.Code() { case "PET_NOT_FOUND": return Lambda.KOFromFault(404, err) }`
+func enclosingCase(info *types.Info, stack []ast.Node) []string {
+	for i := len(stack) - 1; i >= 0; i-- {
+		clause, ok := stack[i].(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		var codes []string
+		for _, expr := range clause.List {
+			if code, ok := constString(info, expr); ok {
+				codes = append(codes, code)
+			}
+		}
+		return codes
+	}
+	return nil
+}
+
+// isMethodOf tells if sel is a method of the type pkg.recv (or of a pointer to it)
+func isMethodOf(info *types.Info, sel *ast.SelectorExpr, pkg, recv string) bool {
+	selection, ok := info.Selections[sel]
+	if !ok || selection.Kind() != types.MethodVal {
+		return false
+	}
+	t := selection.Recv()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == pkg && named.Obj().Name() == recv
+}
+
+// isFunc tells if sel is the function pkg.name
+func isFunc(info *types.Info, sel *ast.SelectorExpr, pkg, name string) bool {
+	obj, ok := info.Uses[sel.Sel].(*types.Func)
+	return ok && obj.Pkg() != nil && obj.Pkg().Path() == pkg && obj.Name() == name
+}
+
+func constString(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+func constInt(info *types.Info, expr ast.Expr) (int, bool) {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	i, exact := constant.Int64Val(tv.Value)
+	return int(i), exact
+}
+
+// summary splits a doc comment into its first sentence and the rest
+func summary(doc string) (string, string) {
+	doc = strings.TrimSpace(doc)
+	first, rest, _ := strings.Cut(doc, "\n")
+	return strings.TrimSpace(first), strings.TrimSpace(rest)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	functionsDir  = "cmd/functions"
+	lambdaPackage = "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// function is a lambda of cmd/functions, named <path>-<METHOD> (pet-GET is GET /pet)
+type function struct {
+	Name    string
+	Path    string
+	Method  string
+	Package *types.Package
+	Info    *types.Info
+	Files   []*ast.File
+	Sources *sources // Packages of the methods called by the handler
+}
+
+// program holds the type-checked handler packages of every function
+type program struct {
+	Fset      *token.FileSet
+	Importer  types.Importer
+	Functions []function
+}
+
+// listedPackage is the subset of `go list -json` we use
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	Export     string
+	GoFiles    []string
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// load discovers the functions of the module rooted at root and type-checks their handler package.
+// Dependencies are read from the export data produced by `go list -export`, so the module has to build.
+func load(root string) (*program, error) {
+	entries, err := os.ReadDir(filepath.Join(root, functionsDir))
+	if err != nil {
+		return nil, err
+	}
+
+	listed, err := goList(root, "./"+functionsDir+"/...")
+	if err != nil {
+		return nil, err
+	}
+	exports := map[string]string{}
+	for _, p := range listed {
+		exports[p.ImportPath] = p.Export
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		export, ok := exports[path]
+		if !ok || export == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(export)
+	})
+	prog := &program{Fset: fset, Importer: imp}
+	src := newSources(fset, listed)
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		fn, err := newFunction(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		fn.Sources = src
+		handler, ok := findHandler(listed, entry.Name())
+		if !ok {
+			return nil, fmt.Errorf("%s has no handler package", entry.Name())
+		}
+		if err := prog.check(&fn, handler); err != nil {
+			return nil, err
+		}
+		prog.Functions = append(prog.Functions, fn)
+	}
+	sort.Slice(prog.Functions, func(i, j int) bool { return prog.Functions[i].Name < prog.Functions[j].Name })
+	return prog, nil
+}
+
+// newFunction splits the name of a function directory the same way the Taskfile and terraform do
+func newFunction(name string) (function, error) {
+	i := strings.LastIndex(name, "-")
+	if i <= 0 || i == len(name)-1 {
+		return function{}, fmt.Errorf("%s does not follow the <path>-<METHOD> convention", name)
+	}
+	return function{
+		Name:   name,
+		Path:   "/" + strings.ReplaceAll(name[:i], "-", "/"),
+		Method: strings.ToUpper(name[i+1:]),
+	}, nil
+}
+
+func findHandler(listed []listedPackage, name string) (listedPackage, bool) {
+	suffix := "/" + functionsDir + "/" + name + "/handler"
+	for _, p := range listed {
+		if strings.HasSuffix(p.ImportPath, suffix) {
+			return p, true
+		}
+	}
+	return listedPackage{}, false
+}
+
+// check parses and type-checks the handler package from its sources, keeping the type information of expressions
+func (p *program) check(fn *function, handler listedPackage) error {
+	for _, file := range handler.GoFiles {
+		f, err := parser.ParseFile(p.Fset, filepath.Join(handler.Dir, file), nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		fn.Files = append(fn.Files, f)
+	}
+	fn.Info = &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	conf := types.Config{Importer: p.Importer}
+	pkg, err := conf.Check(handler.ImportPath, p.Fset, fn.Files, fn.Info)
+	if err != nil {
+		return fmt.Errorf("type-checking %s: %w", handler.ImportPath, err)
+	}
+	fn.Package = pkg
+	return nil
+}
+
+// goList runs `go list -export -deps -json` on patterns and decodes its stream of packages
+func goList(root string, patterns ...string) ([]listedPackage, error) {
+	cmd := exec.Command("go", append([]string{"list", "-export", "-deps", "-json"}, patterns...)...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w: %s", err, stderr.String())
+	}
+
+	var listed []listedPackage
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listedPackage
+		err := decoder.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			return listed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		listed = append(listed, p)
+	}
+}
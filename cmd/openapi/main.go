@@ -0,0 +1,54 @@
+//go:build !exclude
+
+// Command openapi generates the OpenAPI 3.1 document of the functions of cmd/functions.
+//
+// Functions are discovered from their directory (pet-GET is GET /pet), request bodies and query parameters
+// from the Body struct and the QueryStringParameters read by HandleRequest, responses from its calls to
+// OK and KO*, and error codes from the switch on fault codes around KOFromFault. The codes of KOFromCatalog are
+// the public codes of the fault catalog written in the methods returning its fault, with their status. The
+// middlewares of the handler package add the public codes written in their OnSetup and OnBefore.
+//
+//	go run ./cmd/openapi -o api/openapi.yaml
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	root := flag.String("root", ".", "root of the module")
+	output := flag.String("o", "api/openapi.yaml", "output file")
+	title := flag.String("title", "lambadass-2024", "title of the API")
+	version := flag.String("version", "1.0.0", "version of the API")
+	flag.Parse()
+
+	if err := run(*root, *output, *title, *version); err != nil {
+		fmt.Fprintln(os.Stderr, "openapi:", err)
+		os.Exit(1)
+	}
+}
+
+func run(root, output, title, version string) error {
+	prog, err := load(root)
+	if err != nil {
+		return err
+	}
+	doc, err := generate(prog, title, version)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Code generated by cmd/openapi. DO NOT EDIT.\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return os.WriteFile(output, buf.Bytes(), 0o600)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ApplyValidate_String(t *testing.T) {
+	schema := &Schema{Type: "string"}
+	required := applyValidate(schema, "required,min=2,max=10,oneof=a b,dive,len=3")
+	assert.True(t, required)
+	assert.Equal(t, 2, *schema.MinLength)
+	assert.Equal(t, 10, *schema.MaxLength)
+	assert.Equal(t, []string{"a", "b"}, schema.Enum)
+}
+
+func Test_ApplyValidate_Number(t *testing.T) {
+	schema := &Schema{Type: "integer"}
+	required := applyValidate(schema, "omitempty,gte=1,lt=100")
+	assert.False(t, required)
+	assert.InDelta(t, 1, *schema.Minimum, 0)
+	assert.InDelta(t, 100, *schema.ExclusiveMaximum, 0)
+}
+
+func Test_ApplyValidate_Array(t *testing.T) {
+	schema := &Schema{Type: "array"}
+	applyValidate(schema, "gt=0,lte=5")
+	assert.Equal(t, 1, *schema.MinItems)
+	assert.Equal(t, 5, *schema.MaxItems)
+}
+
+func Test_NewFunction(t *testing.T) {
+	fn, err := newFunction("pet-race-GET")
+	require.NoError(t, err)
+	assert.Equal(t, "/pet/race", fn.Path)
+	assert.Equal(t, "GET", fn.Method)
+
+	_, err = newFunction("pet")
+	require.Error(t, err)
+}
+
+func Test_Generate_Functions(t *testing.T) {
+	prog, err := load("../..")
+	require.NoError(t, err)
+	doc, err := generate(prog, "test", "0.0.0")
+	require.NoError(t, err)
+
+	get := doc.Paths["/pet"]["get"]
+	require.NotNil(t, get)
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.True(t, get.Parameters[0].Required)
+	assert.Equal(t, "uuid", get.Parameters[0].Schema.Format)
+	assert.Equal(t, "#/components/schemas/Pet", get.Responses["200"].Content["application/json"].Schema.Ref)
+	assert.Contains(t, get.Responses["404"].Description, "PET_NOT_FOUND")
+	assert.Contains(t, get.Responses["400"].Description, "BAD_REQUEST")
+	assert.Contains(t, get.Responses["500"].Description, "PET_GET_FAILED")          // Reached through PetUseCase.Get
+	assert.Contains(t, get.Responses["400"].Description, "UNSUPPORTED_API_VERSION") // From the OnBefore of Versioning
+	assert.Contains(t, get.Responses["410"].Description, "API_VERSION_SUNSET")
+	assert.Contains(t, get.Responses["500"].Description, "VERSIONING_MISCONFIGURED") // From its OnSetup
+
+	post := doc.Paths["/pet"]["post"]
+	require.NotNil(t, post)
+	body := post.RequestBody.Content["application/json"].Schema
+	assert.Equal(t, []string{"raceId", "name"}, body.Required)
+	assert.Contains(t, post.Responses["400"].Description, "MALFORMED_JSON")
+	assert.Contains(t, post.Responses["422"].Description, "PET_ID_NOT_UNIQUE")
+	assert.Contains(t, post.Responses["500"].Description, "IDENTIFIER_GENERATION_ERROR") // Through preparePetCreation
+
+	put := doc.Paths["/pet"]["put"]
+	require.NotNil(t, put)
+	assert.Contains(t, put.Responses["412"].Description, "PRECONDITION_FAILED") // Through CheckIfMatch
+	assert.Contains(t, put.Responses["404"].Description, "PET_NOT_FOUND")
+	assert.Contains(t, put.Responses["500"].Description, "PET_UPDATE_FAILED")
+
+	assert.Contains(t, doc.Components.Schemas, "HTTPResponseKOBody")
+	assert.Contains(t, doc.Components.Schemas, "Race")
+}
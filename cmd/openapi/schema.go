@@ -0,0 +1,317 @@
+package main
+
+import (
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Schema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1)
+type Schema struct {
+	Ref                  string     `yaml:"$ref,omitempty"`
+	Type                 string     `yaml:"type,omitempty"`
+	Format               string     `yaml:"format,omitempty"`
+	Description          string     `yaml:"description,omitempty"`
+	Default              any        `yaml:"default,omitempty"`
+	Const                any        `yaml:"const,omitempty"`
+	Enum                 []string   `yaml:"enum,omitempty"`
+	Pattern              string     `yaml:"pattern,omitempty"`
+	Minimum              *float64   `yaml:"minimum,omitempty"`
+	Maximum              *float64   `yaml:"maximum,omitempty"`
+	ExclusiveMinimum     *float64   `yaml:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64   `yaml:"exclusiveMaximum,omitempty"`
+	MinLength            *int       `yaml:"minLength,omitempty"`
+	MaxLength            *int       `yaml:"maxLength,omitempty"`
+	MinItems             *int       `yaml:"minItems,omitempty"`
+	MaxItems             *int       `yaml:"maxItems,omitempty"`
+	Items                *Schema    `yaml:"items,omitempty"`
+	Properties           Properties `yaml:"properties,omitempty"`
+	AdditionalProperties *Schema    `yaml:"additionalProperties,omitempty"`
+	Required             []string   `yaml:"required,omitempty"`
+	AllOf                []*Schema  `yaml:"allOf,omitempty"`
+}
+
+// Property is a named property of an object schema
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties keeps the declaration order of the struct fields in the document
+type Properties []Property
+
+// schemas converts Go types to schemas. Named structs of other packages become components,
+// the structs of the handler packages (Body) are inlined.
+type schemas struct {
+	components map[string]*Schema
+	names      map[*types.TypeName]string
+	local      *types.Package
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func (p Properties) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, property := range p {
+		var value yaml.Node
+		if err := value.Encode(property.Schema); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: property.Name}, &value)
+	}
+	return node, nil
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[*types.TypeName]string{}}
+}
+
+// of returns the schema of the JSON representation of t
+func (s *schemas) of(t types.Type) *Schema {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return s.of(ptr.Elem())
+	}
+	if named, ok := t.(*types.Named); ok {
+		if schema, ok := wellKnown(named); ok {
+			return schema
+		}
+		if _, isStruct := named.Underlying().(*types.Struct); isStruct && named.TypeArgs().Len() == 0 && named.Obj().Pkg() != s.local {
+			return &Schema{Ref: "#/components/schemas/" + s.component(named)}
+		}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return basic(u)
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok && b.Kind() == types.Byte {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(u.Elem())}
+	case *types.Array:
+		return &Schema{Type: "array", Items: s.of(u.Elem())}
+	case *types.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(u.Elem())}
+	case *types.Struct:
+		return s.object(u)
+	default:
+		return &Schema{} // any, interfaces...
+	}
+}
+
+// component registers named in the components and returns its name, prefixed by its package on conflicts
+func (s *schemas) component(named *types.Named) string {
+	obj := named.Obj()
+	if name, ok := s.names[obj]; ok {
+		return name
+	}
+	name := obj.Name()
+	if _, taken := s.components[name]; taken {
+		name = strings.ToUpper(obj.Pkg().Name()[:1]) + obj.Pkg().Name()[1:] + name
+	}
+	s.names[obj] = name
+	s.components[name] = &Schema{} // Placeholder for recursive types
+	*s.components[name] = *s.of(named.Underlying())
+	return name
+}
+
+// wellKnown maps the types marshaled as strings
+func wellKnown(named *types.Named) (*Schema, bool) {
+	if named.Obj().Pkg() == nil {
+		return nil, false
+	}
+	switch named.Obj().Pkg().Path() + "." + named.Obj().Name() {
+	case "github.com/google/uuid.UUID":
+		return &Schema{Type: "string", Format: "uuid"}, true
+	case "time.Time":
+		return &Schema{Type: "string", Format: "date-time"}, true
+	case "time.Duration":
+		return &Schema{Type: "integer", Format: "int64"}, true
+	}
+	if hasMethod(named, "MarshalText") && !hasMethod(named, "MarshalJSON") {
+		return &Schema{Type: "string"}, true
+	}
+	return nil, false
+}
+
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+func basic(b *types.Basic) *Schema {
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return &Schema{Type: "boolean"}
+	case info&types.IsInteger != 0:
+		if b.Kind() == types.Int64 || b.Kind() == types.Uint64 {
+			return &Schema{Type: "integer", Format: "int64"}
+		}
+		return &Schema{Type: "integer"}
+	case info&types.IsFloat != 0:
+		return &Schema{Type: "number"}
+	case info&types.IsString != 0:
+		return &Schema{Type: "string"}
+	default:
+		return &Schema{}
+	}
+}
+
+// object returns the schema of a struct, as encoding/json sees it
+func (s *schemas) object(st *types.Struct) *Schema {
+	schema := &Schema{Type: "object"}
+	for i := range st.NumFields() {
+		field := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		name, omitempty, skip := jsonName(field, tag.Get("json"))
+		if skip {
+			continue
+		}
+		if field.Embedded() && tag.Get("json") == "" {
+			if embedded := s.of(field.Type()); embedded.Ref == "" && embedded.Type == "object" {
+				schema.Properties = append(schema.Properties, embedded.Properties...)
+				schema.Required = append(schema.Required, embedded.Required...)
+				continue
+			}
+		}
+
+		property := s.of(field.Type())
+		required := !omitempty || neverEmpty(field.Type())
+		if validate, ok := tag.Lookup("validate"); ok {
+			if property.Ref != "" {
+				property = &Schema{AllOf: []*Schema{property}}
+			}
+			required = applyValidate(property, validate)
+		}
+		schema.Properties = append(schema.Properties, Property{Name: name, Schema: property})
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// neverEmpty tells if omitempty has no effect on values of t, as for structs and fixed size arrays
+func neverEmpty(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		return true
+	case *types.Array:
+		return u.Len() > 0
+	default:
+		return false
+	}
+}
+
+// jsonName returns the name of field in JSON, and if it is omitted when empty or never marshaled
+func jsonName(field *types.Var, tag string) (name string, omitempty, skip bool) {
+	if !field.Exported() || tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name()
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" || option == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+// applyValidate maps the go-playground validate tags of a field to schema constraints and tells if the field is required.
+// Tags after dive apply to the elements and alternatives (|) cannot be expressed simply, both are ignored.
+func applyValidate(schema *Schema, validate string) bool {
+	required := false
+	for _, rule := range strings.Split(validate, ",") {
+		if rule == "dive" {
+			break
+		}
+		if strings.Contains(rule, "|") {
+			continue
+		}
+		name, param, _ := strings.Cut(rule, "=")
+		switch {
+		case name == "required":
+			required = true
+		case strings.HasPrefix(name, "uuid"):
+			schema.Format = "uuid"
+		case name == "email":
+			schema.Format = "email"
+		case name == "url" || name == "uri" || name == "http_url":
+			schema.Format = "uri"
+		case name == "datetime":
+			schema.Format = "date-time"
+		case name == "ipv4" || name == "ipv6":
+			schema.Format = name
+		case name == "alpha":
+			schema.Pattern = "^[a-zA-Z]+$"
+		case name == "alphanum":
+			schema.Pattern = "^[a-zA-Z0-9]+$"
+		case name == "numeric":
+			schema.Pattern = "^[-+]?[0-9]+(?:\\.[0-9]+)?$"
+		case name == "oneof":
+			schema.Enum = strings.Fields(param)
+		default:
+			applyBound(schema, name, param)
+		}
+	}
+	return required
+}
+
+// applyBound maps len, min, max, eq, gt, gte, lt and lte, whose meaning depends on the type of the field
+func applyBound(schema *Schema, name, param string) {
+	value, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	n := int(value)
+
+	switch schema.Type {
+	case "string", "array":
+		lower, upper := &schema.MinLength, &schema.MaxLength
+		if schema.Type == "array" {
+			lower, upper = &schema.MinItems, &schema.MaxItems
+		}
+		switch name {
+		case "len", "eq":
+			*lower, *upper = &n, &n
+		case "min", "gte":
+			*lower = &n
+		case "gt":
+			n++
+			*lower = &n
+		case "max", "lte":
+			*upper = &n
+		case "lt":
+			n--
+			*upper = &n
+		}
+	case "integer", "number":
+		switch name {
+		case "len", "eq":
+			schema.Const = value
+		case "min", "gte":
+			schema.Minimum = &value
+		case "gt":
+			schema.ExclusiveMinimum = &value
+		case "max", "lte":
+			schema.Maximum = &value
+		case "lt":
+			schema.ExclusiveMaximum = &value
+		}
+	}
+}
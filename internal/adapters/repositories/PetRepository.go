@@ -2,18 +2,29 @@ package repositories
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/lambadass-2024/backend/internal/entities"
 	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
 	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 const (
 	PetSQLCreate = "INSERT INTO pet(id, name, race_id) VALUES(:id, :name, :race.id)"
 	PetSQLGet    = `SELECT p.id, p.name, r.id as "race.id", r.name as "race.name" FROM pet p inner join race r on p.race_id = r.id WHERE p.id = :id`
+	// PetSQLGetForUpdate locks the pet until the end of the main transaction, so that it cannot change before it is updated
+	PetSQLGetForUpdate = PetSQLGet + " FOR UPDATE OF p"
+	PetSQLUpdate       = "UPDATE pet SET name = :name, race_id = :race.id WHERE id = :id"
+)
+
+// Sentinels of the codes of the faults of PetRepository, for errors.Is
+var (
+	ErrPetNotUnique = fault.NewSentinel("UNIQUE_VIOLATION")
+	ErrPetNotFound  = fault.NewSentinel("NOT_FOUND")
 )
 
 /******************************************************************************
@@ -30,45 +41,75 @@ type PetRepository[T any, U any] struct {
 ******************************************************************************/
 
 func (r PetRepository[T, U]) newError(code, message string, metadata map[string]any, cause error) fault.Fault {
-	return fault.NewRepository(r.logger, "PetRepository", code, message, metadata, cause)
+	return fault.NewRepository("PetRepository", code, message, metadata, cause)
 }
 
 /******************************************************************************
 ***** Functions
 ******************************************************************************/
 
-func (r PetRepository[T, U]) Create(pet entities.Pet) (entities.Pet, fault.Fault) {
+func (r PetRepository[T, U]) Create(pet entities.Pet) (created entities.Pet, err fault.Fault) {
+	span := trace.StartSpan("PetRepository.Create")
+	defer func() { span.EndWith(err) }()
 	metadata := map[string]any{
 		"id": pet.ID,
 	}
-	err := r.SQL.ExecOneRowAffected(PetSQLCreate, pet)
+	err = r.SQL.ExecOneRowAffected(PetSQLCreate, pet)
 	if err != nil {
-		switch err.Code() {
-		case "UNIQUE_VIOLATION":
-			return entities.Pet{}, r.newError("UNIQUE_VIOLATION", "Pet id not unique", metadata, err)
-		default:
-			return entities.Pet{}, r.newError("INSERT_ERROR", "Error while inserting Pet", metadata, err)
+		if errors.Is(err, sqlframework.ErrUniqueViolation) {
+			return entities.Pet{}, r.newError(ErrPetNotUnique.Code(), "Pet id not unique", metadata, err)
 		}
+		return entities.Pet{}, r.newError("INSERT_ERROR", "Error while inserting Pet", metadata, err)
 	}
 	r.logger.Debug().Msgf("Pet %v created", pet.ID)
 	return pet, nil
 }
 
-func (r PetRepository[T, U]) Get(id uuid.UUID) (entities.Pet, fault.Fault) {
+func (r PetRepository[T, U]) Update(pet entities.Pet) (updated entities.Pet, err fault.Fault) {
+	span := trace.StartSpan("PetRepository.Update")
+	defer func() { span.EndWith(err) }()
+	metadata := map[string]any{
+		"id": pet.ID,
+	}
+	err = r.SQL.ExecOneRowAffected(PetSQLUpdate, pet)
+	if err != nil {
+		if errors.Is(err, sqlframework.ErrRowAffectedNotOne) {
+			return entities.Pet{}, r.newError(ErrPetNotFound.Code(), "Pet not found", metadata, err)
+		}
+		return entities.Pet{}, r.newError("UPDATE_ERROR", "Error while updating Pet", metadata, err)
+	}
+	r.logger.Debug().Msgf("Pet %v updated", pet.ID)
+	return pet, nil
+}
+
+func (r PetRepository[T, U]) Get(id uuid.UUID) (pet entities.Pet, err fault.Fault) {
+	span := trace.StartSpan("PetRepository.Get")
+	defer func() { span.EndWith(err) }()
+	return r.get(PetSQLGet, id)
+}
+
+// GetForUpdate is Get, the pet being locked until the end of the request
+func (r PetRepository[T, U]) GetForUpdate(id uuid.UUID) (pet entities.Pet, err fault.Fault) {
+	span := trace.StartSpan("PetRepository.GetForUpdate")
+	defer func() { span.EndWith(err) }()
+	return r.get(PetSQLGetForUpdate, id)
+}
+
+func (r PetRepository[T, U]) get(query string, id uuid.UUID) (entities.Pet, fault.Fault) {
 	metadata := map[string]any{
 		"id": id,
 	}
 	petIn := entities.Pet{ID: id}
 	petsOut := []entities.Pet{}
 
-	err := r.SQL.Select(PetSQLGet, petIn, &petsOut)
+	err := r.SQL.Select(query, petIn, &petsOut)
 	r.logger.Warn().Interface("hop", petsOut).Msg("Debug warn")
 
 	if err != nil {
 		return entities.Pet{}, r.newError("SELECT_ERROR", "Error while selecting Pet", metadata, err)
 	}
 	if len(petsOut) == 0 {
-		return entities.Pet{}, r.newError("NOT_FOUND", "Pet not found", metadata, err)
+		return entities.Pet{}, r.newError(ErrPetNotFound.Code(), "Pet not found", metadata, err)
 	}
 	if len(petsOut) > 1 {
 		return entities.Pet{}, r.newError("TOO_MANY_PETS", "Multiple pets found", metadata, err)
@@ -83,13 +124,13 @@ func (r PetRepository[T, U]) Get(id uuid.UUID) (entities.Pet, fault.Fault) {
 
 // Setup the logger
 func (r *PetRepository[T, U]) OnSetup(_ context.Context, _ *T) fault.Fault {
-	ll := log.Logger.With().Str("repository", "PetRepository").Logger()
-	r.logger = &ll
+	r.logger = loggerframework.Child("repository", "PetRepository")
 	r.logger.Trace().Msg("OnSetup")
 	return nil
 }
 
-func (r PetRepository[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+func (r *PetRepository[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+	r.logger = loggerframework.Child("repository", "PetRepository")
 	r.logger.Trace().Msg("OnBefore")
 	return nil
 }
@@ -0,0 +1,281 @@
+// Package openapi contains a middleware validating requests against an OpenAPI document, before the handler runs.
+//
+// It complements the validation of the Body structs: the method, the path, the parameters (query, header and path)
+// and the JSON body of every request are checked against the document, usually the one of the api package.
+// Faults are ValidatorFaults with the codes and the ValidationError metadata of the Validator command.
+//
+// Example :
+//
+//	OpenAPI = openapicommand.APIGatewayClient{Spec: api.OpenAPI}
+//	...
+//	Lambda.Use(&Logger).Use(&Lambda).Use(&OpenAPI).Use(&SQL)...
+package openapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// APIGatewayClient validates requests against the OpenAPI document Spec.
+// With ValidateResponses, the responses of the handler are validated too (meant for tests, it costs a decoding per response).
+type APIGatewayClient struct {
+	Spec              []byte
+	ValidateResponses bool
+	logger            *zerolog.Logger
+	spec              *spec
+	operation         *operation // Operation of the current request
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// ValidateRequest checks request against the document, and remembers the operation it targets for OnAfter
+func (m *APIGatewayClient) ValidateRequest(request *events.APIGatewayProxyRequest) fault.Fault {
+	m.operation = nil
+	op, err := m.spec.find(request.HTTPMethod, request.Path)
+	if err != nil {
+		v := validation{spec: m.spec}
+		if errors.Is(err, errNoMethod) {
+			v.fail("method", "method", request.HTTPMethod)
+		} else {
+			v.fail("path", "path", request.Path)
+		}
+		return m.newFault("BAD_REQUEST", err.Error(), v.errs, err)
+	}
+	m.operation = op
+
+	v := validation{spec: m.spec}
+	for _, param := range m.spec.parameters(op) {
+		m.checkParameter(&v, request, op, param)
+	}
+	if f := m.checkBody(&v, request, op); f != nil {
+		return f
+	}
+	if len(v.errs) > 0 {
+		return m.newFault("BAD_REQUEST", "Request does not match the OpenAPI document", v.errs, nil)
+	}
+	return nil
+}
+
+func (m *APIGatewayClient) checkParameter(v *validation, request *events.APIGatewayProxyRequest, op *operation, param map[string]any) {
+	name, _ := param["name"].(string)
+	in, _ := param["in"].(string)
+	var value string
+	var found bool
+	switch in {
+	case "query":
+		value, found = request.QueryStringParameters[name]
+	case "header":
+		value = lambdaframework.Header(request, name)
+		found = value != ""
+	case "path":
+		value, found = op.pathParams[name]
+	default: // Cookies are not validated
+		return
+	}
+
+	namespace := in + "." + name
+	if !found {
+		if required, _ := param["required"].(bool); required || in == "path" {
+			v.fail(namespace, "required", nil)
+		}
+		return
+	}
+	schema, _ := param["schema"].(map[string]any)
+	v.check(coerce(value, m.spec.resolve(schema)), schema, namespace)
+}
+
+// checkBody validates the JSON body of the request. Undecodable bodies are reported as the Validator command does.
+func (m *APIGatewayClient) checkBody(v *validation, request *events.APIGatewayProxyRequest, op *operation) fault.Fault {
+	requestBody, ok := op.definition["requestBody"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	requestBody = m.spec.resolve(requestBody)
+	body := request.Body
+	if body == "" {
+		if required, _ := requestBody["required"].(bool); required {
+			return m.newDecoderFault("EMPTY_JSON", "Cannot unmarshall the provided JSON because it's empty", errors.New("EOF"))
+		}
+		return nil
+	}
+
+	contentType := lambdaframework.Header(request, "Content-Type")
+	content, _ := requestBody["content"].(map[string]any)
+	schema, ok := mediaSchema(content, contentType)
+	if !ok {
+		v.fail("header.Content-Type", "oneof", contentType)
+		return nil
+	}
+	if !isJSON(contentType) {
+		return nil
+	}
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return m.newDecoderFault("MALFORMED_JSON", "Cannot unmarshall the provided JSON because its malformed", err)
+		}
+		body = string(decoded)
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return m.newDecoderFault("MALFORMED_JSON", "Cannot unmarshall the provided JSON because its malformed", err)
+	}
+	v.check(value, schema, "body")
+	return nil
+}
+
+// ValidateResponse checks a response of the operation method path against the document.
+// OnAfter calls it when ValidateResponses is set, tests can call it on the responses of TestHandleRequest.
+func (m *APIGatewayClient) ValidateResponse(method, path string, response *events.APIGatewayProxyResponse) fault.Fault {
+	op, err := m.spec.find(method, path)
+	if err != nil {
+		return m.newFault("INVALID_RESPONSE", err.Error(), nil, err)
+	}
+	return m.validateResponse(op, response)
+}
+
+func (m *APIGatewayClient) validateResponse(op *operation, response *events.APIGatewayProxyResponse) fault.Fault {
+	v := validation{spec: m.spec}
+	definition, ok := m.spec.response(op, response.StatusCode)
+	if !ok {
+		v.fail("response.statusCode", "oneof", response.StatusCode)
+		return m.newFault("INVALID_RESPONSE", "Response does not match the OpenAPI document", v.errs, nil)
+	}
+	content, _ := definition["content"].(map[string]any)
+	contentType := headerOf(response.Headers, "Content-Type")
+	if len(content) == 0 || headerOf(response.Headers, "Content-Encoding") != "" || !isJSON(contentType) {
+		return nil // Nothing to validate, or only the encoders know how to read it back
+	}
+	schema, ok := mediaSchema(content, contentType)
+	if !ok {
+		v.fail("response.header.Content-Type", "oneof", contentType)
+		return m.newFault("INVALID_RESPONSE", "Response does not match the OpenAPI document", v.errs, nil)
+	}
+
+	body := response.Body
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return m.newFault("INVALID_RESPONSE", "Cannot decode the response body", nil, err)
+		}
+		body = string(decoded)
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return m.newFault("INVALID_RESPONSE", "Cannot decode the response body", nil, err)
+	}
+	v.check(value, schema, "response.body")
+	if len(v.errs) > 0 {
+		return m.newFault("INVALID_RESPONSE", "Response does not match the OpenAPI document", v.errs, nil)
+	}
+	return nil
+}
+
+func (m *APIGatewayClient) newFault(code, message string, errs []fault.ValidationError, cause error) fault.Fault {
+	var metadata map[string]any
+	if errs != nil {
+		metadata = map[string]any{"validation": errs}
+	}
+	return fault.NewValidatorFault(code, message, metadata, cause)
+}
+
+func (m *APIGatewayClient) newDecoderFault(code, message string, cause error) fault.Fault {
+	return fault.NewValidatorFault(code, message, map[string]any{
+		"unmarshall": map[string]any{"message": cause.Error()},
+	}, cause)
+}
+
+func decodeJSON(body string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return value, nil
+}
+
+// coerce converts a parameter to the JSON value its schema expects, leaving it as a string when it cannot
+// so the type check reports it
+func coerce(value string, schema map[string]any) any {
+	t, _ := schema["type"].(string)
+	switch t {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		var values []any
+		for _, item := range strings.Split(value, ",") {
+			values = append(values, coerce(item, items))
+		}
+		return values
+	}
+	return value
+}
+
+func headerOf(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "OpenAPI")
+	m.logger.Trace().Msg("OnSetup")
+	s, err := parseSpec(m.Spec)
+	if err != nil {
+		return fault.NewValidatorFault("INVALID_OPENAPI_DOCUMENT", "Cannot parse the OpenAPI document", nil, err)
+	}
+	m.spec = s
+	return nil
+}
+
+func (m *APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "OpenAPI")
+	m.logger.Trace().Msg("OnBefore")
+	return m.ValidateRequest(request)
+}
+
+func (m *APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	if err != nil || !m.ValidateResponses || m.operation == nil || response == nil {
+		return err
+	}
+	return m.validateResponse(m.operation, response)
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
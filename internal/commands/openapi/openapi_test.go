@@ -0,0 +1,166 @@
+package openapi_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/api"
+	"github.com/lambadass-2024/backend/internal/commands/openapi"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const spec = `
+openapi: 3.1.0
+info: {title: test, version: 1.0.0}
+paths:
+  /pet/{id}:
+    parameters:
+      - {name: id, in: path, schema: {type: string, format: uuid}}
+    get:
+      parameters:
+        - {name: limit, in: query, schema: {type: integer, minimum: 1, maximum: 100}}
+        - {name: X-Api-Key, in: header, required: true, schema: {type: string, minLength: 4}}
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: string, format: uuid}
+        tags: {type: array, items: {type: string, enum: [cat, dog]}, maxItems: 2}
+      required: [id]
+      additionalProperties: false
+`
+
+const petID = "01906a1e-5f7c-7b3e-9a0e-0a1b2c3d4e5f"
+
+func newMiddleware(t *testing.T, document string) *openapi.APIGatewayClient {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	m := &openapi.APIGatewayClient{Spec: []byte(document)}
+	require.NoError(t, m.OnSetup(context.Background(), nil))
+	return m
+}
+
+func validationTags(t *testing.T, err fault.Fault) map[string]string {
+	t.Helper()
+	require.Error(t, err)
+	errs, ok := err.Metadata()["validation"].([]fault.ValidationError)
+	require.True(t, ok)
+	tags := map[string]string{}
+	for _, e := range errs {
+		tags[e.StructNamespace] = e.Tag
+	}
+	return tags
+}
+
+/******************************************************************************
+***** Requests
+******************************************************************************/
+
+func Test_OpenAPI_ValidRequest(t *testing.T) {
+	m := newMiddleware(t, spec)
+	err := m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/pet/" + petID,
+		QueryStringParameters: map[string]string{"limit": "10"},
+		Headers:               map[string]string{"x-api-key": "secret"},
+	})
+	require.NoError(t, err)
+}
+
+func Test_OpenAPI_InvalidParameters(t *testing.T) {
+	m := newMiddleware(t, spec)
+	err := m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/pet/123",
+		QueryStringParameters: map[string]string{"limit": "1000"},
+	})
+	assert.Equal(t, "BAD_REQUEST", err.Code())
+	assert.Equal(t, map[string]string{
+		"path.id":          "uuid",
+		"query.limit":      "lte",
+		"header.X-Api-Key": "required",
+	}, validationTags(t, err))
+
+	err = m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/pet/" + petID,
+		QueryStringParameters: map[string]string{"limit": "ten"},
+		Headers:               map[string]string{"X-Api-Key": "secret"},
+	})
+	assert.Equal(t, map[string]string{"query.limit": "type"}, validationTags(t, err))
+}
+
+func Test_OpenAPI_UnknownRoute(t *testing.T) {
+	m := newMiddleware(t, spec)
+	err := m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/race"})
+	assert.Equal(t, map[string]string{"path": "path"}, validationTags(t, err))
+
+	err = m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/pet/" + petID})
+	assert.Equal(t, map[string]string{"method": "method"}, validationTags(t, err))
+}
+
+func Test_OpenAPI_Body(t *testing.T) {
+	m := newMiddleware(t, string(api.OpenAPI))
+	post := func(body string) fault.Fault {
+		return m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/pet", Body: body})
+	}
+
+	require.NoError(t, post(`{"raceId":"`+petID+`","name":"bang"}`))
+
+	err := post(`{"raceId":"nope"}`)
+	assert.Equal(t, "BAD_REQUEST", err.Code())
+	assert.Equal(t, map[string]string{"body.raceId": "uuid", "body.name": "required"}, validationTags(t, err))
+
+	err = post(``)
+	require.Error(t, err)
+	assert.Equal(t, "EMPTY_JSON", err.Code())
+
+	err = post(`{"name":`)
+	require.Error(t, err)
+	assert.Equal(t, "MALFORMED_JSON", err.Code())
+}
+
+/******************************************************************************
+***** Responses
+******************************************************************************/
+
+func Test_OpenAPI_ValidateResponses(t *testing.T) {
+	m := newMiddleware(t, spec)
+	m.ValidateResponses = true
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet/" + petID, Headers: map[string]string{"X-Api-Key": "secret"}}
+	require.NoError(t, m.OnBefore(context.Background(), request))
+
+	ok := &events.APIGatewayProxyResponse{StatusCode: 200, Body: `{"id":"` + petID + `","tags":["cat"]}`}
+	require.NoError(t, m.OnAfter(ok, nil))
+
+	wrong := &events.APIGatewayProxyResponse{StatusCode: 200, Body: `{"tags":["cat","fish","dog"],"name":"x"}`}
+	err := m.OnAfter(wrong, nil)
+	assert.Equal(t, "INVALID_RESPONSE", err.Code())
+	assert.Equal(t, map[string]string{
+		"response.body.id":      "required",
+		"response.body.tags":    "max",
+		"response.body.tags[1]": "oneof",
+		"response.body.name":    "additionalProperties",
+	}, validationTags(t, err))
+
+	err = m.ValidateResponse("GET", "/pet/"+petID, &events.APIGatewayProxyResponse{StatusCode: 404})
+	assert.Equal(t, map[string]string{"response.statusCode": "oneof"}, validationTags(t, err))
+}
+
+func Test_OpenAPI_InvalidDocument(t *testing.T) {
+	m := &openapi.APIGatewayClient{Spec: []byte("swagger: '2.0'")}
+	err := m.OnSetup(context.Background(), nil)
+	require.Error(t, err)
+	assert.Equal(t, "INVALID_OPENAPI_DOCUMENT", err.Code())
+}
@@ -0,0 +1,326 @@
+package openapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// validation collects the errors of a value against the schemas of a spec.
+// Errors use the shape and, where one exists, the tag names of go-playground/validator,
+// so clients handle them the same way as the errors of the Body structs.
+type validation struct {
+	spec *spec
+	errs []fault.ValidationError
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// keywordTags maps JSON Schema keywords to the go-playground tag with the same meaning
+var keywordTags = map[string]string{
+	"minLength":        "min",
+	"minItems":         "min",
+	"maxLength":        "max",
+	"maxItems":         "max",
+	"minimum":          "gte",
+	"maximum":          "lte",
+	"exclusiveMinimum": "gt",
+	"exclusiveMaximum": "lt",
+	"enum":             "oneof",
+	"const":            "eq",
+	"date-time":        "datetime",
+}
+
+var patterns sync.Map // Compiled patterns, by expression
+
+func (v *validation) fail(namespace, keyword string, value any) {
+	tag := keyword
+	if t, ok := keywordTags[keyword]; ok {
+		tag = t
+	}
+	field := namespace[strings.LastIndex(namespace, ".")+1:]
+	v.errs = append(v.errs, fault.ValidationError{
+		Message:         fmt.Sprintf("Key: '%s' Error:Field validation for '%s' failed on the '%s' tag", namespace, field, tag),
+		Field:           field,
+		StructNamespace: namespace,
+		Tag:             tag,
+		Value:           value,
+	})
+}
+
+// check validates value, decoded from JSON with UseNumber, against schema
+func (v *validation) check(value any, schema map[string]any, namespace string) {
+	if schema == nil {
+		return
+	}
+	if _, ok := schema["$ref"]; ok {
+		v.check(value, v.spec.resolve(schema), namespace)
+	}
+	for _, sub := range list(schema["allOf"]) {
+		v.check(value, sub, namespace)
+	}
+	if alternatives := list(schema["anyOf"]); alternatives != nil && v.matching(value, alternatives, namespace) == 0 {
+		v.fail(namespace, "anyOf", value)
+	}
+	if alternatives := list(schema["oneOf"]); alternatives != nil && v.matching(value, alternatives, namespace) != 1 {
+		v.fail(namespace, "oneOf", value)
+	}
+
+	if t, ok := schema["type"]; ok && !hasType(value, t) {
+		v.fail(namespace, "type", value)
+		return
+	}
+	if c, ok := schema["const"]; ok && !equal(value, c) {
+		v.fail(namespace, "const", value)
+	}
+	if enum, ok := schema["enum"].([]any); ok && !contains(enum, value) {
+		v.fail(namespace, "enum", value)
+	}
+
+	switch val := value.(type) {
+	case string:
+		v.checkString(val, schema, namespace)
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			v.checkNumber(f, schema, namespace)
+		}
+	case []any:
+		v.checkArray(val, schema, namespace)
+	case map[string]any:
+		v.checkObject(val, schema, namespace)
+	}
+}
+
+// matching counts the alternatives value is valid against
+func (v *validation) matching(value any, alternatives []map[string]any, namespace string) int {
+	count := 0
+	for _, alternative := range alternatives {
+		sub := validation{spec: v.spec}
+		sub.check(value, alternative, namespace)
+		if len(sub.errs) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (v *validation) checkString(value string, schema map[string]any, namespace string) {
+	length := utf8.RuneCountInString(value)
+	if minimum, ok := number(schema["minLength"]); ok && float64(length) < minimum {
+		v.fail(namespace, "minLength", value)
+	}
+	if maximum, ok := number(schema["maxLength"]); ok && float64(length) > maximum {
+		v.fail(namespace, "maxLength", value)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		cached, ok := patterns.Load(pattern)
+		if !ok {
+			re, _ := regexp.Compile(pattern) // An invalid pattern validates nothing
+			cached, _ = patterns.LoadOrStore(pattern, re)
+		}
+		if re := cached.(*regexp.Regexp); re != nil && !re.MatchString(value) {
+			v.fail(namespace, "pattern", value)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !validFormat(format, value) {
+		v.fail(namespace, format, value)
+	}
+}
+
+func (v *validation) checkNumber(value float64, schema map[string]any, namespace string) {
+	if bound, ok := number(schema["minimum"]); ok && value < bound {
+		v.fail(namespace, "minimum", value)
+	}
+	if bound, ok := number(schema["maximum"]); ok && value > bound {
+		v.fail(namespace, "maximum", value)
+	}
+	if bound, ok := number(schema["exclusiveMinimum"]); ok && value <= bound {
+		v.fail(namespace, "exclusiveMinimum", value)
+	}
+	if bound, ok := number(schema["exclusiveMaximum"]); ok && value >= bound {
+		v.fail(namespace, "exclusiveMaximum", value)
+	}
+}
+
+func (v *validation) checkArray(value []any, schema map[string]any, namespace string) {
+	if minimum, ok := number(schema["minItems"]); ok && float64(len(value)) < minimum {
+		v.fail(namespace, "minItems", len(value))
+	}
+	if maximum, ok := number(schema["maxItems"]); ok && float64(len(value)) > maximum {
+		v.fail(namespace, "maxItems", len(value))
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range value {
+			v.check(item, items, namespace+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+func (v *validation) checkObject(value map[string]any, schema map[string]any, namespace string) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := value[name]; !ok {
+			v.fail(namespace+"."+name, "required", nil)
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, property := range value {
+		if propertySchema, ok := properties[name].(map[string]any); ok {
+			v.check(property, propertySchema, namespace+"."+name)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(namespace+"."+name, "additionalProperties", property)
+			}
+		case map[string]any:
+			v.check(property, additional, namespace+"."+name)
+		}
+	}
+}
+
+// hasType tells if value has one of the JSON Schema types t (a string or a list of strings)
+func hasType(value any, t any) bool {
+	types := stringList(t)
+	if name, ok := t.(string); ok {
+		types = []string{name}
+	}
+	for _, name := range types {
+		switch val := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case json.Number:
+			if name == "number" {
+				return true
+			}
+			if f, err := val.Float64(); err == nil && name == "integer" && f == math.Trunc(f) {
+				return true
+			}
+		case []any:
+			if name == "array" {
+				return true
+			}
+		case map[string]any:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validFormat(format, value string) bool {
+	var err error
+	switch format {
+	case "uuid":
+		_, err = uuid.Parse(value)
+	case "email":
+		_, err = mail.ParseAddress(value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	case "date":
+		_, err = time.Parse(time.DateOnly, value)
+	case "uri":
+		var u *url.URL
+		u, err = url.Parse(value)
+		if err == nil && !u.IsAbs() {
+			return false
+		}
+	case "ipv4", "ipv6":
+		var addr netip.Addr
+		addr, err = netip.ParseAddr(value)
+		if err == nil && (format == "ipv4") != addr.Is4() {
+			return false
+		}
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(value)
+	}
+	return err == nil // Unknown formats are annotations only
+}
+
+// number reads a numeric keyword, decoded from YAML as int or float64
+func number(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal compares a value decoded from JSON with one decoded from YAML
+func equal(a, b any) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(values []any, value any) bool {
+	for _, candidate := range values {
+		if equal(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func list(value any) []map[string]any {
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	schemas := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if schema, ok := item.(map[string]any); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+func stringList(value any) []string {
+	items, _ := value.([]any)
+	var strs []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
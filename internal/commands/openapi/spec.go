@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// spec is an OpenAPI document decoded as generic YAML values, with its paths ready for matching
+type spec struct {
+	root   map[string]any
+	routes []route
+}
+
+// route is a path of the document, like /pet/{id}
+type route struct {
+	template string
+	segments []string
+	item     map[string]any
+}
+
+// operation is the operation matching a request, with the values of its path parameters
+type operation struct {
+	template   string
+	method     string
+	item       map[string]any
+	definition map[string]any
+	pathParams map[string]string
+}
+
+var (
+	errNoPath   = errors.New("no path of the OpenAPI document matches the request")
+	errNoMethod = errors.New("the path of the request has no operation for its method")
+)
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func parseSpec(document []byte) (*spec, error) {
+	var root map[string]any
+	if err := yaml.Unmarshal(document, &root); err != nil {
+		return nil, err
+	}
+	if version, _ := root["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", version)
+	}
+	paths, _ := root["paths"].(map[string]any)
+	s := &spec{root: root}
+	for template, item := range paths {
+		itemMap, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("path %s is not an object", template)
+		}
+		s.routes = append(s.routes, route{template: template, segments: splitPath(template), item: itemMap})
+	}
+	// Static segments win over parameters, as in /pet/mine and /pet/{id}
+	sort.Slice(s.routes, func(i, j int) bool {
+		return strings.Count(s.routes[i].template, "{") < strings.Count(s.routes[j].template, "{")
+	})
+	return s, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// find returns the operation of method on path
+func (s *spec) find(method, path string) (*operation, error) {
+	segments := splitPath(path)
+	pathFound := false
+	for _, r := range s.routes {
+		params, ok := r.match(segments)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		definition, ok := r.item[strings.ToLower(method)].(map[string]any)
+		if !ok {
+			continue
+		}
+		return &operation{template: r.template, method: method, item: r.item, definition: definition, pathParams: params}, nil
+	}
+	if pathFound {
+		return nil, errNoMethod
+	}
+	return nil, errNoPath
+}
+
+func (r route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			value, err := url.PathUnescape(segments[i])
+			if err != nil {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = value
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// resolve follows $ref to a local component, as #/components/schemas/Pet
+func (s *spec) resolve(node map[string]any) map[string]any {
+	for range 32 { // Bounds chains of references
+		ref, ok := node["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+		var current any = s.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			m, ok := current.(map[string]any)
+			if !ok {
+				return map[string]any{}
+			}
+			current = m[token]
+		}
+		next, ok := current.(map[string]any)
+		if !ok {
+			return map[string]any{}
+		}
+		node = next
+	}
+	return node
+}
+
+// parameters returns the parameters of the path item and of the operation, the latter overriding the former
+func (s *spec) parameters(op *operation) []map[string]any {
+	var params []map[string]any
+	index := map[string]int{}
+	for _, list := range []any{op.item["parameters"], op.definition["parameters"]} {
+		items, _ := list.([]any)
+		for _, item := range items {
+			param, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			param = s.resolve(param)
+			key := fmt.Sprint(param["in"], ".", param["name"])
+			if i, ok := index[key]; ok {
+				params[i] = param
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// response returns the response of op for status, falling back to its range (4XX) and default
+func (s *spec) response(op *operation, status int) (map[string]any, bool) {
+	responses, _ := op.definition["responses"].(map[string]any)
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		if response, ok := responses[key].(map[string]any); ok {
+			return s.resolve(response), true
+		}
+	}
+	return nil, false
+}
+
+// mediaSchema returns the schema of the content of a request body or response for contentType.
+// An empty contentType picks application/json.
+func mediaSchema(content map[string]any, contentType string) (map[string]any, bool) {
+	mediaType := "application/json"
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, false
+		}
+		mediaType = parsed
+	}
+	candidates := []string{mediaType, mediaType[:strings.Index(mediaType+"/", "/")] + "/*", "*/*"}
+	for _, candidate := range candidates {
+		if media, ok := content[candidate].(map[string]any); ok {
+			schema, _ := media["schema"].(map[string]any)
+			return schema, true
+		}
+	}
+	return nil, false
+}
+
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,70 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Length of the truncated HMAC appended to cursors
+const signatureLength = 16
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+type Direction byte
+
+const (
+	// Next pages have ids greater than the cursor
+	Next Direction = iota
+	// Prev pages have ids lower than the cursor
+	Prev
+)
+
+// Cursor is the position of a page in a list ordered by id.
+// As ids are UUIDv7, it is also the chronological order.
+type Cursor struct {
+	ID        uuid.UUID
+	Direction Direction
+}
+
+var errInvalidCursor = errors.New("cursor is malformed or its signature does not match")
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// encode returns the opaque representation of c, signed with secret so clients cannot forge it
+func (c Cursor) encode(secret []byte) string {
+	payload := append([]byte{byte(c.Direction)}, c.ID[:]...)
+	return base64.RawURLEncoding.EncodeToString(append(payload, sign(secret, payload)...))
+}
+
+// decodeCursor reads a cursor created by encode with the same secret
+func decodeCursor(secret []byte, s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(raw) != 1+len(uuid.UUID{})+signatureLength {
+		return Cursor{}, errInvalidCursor
+	}
+	payload, signature := raw[:len(raw)-signatureLength], raw[len(raw)-signatureLength:]
+	if !hmac.Equal(signature, sign(secret, payload)) {
+		return Cursor{}, errInvalidCursor
+	}
+
+	c := Cursor{Direction: Direction(payload[0])}
+	if c.Direction != Next && c.Direction != Prev {
+		return Cursor{}, errInvalidCursor
+	}
+	copy(c.ID[:], payload[1:])
+	return c, nil
+}
+
+func sign(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:signatureLength]
+}
@@ -0,0 +1,181 @@
+// Package pagination contains helpers for list endpoints paginated with opaque cursors.
+//
+// Lists are ordered by id (UUIDv7, so chronologically) and pages are selected with a keyset
+// condition on the id instead of an OFFSET, so they stay consistent when rows are inserted.
+//
+// Example :
+//
+//	page, err := Pagination.FromRequest(&request, &Validator)
+//	if err != nil {
+//		return Lambda.KOFromValidatorFault(err)
+//	}
+//	where, orderLimit, params := page.Keyset("p.id")
+//	err = SQL.Select("SELECT p.id, p.name FROM pet p WHERE "+where+orderLimit, params, &pets)
+//	...
+//	return Lambda.OK(pagination.NewResponse(&Pagination, page, pets, func(p entities.Pet) uuid.UUID { return p.ID }))
+package pagination
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// APIGatewayClient reads the pagination query parameters (limit and cursor) and signs the cursors of the responses.
+// The signing secret comes from the PAGINATION_SECRET environment variable.
+type APIGatewayClient struct {
+	logger *zerolog.Logger
+	secret []byte
+}
+
+// Validator is implemented by validatorcommand.LambdaValidator
+type Validator interface {
+	ValidateStruct(data any) fault.Fault
+}
+
+// Page is the page requested by the client
+type Page struct {
+	Limit  int
+	Cursor *Cursor // nil for the first page
+	path   string
+	query  url.Values
+}
+
+// pageQuery validates the query parameters, FromRequest checking the limit against MaxLimit
+type pageQuery struct {
+	Limit int `validate:"gte=1"`
+}
+
+// Links to the surrounding pages, empty if there is none
+type Links struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// Response is the envelope of every paginated response
+type Response[E any] struct {
+	Data  []E   `json:"data"`
+	Links Links `json:"links"`
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// FromRequest reads the limit and cursor query parameters of request.
+// Faults are ValidatorFaults, meant for KOFromValidatorFault.
+func (m *APIGatewayClient) FromRequest(request *events.APIGatewayProxyRequest, validator Validator) (Page, fault.Fault) {
+	page := Page{Limit: DefaultLimit, path: request.Path, query: url.Values{}}
+	for key, value := range request.QueryStringParameters {
+		page.query.Set(key, value)
+	}
+
+	if limit := page.query.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return Page{}, fault.NewValidatorFault("BAD_REQUEST", "Cannot parse limit", nil, err)
+		}
+		if err := validator.ValidateStruct(pageQuery{Limit: l}); err != nil {
+			return Page{}, err
+		}
+		if l > MaxLimit {
+			return Page{}, fault.NewValidatorFault("BAD_REQUEST", "Limit is too high", map[string]any{"maxLimit": MaxLimit}, nil)
+		}
+		page.Limit = l
+	}
+
+	if cursor := page.query.Get("cursor"); cursor != "" {
+		c, err := decodeCursor(m.secret, cursor)
+		if err != nil {
+			return Page{}, fault.NewValidatorFault("INVALID_CURSOR", "Invalid cursor", nil, err)
+		}
+		page.Cursor = &c
+	}
+	return page, nil
+}
+
+// link returns the URL of the page starting at cursor, keeping the other query parameters of the request
+func (m *APIGatewayClient) link(page Page, cursor Cursor) string {
+	query := url.Values{}
+	for key, values := range page.query {
+		query[key] = values
+	}
+	query.Set("limit", strconv.Itoa(page.Limit))
+	query.Set("cursor", cursor.encode(m.secret))
+	return page.path + "?" + query.Encode()
+}
+
+// NewResponse builds the envelope of page from rows, selected with the clause of Page.Keyset.
+// id returns the id of a row, used to build the cursors.
+func NewResponse[E any](m *APIGatewayClient, page Page, rows []E, id func(E) uuid.UUID) Response[E] {
+	hasMore := len(rows) > page.Limit
+	if hasMore {
+		rows = rows[:page.Limit]
+	}
+	backward := page.Cursor != nil && page.Cursor.Direction == Prev
+	if backward {
+		slices.Reverse(rows) // Selected in descending order
+	}
+
+	res := Response[E]{Data: rows}
+	if len(rows) == 0 {
+		return res
+	}
+	first, last := id(rows[0]), id(rows[len(rows)-1])
+
+	// Going forward, there is a previous page as soon as we started from a cursor, and a next one if we got more rows.
+	// Going backward, it is the opposite.
+	if backward || hasMore {
+		res.Links.Next = m.link(page, Cursor{ID: last, Direction: Next})
+	}
+	if (backward && hasMore) || (!backward && page.Cursor != nil) {
+		res.Links.Prev = m.link(page, Cursor{ID: first, Direction: Prev})
+	}
+	return res
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "Pagination")
+	m.logger.Trace().Msg("OnSetup")
+	m.secret = []byte(os.Getenv("PAGINATION_SECRET"))
+	if len(m.secret) == 0 {
+		return fault.NewAPIGateway(500, "NO_PAGINATION_SECRET", "PAGINATION_SECRET is needed to sign cursors", nil, nil)
+	}
+	return nil
+}
+
+func (m *APIGatewayClient) OnBefore(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "Pagination")
+	m.logger.Trace().Msg("OnBefore")
+	return nil
+}
+
+func (m *APIGatewayClient) OnAfter(_ *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	return err
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
@@ -0,0 +1,132 @@
+package pagination_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/commands/pagination"
+	"github.com/lambadass-2024/backend/internal/commands/validator"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ids = []uuid.UUID{
+	uuid.MustParse("018f83e5-79c2-74e3-8cb0-0dc11957f43a"),
+	uuid.MustParse("018f83e6-a405-75b0-8c7f-c1e0c22e7709"),
+	uuid.MustParse("018f83e8-114f-7468-8b1e-3b2fc0b3fa77"),
+}
+
+func identity(id uuid.UUID) uuid.UUID {
+	return id
+}
+
+func NewPagination(t *testing.T) (*pagination.APIGatewayClient, *validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]) {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	t.Setenv("PAGINATION_SECRET", "secret")
+	p := &pagination.APIGatewayClient{}
+	require.NoError(t, p.OnSetup(context.Background(), nil))
+	v := &validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	require.NoError(t, v.OnSetup(context.Background(), nil))
+	return p, v
+}
+
+// queryOf extracts the query parameters of a link, the cursor with the filters of the page
+func queryOf(t *testing.T, link string) map[string]string {
+	t.Helper()
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	query := map[string]string{}
+	for key := range u.Query() {
+		query[key] = u.Query().Get(key)
+	}
+	return query
+}
+
+func Test_Pagination_FirstPage(t *testing.T) {
+	p, v := NewPagination(t)
+
+	page, err := p.FromRequest(&events.APIGatewayProxyRequest{Path: "/pet", QueryStringParameters: map[string]string{"limit": "2", "race": "cat"}}, v)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Limit)
+	assert.Nil(t, page.Cursor)
+
+	where, orderLimit, params := page.Keyset("p.id")
+	assert.Equal(t, "TRUE", where)
+	assert.Equal(t, " ORDER BY p.id ASC LIMIT :page_limit", orderLimit)
+	assert.Equal(t, map[string]any{"page_limit": 3}, params)
+
+	res := pagination.NewResponse(p, page, ids, identity)
+	assert.Equal(t, ids[:2], res.Data)
+	assert.Empty(t, res.Links.Prev)
+	require.NotEmpty(t, res.Links.Next)
+	assert.Equal(t, "cat", queryOf(t, res.Links.Next)["race"])
+
+	// Follow the next link
+	next, err := p.FromRequest(&events.APIGatewayProxyRequest{Path: "/pet", QueryStringParameters: queryOf(t, res.Links.Next)}, v)
+	require.NoError(t, err)
+	require.NotNil(t, next.Cursor)
+	assert.Equal(t, pagination.Cursor{ID: ids[1], Direction: pagination.Next}, *next.Cursor)
+
+	where, orderLimit, params = next.Keyset("p.id")
+	assert.Equal(t, "p.id > :page_cursor", where)
+	assert.Equal(t, " ORDER BY p.id ASC LIMIT :page_limit", orderLimit)
+	assert.Equal(t, ids[1], params["page_cursor"])
+
+	res = pagination.NewResponse(p, next, ids[2:], identity)
+	assert.Empty(t, res.Links.Next)
+	require.NotEmpty(t, res.Links.Prev)
+
+	// And come back
+	prev, err := p.FromRequest(&events.APIGatewayProxyRequest{Path: "/pet", QueryStringParameters: queryOf(t, res.Links.Prev)}, v)
+	require.NoError(t, err)
+	where, orderLimit, _ = prev.Keyset("p.id")
+	assert.Equal(t, "p.id < :page_cursor", where)
+	assert.Equal(t, " ORDER BY p.id DESC LIMIT :page_limit", orderLimit)
+
+	res = pagination.NewResponse(p, prev, []uuid.UUID{ids[1], ids[0]}, identity)
+	assert.Equal(t, ids[:2], res.Data)
+	assert.Empty(t, res.Links.Prev)
+	assert.NotEmpty(t, res.Links.Next)
+}
+
+func Test_Pagination_InvalidCursor(t *testing.T) {
+	p, v := NewPagination(t)
+
+	page, _ := p.FromRequest(&events.APIGatewayProxyRequest{}, v)
+	link := pagination.NewResponse(p, page, make([]uuid.UUID, pagination.DefaultLimit+1), identity).Links.Next
+	query := queryOf(t, link)
+	query["cursor"] = query["cursor"][:4] + "X" + query["cursor"][5:] // Change the id
+
+	_, err := p.FromRequest(&events.APIGatewayProxyRequest{QueryStringParameters: query}, v)
+	require.Error(t, err)
+	assert.Equal(t, "INVALID_CURSOR", err.Code())
+}
+
+func Test_Pagination_InvalidLimit(t *testing.T) {
+	p, v := NewPagination(t)
+
+	for _, limit := range []string{"0", "101", "ten"} {
+		_, err := p.FromRequest(&events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"limit": limit}}, v)
+		require.Error(t, err, limit)
+		assert.Equal(t, "BAD_REQUEST", err.Code(), limit)
+	}
+}
+
+func Test_Pagination_OnSetup_NoSecret(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	t.Setenv("PAGINATION_SECRET", "")
+
+	err := (&pagination.APIGatewayClient{}).OnSetup(context.Background(), nil)
+	require.Error(t, err)
+	assert.Equal(t, "NO_PAGINATION_SECRET", err.Code())
+	var validatorFault *fault.ValidatorFault
+	assert.False(t, errors.As(err, &validatorFault))
+	assert.Equal(t, 500, fault.StatusCode(err))
+}
@@ -0,0 +1,29 @@
+package pagination
+
+import "fmt"
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Keyset returns the pieces of a query selecting page with sql.GenericClient.Select, ordered by column (the id) :
+//
+// - where is a condition, TRUE for the first page, to combine with your own conditions
+//
+// - orderLimit is the ORDER BY and LIMIT clause ending the query
+//
+// - params are the named parameters used by both, add yours to it
+//
+// One more row than the limit is selected to know if there is a next page, NewResponse removes it.
+func (p Page) Keyset(column string) (where, orderLimit string, params map[string]any) {
+	params = map[string]any{"page_limit": p.Limit + 1}
+	if p.Cursor == nil {
+		return "TRUE", fmt.Sprintf(" ORDER BY %v ASC LIMIT :page_limit", column), params
+	}
+
+	params["page_cursor"] = p.Cursor.ID
+	if p.Cursor.Direction == Prev {
+		return column + " < :page_cursor", fmt.Sprintf(" ORDER BY %v DESC LIMIT :page_limit", column), params
+	}
+	return column + " > :page_cursor", fmt.Sprintf(" ORDER BY %v ASC LIMIT :page_limit", column), params
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// KeyFunc identifies the client of a request. An empty key means the request is not rate limited.
+type KeyFunc func(request *events.APIGatewayProxyRequest) string
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// KeyByAPIKey identifies clients by the API key API Gateway validated. The X-Api-Key header is not used : a client
+// could send a new one with each request to get a new bucket. The key is hashed so it is never stored in clear.
+func KeyByAPIKey(request *events.APIGatewayProxyRequest) string {
+	apiKey := request.RequestContext.Identity.APIKey
+	if apiKey == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(apiKey))
+	return "apikey:" + hex.EncodeToString(hash[:])
+}
+
+// KeyBySubject identifies clients by the subject of the JWT validated by the API Gateway authorizer.
+func KeyBySubject(request *events.APIGatewayProxyRequest) string {
+	claims, ok := request.RequestContext.Authorizer["claims"].(map[string]any)
+	if !ok {
+		claims = request.RequestContext.Authorizer
+	}
+	if sub, ok := claims["sub"].(string); ok && sub != "" {
+		return "sub:" + sub
+	}
+	return ""
+}
+
+// KeyBySourceIP identifies clients by their IP address.
+func KeyBySourceIP(request *events.APIGatewayProxyRequest) string {
+	if request.RequestContext.Identity.SourceIP == "" {
+		return ""
+	}
+	return "ip:" + request.RequestContext.Identity.SourceIP
+}
+
+// FirstOf returns the first non empty key of keyFuncs.
+//
+// Example :
+//
+//	ratelimit.FirstOf(ratelimit.KeyByAPIKey, ratelimit.KeyBySubject, ratelimit.KeyBySourceIP)
+func FirstOf(keyFuncs ...KeyFunc) KeyFunc {
+	return func(request *events.APIGatewayProxyRequest) string {
+		for _, f := range keyFuncs {
+			if key := f(request); key != "" {
+				return key
+			}
+		}
+		return ""
+	}
+}
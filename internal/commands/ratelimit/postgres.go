@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
+)
+
+// Tokens of the bucket once refilled since its last update, capped to the limit
+const refilledTokens = `LEAST(CAST(:max_tokens AS double precision),
+	rate_limit.tokens + EXTRACT(EPOCH FROM now() - rate_limit.updated_at) * CAST(:refill_rate AS double precision))`
+
+// RateLimitSQLTake refills the bucket, takes one token if possible and returns what is left, in a single statement.
+const RateLimitSQLTake = `INSERT INTO rate_limit(key, tokens, allowed, updated_at)
+VALUES(:key, CAST(:max_tokens AS double precision) - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+	tokens = CASE WHEN ` + refilledTokens + ` >= 1 THEN ` + refilledTokens + ` - 1 ELSE ` + refilledTokens + ` END,
+	allowed = ` + refilledTokens + ` >= 1,
+	updated_at = now()
+RETURNING tokens, allowed`
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// PostgresStore keeps buckets in the rate_limit table so limits are shared between containers.
+//
+// The token is taken outside the main transaction of the SQL client, in its own committed statement :
+// a request ending with a fault consumes its token too, and the row of the bucket is not locked until
+// the end of the request. The limiter must still be added after the SQL client with Use, which opens the database
+// in its OnSetup.
+type PostgresStore[T any, U any] struct {
+	SQL sqlframework.Client[T, U]
+}
+
+type postgresTakeIn struct {
+	Key        string  `db:"key"`
+	MaxTokens  int     `db:"max_tokens"`
+	RefillRate float64 `db:"refill_rate"`
+}
+
+type postgresTakeOut struct {
+	Tokens  float64 `db:"tokens"`
+	Allowed bool    `db:"allowed"`
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Take implements Store
+func (s *PostgresStore[T, U]) Take(key string, limit int, window time.Duration) (Result, fault.Fault) {
+	in := postgresTakeIn{Key: key, MaxTokens: limit, RefillRate: refillRate(limit, window)}
+	out := []postgresTakeOut{}
+
+	err := s.SQL.SelectAutocommit(RateLimitSQLTake, in, &out)
+	if err != nil {
+		return Result{}, fault.NewRateLimit("RATE_LIMIT_STORE_ERROR", "Cannot update the rate limit bucket", nil, err)
+	}
+	if len(out) != 1 {
+		return Result{}, fault.NewRateLimit("RATE_LIMIT_STORE_ERROR", "Cannot read the rate limit bucket", nil, nil)
+	}
+	return newResult(limit, window, out[0].Tokens, out[0].Allowed), nil
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	sqlframework "github.com/lambadass-2024/backend/internal/frameworks/sql"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PostgresStore_Take_Fault(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	sqlMock := &sqlframework.MockClient[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{}
+	in := postgresTakeIn{Key: "ip:1.2.3.4", MaxTokens: 2, RefillRate: refillRate(2, time.Minute)}
+	sqlMock.MockSelectMap(sqlframework.SelectMapKey{Q: RateLimitSQLTake, DA: in}, nil, []postgresTakeOut{{Tokens: 1, Allowed: true}})
+	limiter := &APIGatewayClient{
+		Store:  &PostgresStore[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{SQL: sqlMock},
+		Key:    KeyBySourceIP,
+		Limit:  2,
+		Window: time.Minute,
+	}
+	require.NoError(t, limiter.OnSetup(context.Background(), nil))
+
+	request := &events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{SourceIP: "1.2.3.4"}},
+	}
+	require.NoError(t, sqlMock.OnBefore(context.Background(), request))
+	require.NoError(t, limiter.OnBefore(context.Background(), request))
+
+	// The request ends with a fault, so the main transaction is rolled back
+	flt := fault.NewUseCase("PetUseCase", "PET_GET_FAILED", "Cannot get this pet", nil, nil)
+	response := &events.APIGatewayProxyResponse{}
+	require.Error(t, limiter.OnAfter(response, flt))
+	require.Error(t, sqlMock.OnAfter(response, flt))
+
+	assert.Equal(t, 1, sqlMock.Committed(RateLimitSQLTake))
+	assert.Equal(t, "1", response.Headers["RateLimit-Remaining"])
+}
@@ -0,0 +1,119 @@
+// Package ratelimit contains a middleware limiting how many requests each client can make,
+// on top of the API Gateway throttling.
+//
+// Each client owns a token bucket of Limit tokens, refilled continuously over Window.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// APIGatewayClient rejects requests of clients who exceeded their limit with a 429.
+//
+// Example :
+//
+//	RateLimiter = ratelimit.APIGatewayClient{
+//		Store:  &ratelimit.MemoryStore{},
+//		Key:    ratelimit.FirstOf(ratelimit.KeyByAPIKey, ratelimit.KeyBySourceIP),
+//		Limit:  100,
+//		Window: time.Minute,
+//	}
+type APIGatewayClient struct {
+	Store  Store
+	Key    KeyFunc
+	Limit  int
+	Window time.Duration
+	logger *zerolog.Logger
+	result *Result
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// headers returns the RateLimit-* headers (and Retry-After if the request is rejected) describing res
+func headers(res Result) map[string]string {
+	h := map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(res.Limit),
+		"RateLimit-Remaining": strconv.Itoa(res.Remaining),
+		"RateLimit-Reset":     strconv.Itoa(seconds(res.Reset)),
+	}
+	if !res.Allowed {
+		h["Retry-After"] = strconv.Itoa(max(1, seconds(res.RetryAfter)))
+	}
+	return h
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "RateLimit")
+	m.logger.Trace().Msg("OnSetup")
+	if m.Store == nil || m.Key == nil || m.Limit <= 0 || m.Window <= 0 {
+		return fault.NewRateLimit("RATE_LIMIT_MISCONFIGURED", "Rate limiter needs a Store, a Key, a Limit and a Window", map[string]any{
+			"limit": m.Limit, "window": m.Window.String(),
+		}, nil)
+	}
+	return nil
+}
+
+func (m *APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "RateLimit")
+	m.logger.Trace().Msg("OnBefore")
+	m.result = nil
+
+	key := m.Key(request)
+	if key == "" {
+		m.logger.Debug().Msg("No rate limit key for this request, skipping")
+		return nil
+	}
+
+	res, err := m.Store.Take(key, m.Limit, m.Window)
+	if err != nil {
+		return err
+	}
+	m.result = &res
+	if !res.Allowed {
+		return fault.NewAPIGatewayWithHeaders(429, "TOO_MANY_REQUESTS", "Too many requests, retry later", headers(res), map[string]any{
+			"retryAfter": max(1, seconds(res.RetryAfter)),
+		}, nil)
+	}
+	return nil
+}
+
+// OnAfter adds the RateLimit-* headers to the response of allowed requests
+func (m *APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	if m.result == nil || response == nil {
+		return err
+	}
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	for key, value := range headers(*m.result) {
+		response.Headers[key] = value
+	}
+	return err
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
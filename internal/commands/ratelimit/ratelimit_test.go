@@ -0,0 +1,148 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/commands/ratelimit"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newRequest(sourceIP string) *events.APIGatewayProxyRequest {
+	return &events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Identity: events.APIGatewayRequestIdentity{SourceIP: sourceIP},
+		},
+	}
+}
+
+func NewRateLimiter(c *clock) *ratelimit.APIGatewayClient {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	limiter := &ratelimit.APIGatewayClient{
+		Store:  &ratelimit.MemoryStore{Clock: c.Now},
+		Key:    ratelimit.KeyBySourceIP,
+		Limit:  2,
+		Window: time.Minute,
+	}
+	_ = limiter.OnSetup(context.Background(), nil)
+	return limiter
+}
+
+/******************************************************************************
+***** MemoryStore
+******************************************************************************/
+
+func Test_MemoryStore_Take_Refill(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	store := ratelimit.MemoryStore{Clock: c.Now}
+
+	res, err := store.Take("key", 2, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	assert.Equal(t, 30*time.Second, res.Reset)
+
+	res, _ = store.Take("key", 2, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, _ = store.Take("key", 2, time.Minute)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 30*time.Second, res.RetryAfter)
+
+	res, _ = store.Take("other", 2, time.Minute)
+	assert.True(t, res.Allowed)
+
+	c.now = c.now.Add(30 * time.Second)
+	res, _ = store.Take("key", 2, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func Test_RateLimit_OnBefore_Allowed(t *testing.T) {
+	limiter := NewRateLimiter(&clock{now: time.Unix(0, 0)})
+
+	require.NoError(t, limiter.OnBefore(context.Background(), newRequest("1.2.3.4")))
+
+	response := &events.APIGatewayProxyResponse{StatusCode: 200}
+	require.NoError(t, limiter.OnAfter(response, nil))
+	assert.Equal(t, "2", response.Headers["RateLimit-Limit"])
+	assert.Equal(t, "1", response.Headers["RateLimit-Remaining"])
+	assert.Equal(t, "30", response.Headers["RateLimit-Reset"])
+	assert.NotContains(t, response.Headers, "Retry-After")
+}
+
+func Test_RateLimit_OnBefore_TooManyRequests(t *testing.T) {
+	limiter := NewRateLimiter(&clock{now: time.Unix(0, 0)})
+
+	require.NoError(t, limiter.OnBefore(context.Background(), newRequest("1.2.3.4")))
+	require.NoError(t, limiter.OnBefore(context.Background(), newRequest("1.2.3.4")))
+	err := limiter.OnBefore(context.Background(), newRequest("1.2.3.4"))
+	require.Error(t, err)
+
+	apigf, ok := err.(*fault.APIGatewayProxyFault)
+	require.True(t, ok)
+	assert.Equal(t, 429, apigf.StatusCode)
+	assert.Equal(t, "TOO_MANY_REQUESTS", apigf.Code())
+	assert.Equal(t, "30", apigf.Headers["Retry-After"])
+	assert.Equal(t, "0", apigf.Headers["RateLimit-Remaining"])
+
+	require.NoError(t, limiter.OnBefore(context.Background(), newRequest("5.6.7.8")))
+}
+
+func Test_RateLimit_OnBefore_NoKey(t *testing.T) {
+	limiter := NewRateLimiter(&clock{now: time.Unix(0, 0)})
+
+	for range 5 {
+		require.NoError(t, limiter.OnBefore(context.Background(), newRequest("")))
+	}
+	response := &events.APIGatewayProxyResponse{StatusCode: 200}
+	require.NoError(t, limiter.OnAfter(response, nil))
+	assert.Nil(t, response.Headers)
+}
+
+func Test_RateLimit_OnSetup_Misconfigured(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	limiter := ratelimit.APIGatewayClient{}
+
+	err := limiter.OnSetup(context.Background(), nil)
+	require.Error(t, err)
+	assert.Equal(t, "RATE_LIMIT_MISCONFIGURED", err.Code())
+}
+
+/******************************************************************************
+***** Keys
+******************************************************************************/
+
+func Test_RateLimit_FirstOf(t *testing.T) {
+	key := ratelimit.FirstOf(ratelimit.KeyByAPIKey, ratelimit.KeyBySubject, ratelimit.KeyBySourceIP)
+
+	request := newRequest("1.2.3.4")
+	assert.Equal(t, "ip:1.2.3.4", key(request))
+
+	request.RequestContext.Authorizer = map[string]any{"claims": map[string]any{"sub": "user-1"}}
+	assert.Equal(t, "sub:user-1", key(request))
+
+	request.Headers = map[string]string{"x-api-key": "secret"}
+	assert.Equal(t, "sub:user-1", key(request), "The header of the client is not trusted")
+
+	request.RequestContext.Identity.APIKey = "secret"
+	assert.Equal(t, "apikey:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", key(request))
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+// Above this number of buckets, MemoryStore drops the ones that are full again
+const maxMemoryBuckets = 10000
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Store keeps one token bucket per client key.
+//
+// Take removes one token from the bucket of key, refilled with limit tokens per window,
+// and tells if the request is allowed.
+type Store interface {
+	Take(key string, limit int, window time.Duration) (Result, fault.Fault)
+}
+
+// Result is the state of a bucket after a Take
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Time before the next token, only set when the request is not allowed
+	Reset      time.Duration // Time before the bucket is full again
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore keeps buckets in the memory of the container.
+// Limits are not shared between containers, use PostgresStore for that.
+type MemoryStore struct {
+	Clock   func() time.Time // Defaults to time.Now
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// refillRate returns how many tokens are added to a bucket each second
+func refillRate(limit int, window time.Duration) float64 {
+	return float64(limit) / window.Seconds()
+}
+
+// newResult computes a Result from the tokens left in a bucket
+func newResult(limit int, window time.Duration, tokens float64, allowed bool) Result {
+	rate := refillRate(limit, window)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(0, int(math.Floor(tokens))),
+		Reset:     time.Duration(math.Ceil((float64(limit) - tokens) / rate * float64(time.Second))),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate * float64(time.Second)))
+	}
+	return res
+}
+
+func (s *MemoryStore) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock()
+	}
+	return time.Now()
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(key string, limit int, window time.Duration) (Result, fault.Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	rate := refillRate(limit, window)
+	if s.buckets == nil {
+		s.buckets = make(map[string]*bucket)
+	}
+	if len(s.buckets) > maxMemoryBuckets {
+		s.prune(now, float64(limit), rate)
+	}
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return newResult(limit, window, b.tokens, false), nil
+	}
+	b.tokens--
+	return newResult(limit, window, b.tokens, true), nil
+}
+
+// prune removes buckets that would be full by now, they are equivalent to missing ones
+func (s *MemoryStore) prune(now time.Time, limit, rate float64) {
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*rate >= limit {
+			delete(s.buckets, key)
+		}
+	}
+}
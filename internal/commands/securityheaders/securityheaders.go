@@ -0,0 +1,129 @@
+// Package securityheaders contains a middleware adding the security headers browsers expect to every response.
+package securityheaders
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// Disabled as the value of a header of APIGatewayClient prevents it from being sent
+	Disabled = "-"
+
+	DefaultStrictTransportSecurity = "max-age=63072000; includeSubDomains" // 2 years
+	DefaultContentSecurityPolicy   = "default-src 'none'; frame-ancestors 'none'"
+	DefaultReferrerPolicy          = "no-referrer"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// APIGatewayClient adds security headers to OK and KO responses. Empty fields use the defaults, suited to a JSON API.
+// Headers already set by the handler are kept, so a function can relax its own policy.
+//
+// Error responses need the headers as much as the others. Added after the Lambda middleware, its OnAfter runs first
+// and sets them on the response of a fault too, which the Lambda middleware turns into a KO keeping its headers.
+// Added before the SQL one, it still runs when the database cannot be reached:
+//
+//	Lambda.Use(&Logger).Use(&Lambda).Use(&SecurityHeaders).Use(&SQL)...
+type APIGatewayClient struct {
+	StrictTransportSecurity string
+	ContentSecurityPolicy   string
+	ReferrerPolicy          string
+	// Authenticated tells if a request is authenticated, its responses are then sent with Cache-Control: no-store.
+	// Defaults to IsAuthenticated.
+	Authenticated func(request *events.APIGatewayProxyRequest) bool
+	logger        *zerolog.Logger
+	authenticated bool // Current request is authenticated
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// IsAuthenticated tells if request carries credentials : an Authorization header, an API key
+// or the context of an API Gateway authorizer
+func IsAuthenticated(request *events.APIGatewayProxyRequest) bool {
+	return lambdaframework.Header(request, "Authorization") != "" ||
+		request.RequestContext.Identity.APIKey != "" ||
+		len(request.RequestContext.Authorizer) > 0
+}
+
+// headers returns the headers to add to every response
+func (m *APIGatewayClient) headers() map[string]string {
+	h := map[string]string{
+		"Strict-Transport-Security": valueOrDefault(m.StrictTransportSecurity, DefaultStrictTransportSecurity),
+		"Content-Security-Policy":   valueOrDefault(m.ContentSecurityPolicy, DefaultContentSecurityPolicy),
+		"Referrer-Policy":           valueOrDefault(m.ReferrerPolicy, DefaultReferrerPolicy),
+		"X-Content-Type-Options":    "nosniff",
+	}
+	if m.authenticated {
+		h["Cache-Control"] = "no-store"
+	}
+	return h
+}
+
+func valueOrDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// hasHeader looks for name in headers without case sensitivity, as handlers may write them in any case
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "SecurityHeaders")
+	m.logger.Trace().Msg("OnSetup")
+	if m.Authenticated == nil {
+		m.Authenticated = IsAuthenticated
+	}
+	return nil
+}
+
+func (m *APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "SecurityHeaders")
+	m.logger.Trace().Msg("OnBefore")
+	m.authenticated = m.Authenticated(request)
+	return nil
+}
+
+// OnAfter adds the security headers to the response, whether the request succeeded or not
+func (m *APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	if response == nil {
+		return err
+	}
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	for key, value := range m.headers() {
+		if value != Disabled && !hasHeader(response.Headers, key) {
+			response.Headers[key] = value
+		}
+	}
+	return err
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
@@ -0,0 +1,57 @@
+package securityheaders_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/commands/securityheaders"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handle(t *testing.T, m *securityheaders.APIGatewayClient, request *events.APIGatewayProxyRequest, response *events.APIGatewayProxyResponse, err fault.Fault) {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	assert.Equal(t, err, m.OnAfter(response, err))
+}
+
+func Test_SecurityHeaders_Defaults(t *testing.T) {
+	response := &events.APIGatewayProxyResponse{}
+	handle(t, &securityheaders.APIGatewayClient{}, &events.APIGatewayProxyRequest{}, response, nil)
+
+	assert.Equal(t, map[string]string{
+		"Strict-Transport-Security": securityheaders.DefaultStrictTransportSecurity,
+		"Content-Security-Policy":   securityheaders.DefaultContentSecurityPolicy,
+		"Referrer-Policy":           securityheaders.DefaultReferrerPolicy,
+		"X-Content-Type-Options":    "nosniff",
+	}, response.Headers)
+}
+
+func Test_SecurityHeaders_Authenticated(t *testing.T) {
+	response := &events.APIGatewayProxyResponse{}
+	request := &events.APIGatewayProxyRequest{Headers: map[string]string{"authorization": "Bearer token"}}
+	handle(t, &securityheaders.APIGatewayClient{}, request, response, fault.NewAPIGateway(404, "NOT_FOUND", "Not found", nil, nil))
+
+	assert.Equal(t, "no-store", response.Headers["Cache-Control"])
+}
+
+func Test_SecurityHeaders_Configured(t *testing.T) {
+	response := &events.APIGatewayProxyResponse{Headers: map[string]string{"referrer-policy": "origin"}}
+	m := &securityheaders.APIGatewayClient{
+		StrictTransportSecurity: securityheaders.Disabled,
+		ContentSecurityPolicy:   "default-src 'self'",
+		Authenticated:           func(_ *events.APIGatewayProxyRequest) bool { return true },
+	}
+	handle(t, m, &events.APIGatewayProxyRequest{}, response, nil)
+
+	assert.NotContains(t, response.Headers, "Strict-Transport-Security")
+	assert.Equal(t, "default-src 'self'", response.Headers["Content-Security-Policy"])
+	assert.Equal(t, "origin", response.Headers["referrer-policy"])
+	assert.NotContains(t, response.Headers, "Referrer-Policy")
+	assert.Equal(t, "no-store", response.Headers["Cache-Control"])
+}
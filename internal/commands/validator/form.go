@@ -0,0 +1,281 @@
+package validator
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+const DefaultMaxFileSize = DefaultMaxBodySize
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// File is a file uploaded in a multipart/form-data body, bound to the *File and []*File fields of a struct.
+//
+// Fields can restrict the size and the type of their files with a file tag, types being sniffed from the content :
+//
+//	Photo *validator.File `form:"photo" file:"max=5242880,types=image/jpeg image/png" validate:"required"`
+type File struct {
+	Filename    string
+	ContentType string // Sniffed from the content, the type declared by the client is in Header
+	Size        int
+	Content     []byte
+	Header      textproto.MIMEHeader
+	mime        *mimetype.MIME
+}
+
+// formField is a field of a struct bound from a form
+type formField struct {
+	name  string
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+var (
+	fileType      = reflect.TypeFor[*File]()
+	filesType     = reflect.TypeFor[[]*File]()
+	unmarshalType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// parseURLEncoded reads an application/x-www-form-urlencoded body
+func (t LambdaValidator[T, U]) parseURLEncoded(body string) (url.Values, fault.Fault) {
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return nil, t.newMalformedFormFault(err)
+	}
+	return values, nil
+}
+
+// parseMultipart reads a multipart/form-data body, enforcing MaxFileSize on each file
+func (t LambdaValidator[T, U]) parseMultipart(body, boundary string) (url.Values, map[string][]*File, fault.Fault) {
+	if boundary == "" {
+		return nil, nil, t.newMalformedFormFault(errors.New("multipart: no boundary in Content-Type"))
+	}
+	values, files := url.Values{}, map[string][]*File{}
+	reader := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF { //nolint:errorlint // io.EOF is returned unwrapped by NextPart
+			return values, files, nil
+		}
+		if err != nil {
+			return nil, nil, t.newMalformedFormFault(err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, nil, t.newMalformedFormFault(err)
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			values.Add(name, string(content))
+			continue
+		}
+
+		detected := mimetype.Detect(content)
+		file := &File{Filename: part.FileName(), ContentType: detected.String(), Size: len(content), Content: content, Header: part.Header, mime: detected}
+		if t.MaxFileSize != Unlimited && file.Size > t.MaxFileSize {
+			return nil, nil, t.newFileFault("FILE_TOO_LARGE", "An uploaded file is too large", name, file, map[string]any{"maxFileSize": t.MaxFileSize})
+		}
+		files[name] = append(files[name], file)
+	}
+}
+
+// bindForm sets the fields of data, a pointer to a struct, from the values and files of a form.
+// Fields are named by their form tag, or their json tag so the structs of JSON bodies can be reused.
+func (t LambdaValidator[T, U]) bindForm(values url.Values, files map[string][]*File, data any) fault.Fault {
+	rv := reflect.ValueOf(data)
+	for rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fault.NewValidatorFault("INTERNAL_MARSHALING_ERROR",
+			"Cannot bind the provided form because of an internal error", nil, fmt.Errorf("cannot bind a form into %T", data))
+	}
+	fields := map[string]formField{}
+	collectFormFields(rv.Elem(), fields)
+
+	for name := range values {
+		if _, ok := fields[name]; !ok {
+			return t.newFormFault("UNKNOWN_FIELD", "Cannot bind the provided form : unknown field", fmt.Errorf("form: unknown field %q", name))
+		}
+	}
+	for name := range files {
+		if field, ok := fields[name]; !ok || (field.value.Type() != fileType && field.value.Type() != filesType) {
+			return t.newFormFault("UNKNOWN_FIELD", "Cannot bind the provided form : unknown file field", fmt.Errorf("form: unknown file field %q", name))
+		}
+	}
+
+	for name, field := range fields {
+		if fs, ok := files[name]; ok {
+			if err := t.bindFiles(field, fs); err != nil {
+				return err
+			}
+			continue
+		}
+		if strs, ok := values[name]; ok {
+			if err := setFormValue(field.value, strs); err != nil {
+				return t.newFormFault("WRONG_TYPE", "Cannot bind the provided form because a wrong type is used", fmt.Errorf("form: field %q: %w", name, err))
+			}
+		}
+	}
+	return nil
+}
+
+func collectFormFields(v reflect.Value, fields map[string]formField) {
+	for i := range v.NumField() {
+		structField := v.Type().Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		name := structField.Tag.Get("form")
+		if name == "" {
+			name, _, _ = strings.Cut(structField.Tag.Get("json"), ",")
+		}
+		if name == "-" {
+			continue
+		}
+		if structField.Anonymous && name == "" && structField.Type.Kind() == reflect.Struct {
+			collectFormFields(v.Field(i), fields)
+			continue
+		}
+		if name == "" {
+			name = structField.Name
+		}
+		fields[name] = formField{name: name, value: v.Field(i), tag: structField.Tag}
+	}
+}
+
+// bindFiles checks the files of field against its file tag and sets it
+func (t LambdaValidator[T, U]) bindFiles(field formField, files []*File) fault.Fault {
+	limits := map[string]string{}
+	for _, option := range strings.Split(field.tag.Get("file"), ",") {
+		key, value, _ := strings.Cut(option, "=")
+		limits[key] = value
+	}
+	for _, file := range files {
+		if limit, err := strconv.Atoi(limits["max"]); err == nil && file.Size > limit {
+			return t.newFileFault("FILE_TOO_LARGE", "An uploaded file is too large", field.name, file, map[string]any{"maxFileSize": limit})
+		}
+		if allowed := strings.Fields(limits["types"]); len(allowed) > 0 && !file.is(allowed) {
+			return t.newFileFault("UNSUPPORTED_FILE_TYPE", "An uploaded file has a type which is not allowed", field.name, file, map[string]any{"allowed": allowed})
+		}
+	}
+
+	if field.value.Type() == fileType {
+		field.value.Set(reflect.ValueOf(files[0]))
+	} else {
+		field.value.Set(reflect.ValueOf(files))
+	}
+	return nil
+}
+
+// is tells if the sniffed type of f is one of types, which may be wildcards as image/*
+func (f *File) is(types []string) bool {
+	for _, t := range types {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(f.ContentType, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if f.mime.Is(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFormValue converts the values of a form field to the type of v, repeated keys filling slices
+func setFormValue(v reflect.Value, strs []string) error {
+	if v.Kind() == reflect.Slice && !v.Addr().Type().Implements(unmarshalType) {
+		slice := reflect.MakeSlice(v.Type(), len(strs), len(strs))
+		for i, s := range strs {
+			if err := setFormScalar(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setFormScalar(v, strs[len(strs)-1])
+}
+
+func setFormScalar(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setFormScalar(v.Elem(), s)
+	}
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot bind a form value into %s", v.Type())
+	}
+	return nil
+}
+
+func (t LambdaValidator[T, U]) newFormFault(code, message string, cause error) fault.Fault {
+	return fault.NewValidatorFault(code, message, map[string]any{
+		"unmarshall": map[string]any{"message": cause.Error()},
+	}, cause)
+}
+
+func (t LambdaValidator[T, U]) newMalformedFormFault(cause error) fault.Fault {
+	return t.newFormFault("MALFORMED_FORM", "Cannot read the provided form because its malformed", cause)
+}
+
+func (t LambdaValidator[T, U]) newFileFault(code, message, field string, file *File, limit map[string]any) fault.Fault {
+	return fault.NewValidatorFault(code, message, map[string]any{
+		"file":  map[string]any{"field": field, "filename": file.Filename, "contentType": file.ContentType, "size": file.Size},
+		"limit": limit,
+	}, nil)
+}
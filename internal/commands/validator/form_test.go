@@ -0,0 +1,123 @@
+package validator_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/commands/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type PetForm struct {
+	ID     uuid.UUID         `json:"id"     validate:"required"`
+	Name   string            `json:"name"   validate:"required"`
+	Age    int               `form:"age"    validate:"gte=0"`
+	Tags   []string          `json:"tags"`
+	Photo  *validator.File   `form:"photo"  file:"max=1024,types=image/png"`
+	Extras []*validator.File `form:"extras" file:"types=image/* text/plain"`
+}
+
+var (
+	png     = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00")
+	petUUID = "752cd664-4267-493e-b831-1d4587abf5b3"
+)
+
+type part struct {
+	name, filename string
+	content        []byte
+}
+
+func multipartRequest(t *testing.T, parts ...part) *events.APIGatewayProxyRequest {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, p := range parts {
+		if p.filename == "" {
+			require.NoError(t, writer.WriteField(p.name, string(p.content)))
+			continue
+		}
+		w, err := writer.CreateFormFile(p.name, p.filename)
+		require.NoError(t, err)
+		_, err = w.Write(p.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return &events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Type": writer.FormDataContentType()}, Body: body.String()}
+}
+
+func Test_Form_URLEncoded(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{})
+	request := &events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:    "id=" + petUUID + "&name=bang&age=3&tags=a&tags=b",
+	}
+
+	var data PetForm
+	require.NoError(t, v.ValidateRequestIntoStruct(request, &data))
+	assert.Equal(t, PetForm{ID: uuid.MustParse(petUUID), Name: "bang", Age: 3, Tags: []string{"a", "b"}}, data)
+}
+
+func Test_Form_URLEncodedFaults(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{})
+	for body, code := range map[string]string{
+		"name=bang&color=red":                 "UNKNOWN_FIELD",
+		"id=" + petUUID + "&name=bang&age=x":  "WRONG_TYPE",
+		"id=nope&name=bang":                   "WRONG_TYPE",
+		"id=" + petUUID + "&name=bang&age=-1": "BAD_REQUEST",
+		"name=%zz":                            "MALFORMED_FORM",
+	} {
+		var data PetForm
+		err := v.ValidateRequestIntoStruct(&events.APIGatewayProxyRequest{
+			Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			Body:    body,
+		}, &data)
+		require.Error(t, err, body)
+		assert.Equal(t, code, err.Code(), body)
+	}
+}
+
+func Test_Form_Multipart(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{})
+	request := multipartRequest(t,
+		part{name: "id", content: []byte(petUUID)},
+		part{name: "name", content: []byte("bang")},
+		part{name: "photo", filename: "bang.png", content: png},
+		part{name: "extras", filename: "a.txt", content: []byte("hello")},
+		part{name: "extras", filename: "b.png", content: png},
+	)
+
+	var data PetForm
+	require.NoError(t, v.ValidateRequestIntoStruct(request, &data))
+	require.NotNil(t, data.Photo)
+	assert.Equal(t, "bang.png", data.Photo.Filename)
+	assert.Equal(t, "image/png", data.Photo.ContentType)
+	assert.Equal(t, png, data.Photo.Content)
+	require.Len(t, data.Extras, 2)
+	assert.Equal(t, "text/plain; charset=utf-8", data.Extras[0].ContentType)
+}
+
+func Test_Form_MultipartFiles(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{MaxFileSize: 2048})
+	id, name := part{name: "id", content: []byte(petUUID)}, part{name: "name", content: []byte("bang")}
+
+	for code, file := range map[string]part{
+		"UNSUPPORTED_FILE_TYPE": {name: "photo", filename: "bang.png", content: []byte("not a png")},
+		"FILE_TOO_LARGE":        {name: "photo", filename: "bang.png", content: append(png, make([]byte, 1024)...)},
+		"UNKNOWN_FIELD":         {name: "name", filename: "name.txt", content: []byte("bang")},
+	} {
+		var data PetForm
+		err := v.ValidateRequestIntoStruct(multipartRequest(t, id, name, file), &data)
+		require.Error(t, err, code)
+		assert.Equal(t, code, err.Code())
+	}
+
+	var data PetForm
+	err := v.ValidateRequestIntoStruct(multipartRequest(t, id, name, part{name: "extras", filename: "big", content: make([]byte, 4096)}), &data)
+	require.Error(t, err)
+	assert.Equal(t, "FILE_TOO_LARGE", err.Code())
+	assert.Equal(t, map[string]any{"maxFileSize": 2048}, err.Metadata()["limit"])
+}
@@ -0,0 +1,95 @@
+package validator
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+const (
+	DefaultMaxBodySize    = 1 << 20 // 1 MiB
+	DefaultMaxDepth       = 32
+	DefaultMaxArrayLength = 1000
+	// Unlimited disables a limit of LambdaValidator
+	Unlimited = -1
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// frame is an array or object being read by checkJSONLimits
+type frame struct {
+	array bool
+	count int
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// setDefaultLimits replaces the zero limits by the defaults
+func (t *LambdaValidator[T, U]) setDefaultLimits() {
+	if t.MaxBodySize == 0 {
+		t.MaxBodySize = DefaultMaxBodySize
+	}
+	if t.MaxDepth == 0 {
+		t.MaxDepth = DefaultMaxDepth
+	}
+	if t.MaxArrayLength == 0 {
+		t.MaxArrayLength = DefaultMaxArrayLength
+	}
+	if t.MaxFileSize == 0 {
+		t.MaxFileSize = DefaultMaxFileSize
+	}
+}
+
+// checkBodySize returns a 413 fault if a body of size bytes is too large
+func (t LambdaValidator[T, U]) checkBodySize(size int) fault.Fault {
+	if t.MaxBodySize == Unlimited || size <= t.MaxBodySize {
+		return nil
+	}
+	return fault.NewValidatorFault("PAYLOAD_TOO_LARGE", "The body of the request is too large", map[string]any{
+		"limit": map[string]any{"maxBodySize": t.MaxBodySize, "size": size},
+	}, nil)
+}
+
+// checkJSONLimits reads the tokens of jsonString to enforce MaxDepth and MaxArrayLength before it is decoded,
+// so a hostile document cannot make the decoder allocate much. Syntax errors are left to the decoder.
+func (t LambdaValidator[T, U]) checkJSONLimits(jsonString string) fault.Fault {
+	if t.MaxDepth == Unlimited && t.MaxArrayLength == Unlimited {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(jsonString))
+	var stack []frame
+	for {
+		token, err := decoder.Token()
+		if err != nil { // End of the document, or a syntax error the decoder will report
+			return nil
+		}
+		delim, isDelim := token.(json.Delim)
+		if isDelim && (delim == ']' || delim == '}') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		// A value starts, or a key if the current frame is an object
+		if len(stack) > 0 && stack[len(stack)-1].array {
+			stack[len(stack)-1].count++
+			if t.MaxArrayLength != Unlimited && stack[len(stack)-1].count > t.MaxArrayLength {
+				return fault.NewValidatorFault("JSON_ARRAY_TOO_LONG", "An array of the JSON body has too many items", map[string]any{
+					"limit": map[string]any{"maxArrayLength": t.MaxArrayLength},
+				}, nil)
+			}
+		}
+		if isDelim {
+			stack = append(stack, frame{array: delim == '['})
+			if t.MaxDepth != Unlimited && len(stack) > t.MaxDepth {
+				return fault.NewValidatorFault("JSON_TOO_DEEP", "The JSON body is nested too deeply", map[string]any{
+					"limit": map[string]any{"maxDepth": t.MaxDepth},
+				}, nil)
+			}
+		}
+	}
+}
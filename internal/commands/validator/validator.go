@@ -2,22 +2,33 @@ package validator
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"mime"
+	"net/url"
 	"strings"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/go-playground/validator/v10"
 	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 /*****************************************************************************
 ***** Structs
 ******************************************************************************/
 
+// LambdaValidator validates JSON bodies and structs.
+// Limits left to zero use their default (DefaultMaxBodySize...), Unlimited disables them.
 type LambdaValidator[T any, U any] struct {
-	logger    *zerolog.Logger
-	validator *validator.Validate
+	MaxBodySize    int // In bytes, after base64 decoding
+	MaxDepth       int // Nesting of arrays and objects in JSON bodies
+	MaxArrayLength int // Items of each array of JSON bodies
+	MaxFileSize    int // Each file of multipart/form-data bodies, in bytes
+	logger         *zerolog.Logger
+	validator      *validator.Validate
 }
 
 /******************************************************************************
@@ -27,16 +38,79 @@ type LambdaValidator[T any, U any] struct {
 // Validates the given JSON string and populates the provided data structure.
 // It returns a fault.Fault if there are any validation errors.
 func (t LambdaValidator[T, U]) ValidateJSONIntoStruct(jsonString string, data any) fault.Fault {
+	if err := t.checkBodySize(len(jsonString)); err != nil {
+		return err
+	}
+	if err := t.checkJSONLimits(jsonString); err != nil {
+		return err
+	}
+
 	decoder := json.NewDecoder(strings.NewReader(jsonString))
 	decoder.DisallowUnknownFields()
 	err := decoder.Decode(&data)
 	if err != nil {
-		return fault.NewValidatorFaultFromDecoder(t.logger, err)
+		return fault.NewValidatorFaultFromDecoder(err)
 	}
 
 	err = t.validator.Struct(data)
 	if err != nil {
-		return fault.NewValidatorFaultFromStruct(t.logger, err)
+		return fault.NewValidatorFaultFromStruct(err)
+	}
+	return nil
+}
+
+// ValidateRequestIntoStruct validates the body of an API Gateway request into data, decoding it first if it is base64 encoded.
+// JSON bodies are read like ValidateJSONIntoStruct, application/x-www-form-urlencoded and multipart/form-data ones
+// are bound to the same fields (see File for uploads). Other content types are refused with a 415.
+func (t LambdaValidator[T, U]) ValidateRequestIntoStruct(request *events.APIGatewayProxyRequest, data any) fault.Fault {
+	contentType := lambdaframework.Header(request, "Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	isJSON := err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+	if !isJSON && mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+		return fault.NewValidatorFault("UNSUPPORTED_MEDIA_TYPE",
+			"The body of the request must be sent as application/json, application/x-www-form-urlencoded or multipart/form-data", map[string]any{
+				"contentType": contentType,
+			}, err)
+	}
+
+	body := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return fault.NewValidatorFault("MALFORMED_BASE64", "Cannot decode the base64 encoded body", nil, err)
+		}
+		body = string(decoded)
+	}
+	if isJSON {
+		return t.ValidateJSONIntoStruct(body, data)
+	}
+
+	if err := t.checkBodySize(len(body)); err != nil {
+		return err
+	}
+	var values url.Values
+	var files map[string][]*File
+	var f fault.Fault
+	if mediaType == "multipart/form-data" {
+		values, files, f = t.parseMultipart(body, params["boundary"])
+	} else {
+		values, f = t.parseURLEncoded(body)
+	}
+	if f != nil {
+		return f
+	}
+	if f := t.bindForm(values, files, data); f != nil {
+		return f
+	}
+	return t.ValidateStruct(data)
+}
+
+// ValidateStruct validates data against its validate tags.
+// It returns a fault.Fault if there are any validation errors.
+func (t LambdaValidator[T, U]) ValidateStruct(data any) fault.Fault {
+	err := t.validator.Struct(data)
+	if err != nil {
+		return fault.NewValidatorFaultFromStruct(err)
 	}
 	return nil
 }
@@ -45,16 +119,25 @@ func (t LambdaValidator[T, U]) ValidateJSONIntoStruct(jsonString string, data an
 ***** Middleware
 ******************************************************************************/
 func (t *LambdaValidator[T, U]) OnSetup(_ context.Context, _ *T) fault.Fault {
-	ll := log.Logger.With().Str("commands", "Validator").Logger()
-	t.logger = &ll
+	t.logger = loggerframework.Child("commands", "Validator")
 	t.logger.Info().Msg("Creating validator")
 	t.validator = validator.New(validator.WithRequiredStructEnabled())
+	t.setDefaultLimits()
 	t.logger.Trace().Msg("OnSetup")
 	return nil
 }
 
-func (t *LambdaValidator[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+// OnBefore rejects the API Gateway requests whose body is too large, before any handler reads it
+func (t *LambdaValidator[T, U]) OnBefore(_ context.Context, request *T) fault.Fault {
+	t.logger = loggerframework.Child("commands", "Validator")
 	t.logger.Trace().Msg("OnBefore")
+	if r, ok := any(request).(*events.APIGatewayProxyRequest); ok {
+		size := len(r.Body)
+		if r.IsBase64Encoded {
+			size = base64.StdEncoding.DecodedLen(size)
+		}
+		return t.checkBodySize(size)
+	}
 	return nil
 }
 
@@ -0,0 +1,87 @@
+package validator_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/commands/validator"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Body struct {
+	Name  string   `json:"name" validate:"required"`
+	Items []any    `json:"items"`
+	Tags  []string `json:"tags"`
+}
+
+func newValidator(t *testing.T, v validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]) *validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse] {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	require.NoError(t, v.OnSetup(context.Background(), nil))
+	return &v
+}
+
+func Test_Validator_Limits(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{
+		MaxBodySize: 64, MaxDepth: 3, MaxArrayLength: 2,
+	})
+	var data Body
+
+	require.NoError(t, v.ValidateJSONIntoStruct(`{"name":"a","items":[[1],{"a":1}]}`, &data))
+
+	err := v.ValidateJSONIntoStruct(`{"name":"a","items":[[[1]]]}`, &data)
+	require.Error(t, err)
+	assert.Equal(t, "JSON_TOO_DEEP", err.Code())
+
+	err = v.ValidateJSONIntoStruct(`{"name":"a","tags":["a","b","c"]}`, &data)
+	require.Error(t, err)
+	assert.Equal(t, "JSON_ARRAY_TOO_LONG", err.Code())
+
+	err = v.ValidateJSONIntoStruct(`{"name":"`+strings.Repeat("a", 64)+`"}`, &data)
+	require.Error(t, err)
+	assert.Equal(t, "PAYLOAD_TOO_LARGE", err.Code())
+}
+
+func Test_Validator_Unlimited(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{
+		MaxDepth: validator.Unlimited, MaxArrayLength: validator.Unlimited,
+	})
+	var data Body
+	require.NoError(t, v.ValidateJSONIntoStruct(`{"name":"a","items":[`+strings.Repeat("[", 100)+strings.Repeat("]", 100)+`]}`, &data))
+}
+
+func Test_Validator_Request(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{})
+	var data Body
+
+	request := &events.APIGatewayProxyRequest{
+		Headers:         map[string]string{"content-type": "application/json; charset=utf-8"},
+		IsBase64Encoded: true,
+		Body:            base64.StdEncoding.EncodeToString([]byte(`{"name":"a"}`)),
+	}
+	require.NoError(t, v.ValidateRequestIntoStruct(request, &data))
+	assert.Equal(t, "a", data.Name)
+
+	request.Body = "%%%"
+	err := v.ValidateRequestIntoStruct(request, &data)
+	require.Error(t, err)
+	assert.Equal(t, "MALFORMED_BASE64", err.Code())
+
+	err = v.ValidateRequestIntoStruct(&events.APIGatewayProxyRequest{Body: `{"name":"a"}`}, &data)
+	require.Error(t, err)
+	assert.Equal(t, "UNSUPPORTED_MEDIA_TYPE", err.Code())
+}
+
+func Test_Validator_OnBefore_BodySize(t *testing.T) {
+	v := newValidator(t, validator.LambdaValidator[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{MaxBodySize: 4})
+
+	require.NoError(t, v.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Body: "1234"}))
+	err := v.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Body: "12345"})
+	require.Error(t, err)
+	assert.Equal(t, "PAYLOAD_TOO_LARGE", err.Code())
+}
@@ -0,0 +1,292 @@
+// Package versioning contains a middleware resolving the version of the API requested by a client,
+// so the JSON shape of the entities can evolve without breaking existing clients.
+//
+// The version is read, by priority, from :
+//   - a path prefix : /v2/pet
+//   - a header : Api-Version: 2
+//   - the version parameter of the Accept header : Accept: application/vnd.lambadass+json;version=2
+//
+// Requests asking for no version get the Default one, the first by default as it is the one clients used before.
+package versioning
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+)
+
+const DefaultHeader = "Api-Version"
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Version is a version of the API, named as clients request it : 2 for /v2/ or Api-Version: 2
+type Version struct {
+	Name        string
+	Deprecation time.Time // Sent in a Deprecation header when set, the date may be in the future to announce it
+	Sunset      time.Time // Sent in a Sunset header when set, requests are answered with a 410 once it is over
+	Link        string    // Documentation of the migration, sent in a Link header with Deprecation or Sunset
+}
+
+// APIGatewayClient resolves the version of each request, and adds its Deprecation and Sunset headers to the responses.
+// Handlers read it with Version, or let Route and Transform pick what to do for them.
+//
+// The version must be known before the handler runs, and a request for an unknown or sunset version is better
+// refused before a transaction is opened for it : add it before the SQL middleware. Its faults become KO responses
+// in the Lambda middleware added before it:
+//
+//	Versioning = versioning.APIGatewayClient{Versions: []versioning.Version{
+//		{Name: "1", Deprecation: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Link: "https://docs.lambadass.com/migrate-v2"},
+//		{Name: "2"},
+//	}}
+//	...
+//	Lambda.Use(&Logger).Use(&Lambda).Use(&Versioning).Use(&SQL)...
+type APIGatewayClient struct {
+	Versions  []Version // Oldest first
+	Default   string    // Version of the requests asking for none, defaults to the first of Versions
+	Header    string    // Header carrying the version, defaults to DefaultHeader
+	MediaType string    // Only the version parameter of this media type is read from Accept, any media type if empty
+	Now       func() time.Time
+	logger    *zerolog.Logger
+	current   int  // Index in Versions of the version of the current request, -1 if unresolved
+	fromPath  bool // The version of the current request comes from its path, its response does not vary with headers
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Version returns the name of the version requested by the current request
+func (m *APIGatewayClient) Version() string {
+	if m.current < 0 || m.current >= len(m.Versions) {
+		return ""
+	}
+	return m.Versions[m.current].Name
+}
+
+// AtLeast tells if the current request asks for version name or a newer one
+func (m *APIGatewayClient) AtLeast(name string) bool {
+	i := m.index(name)
+	return i >= 0 && m.current >= i
+}
+
+// Route returns a handler calling the handler of the current version, or of the newest version before it,
+// so a handler only has to be written for the versions changing something.
+//
+// Example :
+//
+//	Lambda.Start(Versioning.Route(map[string]lambdaframework.HandlerFunc[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{
+//		"1": HandleRequest,
+//		"3": HandleRequestV3,
+//	}))
+func (m *APIGatewayClient) Route(
+	handlers map[string]lambdaframework.HandlerFunc[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse],
+) lambdaframework.HandlerFunc[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse] {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, fault.Fault) {
+		handler, ok := pick(m, handlers)
+		if !ok {
+			return events.APIGatewayProxyResponse{}, fault.NewAPIGateway(500, "NO_HANDLER_FOR_VERSION",
+				"No handler can answer this version of the API", map[string]any{"version": m.Version()}, nil)
+		}
+		return handler(ctx, request)
+	}
+}
+
+// Transform returns obj as the current version expects it, with the transformer of this version or of the newest
+// version before it. obj is returned as is if none applies, so transformers are only needed for the old versions.
+//
+// Example :
+//
+//	pet, err := PetUseCase.Get(id)
+//	...
+//	return Lambda.OK(versioning.Transform(&Versioning, pet, map[string]func(entities.Pet) any{
+//		"1": func(pet entities.Pet) any { return petV1{ID: pet.ID, Name: pet.Name, Race: pet.Race.Name} },
+//		"2": func(pet entities.Pet) any { return pet },
+//	}))
+func Transform[T any](m *APIGatewayClient, obj T, transformers map[string]func(T) any) any {
+	transformer, ok := pick(m, transformers)
+	if !ok {
+		return obj
+	}
+	return transformer(obj)
+}
+
+// pick returns the value of the current version in byVersion, or of the newest version before it
+func pick[V any](m *APIGatewayClient, byVersion map[string]V) (V, bool) {
+	for i := m.current; i >= 0 && i < len(m.Versions); i-- {
+		if value, ok := byVersion[m.Versions[i].Name]; ok {
+			return value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+func (m *APIGatewayClient) index(name string) int {
+	for i, version := range m.Versions {
+		if version.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// requested returns the version asked by request, "" if it asks for none, and whether it comes from its path
+func (m *APIGatewayClient) requested(request *events.APIGatewayProxyRequest) (name string, fromPath bool) {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(request.Path, "/"), "/")
+	if len(segment) > 1 && segment[0] == 'v' && unicode.IsDigit(rune(segment[1])) {
+		return segment[1:], true
+	}
+	if value := strings.TrimSpace(lambdaframework.Header(request, m.Header)); value != "" {
+		return value, false
+	}
+	return m.acceptVersion(lambdaframework.Header(request, "Accept")), false
+}
+
+// acceptVersion returns the version parameter of the first media range of accept having one
+func (m *APIGatewayClient) acceptVersion(accept string) string {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		if m.MediaType != "" && !strings.EqualFold(strings.TrimSpace(params[0]), m.MediaType) {
+			continue
+		}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(key), "version") {
+				return strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return ""
+}
+
+// headers returns the headers describing version to its clients
+func (m *APIGatewayClient) headers(version Version) map[string]string {
+	h := map[string]string{m.Header: version.Name}
+	if !version.Deprecation.IsZero() {
+		h["Deprecation"] = "@" + strconv.FormatInt(version.Deprecation.Unix(), 10)
+	}
+	if !version.Sunset.IsZero() {
+		h["Sunset"] = version.Sunset.UTC().Format(http.TimeFormat)
+	}
+	switch {
+	case version.Link == "":
+	case !version.Deprecation.IsZero():
+		h["Link"] = "<" + version.Link + `>; rel="deprecation"`
+	case !version.Sunset.IsZero():
+		h["Link"] = "<" + version.Link + `>; rel="sunset"`
+	}
+	return h
+}
+
+// supported returns the names of the versions which are not over
+func (m *APIGatewayClient) supported() []string {
+	names := make([]string, 0, len(m.Versions))
+	for _, version := range m.Versions {
+		if !m.isOver(version) {
+			names = append(names, version.Name)
+		}
+	}
+	return names
+}
+
+func (m *APIGatewayClient) isOver(version Version) bool {
+	return !version.Sunset.IsZero() && !m.Now().Before(version.Sunset)
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "Versioning")
+	m.logger.Trace().Msg("OnSetup")
+	m.current = -1
+	if m.Header == "" {
+		m.Header = DefaultHeader
+	}
+	if m.Now == nil {
+		m.Now = time.Now
+	}
+	if len(m.Versions) == 0 {
+		return fault.NewAPIGateway(500, "VERSIONING_MISCONFIGURED", "Versioning needs at least one version", nil, nil)
+	}
+	for i, version := range m.Versions {
+		if version.Name == "" || m.index(version.Name) != i {
+			return fault.NewAPIGateway(500, "VERSIONING_MISCONFIGURED", "Versions must have unique names", map[string]any{
+				"version": version.Name,
+			}, nil)
+		}
+	}
+	if m.Default == "" {
+		m.Default = m.Versions[0].Name
+	}
+	if m.index(m.Default) < 0 {
+		return fault.NewAPIGateway(500, "VERSIONING_MISCONFIGURED", "Default version is not one of the versions", map[string]any{
+			"default": m.Default,
+		}, nil)
+	}
+	return nil
+}
+
+// OnBefore resolves the version of the request, rejecting unknown versions with a 400 and the ones over with a 410
+func (m *APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("commands", "Versioning")
+	m.logger.Trace().Msg("OnBefore")
+	m.current, m.fromPath = -1, false
+
+	name, fromPath := m.requested(request)
+	if name == "" {
+		name = m.Default
+	}
+	i := m.index(name)
+	if i < 0 {
+		return fault.NewAPIGateway(400, "UNSUPPORTED_API_VERSION", "This version of the API does not exist", map[string]any{
+			"version": name, "supported": m.supported(),
+		}, nil)
+	}
+	if m.isOver(m.Versions[i]) {
+		return fault.NewAPIGatewayWithHeaders(410, "API_VERSION_SUNSET", "This version of the API is no longer available",
+			m.headers(m.Versions[i]), map[string]any{"version": name, "supported": m.supported()}, nil)
+	}
+	m.current, m.fromPath = i, fromPath
+	m.logger.Debug().Str("version", name).Msg("API version resolved")
+	return nil
+}
+
+// OnAfter adds the version of the request to the response, with its Deprecation and Sunset headers
+func (m *APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	if m.current < 0 || response == nil {
+		return err
+	}
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	for key, value := range m.headers(m.Versions[m.current]) {
+		if key == "Link" {
+			lambdaframework.MergeHeader(response.Headers, key, value)
+		} else {
+			response.Headers[key] = value
+		}
+	}
+	if !m.fromPath {
+		lambdaframework.MergeHeader(response.Headers, "Vary", m.Header)
+		lambdaframework.MergeHeader(response.Headers, "Vary", "Accept")
+	}
+	return err
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
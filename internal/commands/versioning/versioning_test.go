@@ -0,0 +1,156 @@
+package versioning_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/commands/versioning"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	deprecation = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset      = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newVersioning(t *testing.T, now time.Time) *versioning.APIGatewayClient {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	m := &versioning.APIGatewayClient{
+		Versions: []versioning.Version{
+			{Name: "1", Deprecation: deprecation, Sunset: sunset, Link: "https://docs.lambadass.com/v2"},
+			{Name: "2"},
+			{Name: "3"},
+		},
+		MediaType: "application/vnd.lambadass+json",
+		Now:       func() time.Time { return now },
+	}
+	require.NoError(t, m.OnSetup(context.Background(), nil))
+	return m
+}
+
+func Test_Versioning_Resolve(t *testing.T) {
+	m := newVersioning(t, deprecation)
+	for expected, request := range map[string]*events.APIGatewayProxyRequest{
+		"1": {Path: "/pet"},
+		"2": {Path: "/v2/pet", Headers: map[string]string{"Api-Version": "3"}},
+		"3": {Path: "/pet", Headers: map[string]string{"api-version": "3", "Accept": "application/vnd.lambadass+json;version=2"}},
+	} {
+		require.NoError(t, m.OnBefore(context.Background(), request))
+		assert.Equal(t, expected, m.Version(), request.Path)
+	}
+
+	require.NoError(t, m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{
+		Path: "/pet", Headers: map[string]string{"Accept": `application/json;version=3, application/vnd.lambadass+json; version="2"`},
+	}))
+	assert.Equal(t, "2", m.Version())
+	assert.True(t, m.AtLeast("2"))
+	assert.False(t, m.AtLeast("3"))
+	assert.False(t, m.AtLeast("4"))
+}
+
+func Test_Versioning_Unsupported(t *testing.T) {
+	m := newVersioning(t, deprecation)
+	err := m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Path: "/v9/pet"})
+	require.Error(t, err)
+
+	apigf, ok := err.(*fault.APIGatewayProxyFault)
+	require.True(t, ok)
+	assert.Equal(t, 400, apigf.StatusCode)
+	assert.Equal(t, "UNSUPPORTED_API_VERSION", apigf.Code())
+	assert.Equal(t, []string{"1", "2", "3"}, apigf.Metadata()["supported"])
+}
+
+func Test_Versioning_Sunset(t *testing.T) {
+	m := newVersioning(t, sunset)
+	err := m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Path: "/v1/pet"})
+	require.Error(t, err)
+
+	apigf, ok := err.(*fault.APIGatewayProxyFault)
+	require.True(t, ok)
+	assert.Equal(t, 410, apigf.StatusCode)
+	assert.Equal(t, "API_VERSION_SUNSET", apigf.Code())
+	assert.Equal(t, "Thu, 01 Jan 2026 00:00:00 GMT", apigf.Headers["Sunset"])
+	assert.Equal(t, []string{"2", "3"}, apigf.Metadata()["supported"])
+}
+
+func Test_Versioning_Headers(t *testing.T) {
+	m := newVersioning(t, deprecation)
+	require.NoError(t, m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Path: "/pet"}))
+	response := &events.APIGatewayProxyResponse{Headers: map[string]string{"Vary": "Accept", "link": `</pet?cursor=a>; rel="next"`}}
+	require.NoError(t, m.OnAfter(response, nil))
+
+	assert.Equal(t, map[string]string{
+		"Api-Version": "1",
+		"Deprecation": "@1735689600",
+		"Sunset":      "Thu, 01 Jan 2026 00:00:00 GMT",
+		"link":        `</pet?cursor=a>; rel="next", <https://docs.lambadass.com/v2>; rel="deprecation"`,
+		"Vary":        "Accept, Api-Version",
+	}, response.Headers)
+
+	require.NoError(t, m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Path: "/v3/pet"}))
+	response = &events.APIGatewayProxyResponse{}
+	require.NoError(t, m.OnAfter(response, nil))
+	assert.Equal(t, map[string]string{"Api-Version": "3"}, response.Headers)
+}
+
+func Test_Versioning_Headers_Compressed(t *testing.T) {
+	m := newVersioning(t, deprecation)
+	request := &events.APIGatewayProxyRequest{Path: "/pet", Headers: map[string]string{"Accept-Encoding": "gzip", "Api-Version": "2"}}
+	apiGateway := lambdaframework.APIGatewayClient{Lambda: lambdaframework.TestNewLambda(request)}
+	require.NoError(t, m.OnBefore(context.Background(), request))
+
+	response, err := apiGateway.OK(map[string]string{"name": strings.Repeat("Rex ", 500)})
+	require.NoError(t, err)
+	// The middlewares run OnAfter in reverse order, the Lambda middleware being before the versioning
+	require.NoError(t, m.OnAfter(&response, nil))
+	require.NoError(t, apiGateway.OnAfter(&response, nil))
+
+	assert.Equal(t, "gzip", response.Headers["Content-Encoding"])
+	assert.Equal(t, "Accept, Api-Version, Accept-Encoding", response.Headers["Vary"])
+}
+
+func Test_Versioning_RouteAndTransform(t *testing.T) {
+	m := newVersioning(t, deprecation)
+	handler := m.Route(map[string]lambdaframework.HandlerFunc[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]{
+		"1": func(_ context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, fault.Fault) {
+			return events.APIGatewayProxyResponse{Body: "v1"}, nil
+		},
+		"3": func(_ context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, fault.Fault) {
+			return events.APIGatewayProxyResponse{Body: "v3"}, nil
+		},
+	})
+	transformers := map[string]func(int) any{"1": func(i int) any { return -i }}
+
+	for version, expected := range map[string]string{"1": "v1", "2": "v1", "3": "v3"} {
+		require.NoError(t, m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Path: "/v" + version + "/pet"}))
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, expected, response.Body, version)
+		assert.Equal(t, -1, versioning.Transform(m, 1, transformers), version)
+	}
+
+	transformers = map[string]func(int) any{"2": func(i int) any { return -i }}
+	require.NoError(t, m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{Path: "/v1/pet"}))
+	assert.Equal(t, 1, versioning.Transform(m, 1, transformers))
+}
+
+func Test_Versioning_Misconfigured(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	for _, m := range []*versioning.APIGatewayClient{
+		{},
+		{Versions: []versioning.Version{{Name: "1"}, {Name: "1"}}},
+		{Versions: []versioning.Version{{Name: "1"}}, Default: "2"},
+	} {
+		err := m.OnSetup(context.Background(), nil)
+		require.Error(t, err)
+		assert.Equal(t, "VERSIONING_MISCONFIGURED", err.Code())
+	}
+}
@@ -3,15 +3,17 @@ package fault
 import (
 	"fmt"
 
-	"github.com/rs/zerolog"
+	"github.com/lambadass-2024/backend/internal/redact"
 )
 
 type APIGatewayProxyFault struct {
 	StatusCode int
+	Headers    map[string]string
 	code       string
 	message    string
 	metadata   map[string]any
 	cause      error
+	stack      Stack
 }
 
 func (u APIGatewayProxyFault) Code() string {
@@ -38,46 +40,61 @@ func (u APIGatewayProxyFault) Cause() error {
 	return u.cause
 }
 
+func (u APIGatewayProxyFault) Unwrap() error {
+	return u.cause
+}
+
+// Is tells if target is the Sentinel of the code of u
+func (u APIGatewayProxyFault) Is(target error) bool {
+	return is(u.code, target)
+}
+
+func (u APIGatewayProxyFault) StackTrace() Stack {
+	return u.stack
+}
+
 func (u APIGatewayProxyFault) Error() string {
 	return fmt.Sprintf("APIGatewayProxyFault [%v] : %v", u.code, u.message)
 }
 
-func NewAPIGateway(logger *zerolog.Logger, statusCode int, code, message string, metadata map[string]any, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-	fault := APIGatewayProxyFault{StatusCode: statusCode, code: code, message: message, metadata: metadata, cause: cause}
-	e.Err(&fault).Msg(message)
+func NewAPIGateway(statusCode int, code, message string, metadata map[string]any, cause error) Fault {
+	fault := APIGatewayProxyFault{StatusCode: statusCode, code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
 	return &fault
 }
 
-func NewAPIGatewayFromFault(logger *zerolog.Logger, statusCode int, cause Fault) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-	fault := APIGatewayProxyFault{StatusCode: statusCode, code: cause.Code(), message: cause.Message(), metadata: cause.Metadata(), cause: cause}
-	e.Err(&fault).Msg(cause.Message())
+// NewAPIGatewayWithHeaders is like NewAPIGateway, but the headers will be added to the response
+func NewAPIGatewayWithHeaders(
+	statusCode int, code, message string, headers map[string]string, metadata map[string]any, cause error,
+) Fault {
+	fault := APIGatewayProxyFault{StatusCode: statusCode, Headers: headers, code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
 	return &fault
 }
 
-func NewAPIGatewayFromValidatorFault(logger *zerolog.Logger, cause Fault) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-
-	var statusCode int
-	switch cause.Code() {
-	case "UNKNOWN_FIELD":
-		statusCode = 400
-	case "MALFORMED_JSON":
-		statusCode = 400
-	case "EMPTY_JSON":
-		statusCode = 400
-	case "WRONG_TYPE":
-		statusCode = 400
-	case "BAD_REQUEST":
-		statusCode = 400
-	case "INTERNAL_MARSHALING_ERROR":
-		statusCode = 500
-	default:
-		statusCode = 500
-	}
+func NewAPIGatewayFromFault(statusCode int, cause Fault) Fault {
+	fault := APIGatewayProxyFault{StatusCode: statusCode, code: cause.Code(), message: cause.Message(), metadata: redact.Metadata(cause.Metadata()), cause: cause}
+	return &fault
+}
 
-	fault := APIGatewayProxyFault{StatusCode: statusCode, code: cause.Code(), message: cause.Message(), metadata: cause.Metadata(), cause: cause}
-	e.Err(&fault).Msg(cause.Message())
+// NewAPIGatewayFromCatalog is like NewAPIGatewayFromFault, with the status declared for the code of cause in the
+// catalog, 500 if undeclared. A cause without message gets the public message of its code.
+func NewAPIGatewayFromCatalog(cause Fault) Fault {
+	message := cause.Message()
+	if d, ok := Lookup(cause.Code()); ok && message == "" {
+		message = d.Message
+	}
+	fault := APIGatewayProxyFault{StatusCode: CodeStatus(cause.Code()), code: cause.Code(), message: message, metadata: redact.Metadata(cause.Metadata()), cause: cause}
 	return &fault
 }
+
+func NewAPIGatewayFromValidatorFault(cause Fault) Fault {
+	return NewAPIGatewayFromCatalog(cause)
+}
+
+// StatusCode returns the status code of the response of f, as the Lambda middleware makes it :
+// the one of an APIGatewayProxyFault, else the one declared for its code in the catalog
+func StatusCode(f Fault) int {
+	if apigf, ok := f.(*APIGatewayProxyFault); ok {
+		return apigf.StatusCode
+	}
+	return CodeStatus(f.Code())
+}
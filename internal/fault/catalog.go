@@ -0,0 +1,67 @@
+package fault
+
+import (
+	"fmt"
+	"sort"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Definition declares a fault code once, for every fault having it : the status of the responses made of these
+// faults, the message API consumers can rely on, whether retrying the request may succeed, and what it means.
+//
+// Internal codes never reach a response as is, the faults having them being wrapped with a public code first,
+// so they are not documented for API consumers.
+type Definition struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Message     string `json:"message"` // Public message, for faults without message
+	Retryable   bool   `json:"retryable"`
+	Description string `json:"description"`
+	Internal    bool   `json:"-"`
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+var catalog = map[string]Definition{}
+
+// Declare adds definitions to the catalog. It panics if a code is declared twice, or without a status.
+func Declare(definitions ...Definition) {
+	for _, d := range definitions {
+		if _, ok := catalog[d.Code]; ok {
+			panic(fmt.Sprintf("fault code %v declared twice", d.Code))
+		}
+		if d.Code == "" || d.Status < 400 || d.Status > 599 {
+			panic(fmt.Sprintf("fault code %q declared without a KO status", d.Code))
+		}
+		catalog[d.Code] = d
+	}
+}
+
+// Lookup returns the definition of code
+func Lookup(code string) (Definition, bool) {
+	d, ok := catalog[code]
+	return d, ok
+}
+
+// Catalog returns the definitions of all the codes, sorted by code
+func Catalog() []Definition {
+	definitions := make([]Definition, 0, len(catalog))
+	for _, d := range catalog {
+		definitions = append(definitions, d)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Code < definitions[j].Code })
+	return definitions
+}
+
+// CodeStatus returns the status of the responses made of the faults of code, 500 for an undeclared code
+func CodeStatus(code string) int {
+	if d, ok := catalog[code]; ok {
+		return d.Status
+	}
+	return 500
+}
@@ -0,0 +1,131 @@
+package fault_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var codeFormat = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// sourceCodes returns the fault codes written as literals in the sources of the module, with where they are :
+// arguments of the parameters named code, and values given to variables or fields named code, in upper snake case
+func sourceCodes(t *testing.T, root string) map[string]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []*ast.File
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root && (d.Name() == "build" || d.Name() == "terraform" || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		files = append(files, f)
+		return err
+	})
+	require.NoError(t, err)
+
+	parameters := map[string]int{} // Position of the parameter named code, by function name
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			i := 0
+			for _, field := range fd.Type.Params.List {
+				for _, name := range field.Names {
+					if name.Name == "code" {
+						parameters[fd.Name.Name] = i
+					}
+					i++
+				}
+			}
+		}
+	}
+
+	codes := map[string]string{}
+	add := func(expr ast.Expr) {
+		if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if code, err := strconv.Unquote(lit.Value); err == nil && codeFormat.MatchString(code) {
+				codes[code] = fset.Position(lit.Pos()).String()
+			}
+		}
+	}
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				var name string
+				switch fun := n.Fun.(type) {
+				case *ast.Ident:
+					name = fun.Name
+				case *ast.SelectorExpr:
+					name = fun.Sel.Name
+				}
+				if i, ok := parameters[name]; ok && i < len(n.Args) {
+					add(n.Args[i])
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok && ident.Name == "code" && i < len(n.Rhs) {
+						add(n.Rhs[i])
+					}
+				}
+			case *ast.KeyValueExpr:
+				if ident, ok := n.Key.(*ast.Ident); ok && ident.Name == "code" {
+					add(n.Value)
+				}
+			}
+			return true
+		})
+	}
+	return codes
+}
+
+func Test_Catalog_Declared(t *testing.T) {
+	codes := sourceCodes(t, "../..")
+	require.Contains(t, codes, "PET_NOT_FOUND")
+
+	var undeclared []string
+	for code, position := range codes {
+		if _, ok := fault.Lookup(code); !ok {
+			undeclared = append(undeclared, code+" ("+position+")")
+		}
+	}
+	sort.Strings(undeclared)
+	assert.Empty(t, undeclared, "Declare these fault codes in internal/fault/codes.go")
+}
+
+func Test_Catalog_Lookup(t *testing.T) {
+	definition, ok := fault.Lookup("TOO_MANY_REQUESTS")
+	require.True(t, ok)
+	assert.Equal(t, 429, definition.Status)
+	assert.True(t, definition.Retryable)
+
+	_, ok = fault.Lookup("UNDECLARED")
+	assert.False(t, ok)
+	assert.Equal(t, 404, fault.CodeStatus("PET_NOT_FOUND"))
+	assert.Equal(t, 500, fault.CodeStatus("UNDECLARED"))
+
+	definitions := fault.Catalog()
+	assert.True(t, sort.SliceIsSorted(definitions, func(i, j int) bool { return definitions[i].Code < definitions[j].Code }))
+	assert.Panics(t, func() { fault.Declare(fault.Definition{Code: "TOO_MANY_REQUESTS", Status: 429}) })
+	assert.Panics(t, func() { fault.Declare(fault.Definition{Code: "NOT_A_KO", Status: 200}) })
+}
@@ -0,0 +1,131 @@
+package fault
+
+// The fault codes of the project, each declared once. Test_Catalog_Declared fails on a code written in the
+// sources without being declared here, and cmd/faultcodes documents the public ones for API consumers.
+func init() {
+	// Requests rejected by the validator, the pagination or the OpenAPI document
+	Declare(
+		Definition{Code: "BAD_REQUEST", Status: 400, Message: "Bad request",
+			Description: "The request does not pass the validation, the failing fields are in metadata.validation."},
+		Definition{Code: "UNKNOWN_FIELD", Status: 400, Message: "Cannot unmarshall the provided JSON : unknown field",
+			Description: "The body has a field the endpoint does not know."},
+		Definition{Code: "MALFORMED_JSON", Status: 400, Message: "Cannot unmarshall the provided JSON because its malformed",
+			Description: "The body is not valid JSON."},
+		Definition{Code: "EMPTY_JSON", Status: 400, Message: "Cannot unmarshall the provided JSON because it's empty",
+			Description: "The endpoint needs a body and none was sent."},
+		Definition{Code: "WRONG_TYPE", Status: 400, Message: "Cannot unmarshall the provided JSON because a wrong type is used",
+			Description: "A field of the body has a type other than the one of the schema."},
+		Definition{Code: "JSON_TOO_DEEP", Status: 400, Message: "The JSON body is nested too deeply",
+			Description: "The objects and arrays of the body are nested beyond the limit of the endpoint."},
+		Definition{Code: "JSON_ARRAY_TOO_LONG", Status: 400, Message: "An array of the JSON body has too many items",
+			Description: "An array of the body has more items than the limit of the endpoint."},
+		Definition{Code: "MALFORMED_BASE64", Status: 400, Message: "Cannot decode the base64 encoded body",
+			Description: "The body is flagged as base64 encoded but cannot be decoded."},
+		Definition{Code: "MALFORMED_FORM", Status: 400, Message: "Cannot read the provided form because its malformed",
+			Description: "The urlencoded or multipart form of the body cannot be parsed."},
+		Definition{Code: "INVALID_CURSOR", Status: 400, Message: "Invalid cursor",
+			Description: "The pagination cursor was not given by a previous page, was altered or has expired."},
+		Definition{Code: "PAYLOAD_TOO_LARGE", Status: 413, Message: "The body of the request is too large",
+			Description: "The body is larger than the limit of the endpoint."},
+		Definition{Code: "FILE_TOO_LARGE", Status: 413, Message: "An uploaded file is too large",
+			Description: "A file of the multipart form is larger than the limit of its field."},
+		Definition{Code: "UNSUPPORTED_MEDIA_TYPE", Status: 415, Message: "Unsupported media type",
+			Description: "The Content-Type of the body is not accepted by the endpoint."},
+		Definition{Code: "UNSUPPORTED_FILE_TYPE", Status: 415, Message: "An uploaded file has a type which is not allowed",
+			Description: "A file of the multipart form has a type not allowed for its field."},
+		Definition{Code: "INTERNAL_MARSHALING_ERROR", Status: 500, Message: "Cannot unmarshall the provided JSON because of an internal error",
+			Description: "The body cannot be decoded because of a bug of the endpoint."},
+		Definition{Code: "UNEXPECTED_INPUT_VALIDATION_ERROR", Status: 500, Message: "Validation raised an unexpected error",
+			Description: "The validation of the request failed because of a bug of the endpoint."},
+		Definition{Code: "INVALID_RESPONSE", Status: 500, Message: "Response does not match the OpenAPI document",
+			Description: "The endpoint made a response its OpenAPI document does not allow."},
+		Definition{Code: "INVALID_OPENAPI_DOCUMENT", Status: 500, Message: "Cannot parse the OpenAPI document",
+			Description: "The OpenAPI document bundled with the function is invalid."},
+		Definition{Code: "NO_PAGINATION_SECRET", Status: 500, Message: "PAGINATION_SECRET is needed to sign cursors",
+			Description: "The function is deployed without the secret of its pagination cursors."},
+	)
+
+	// Responses of the lambda framework and of the commands around the handlers
+	Declare(
+		Definition{Code: "NOT_ACCEPTABLE", Status: 406, Message: "None of the accepted media types can be produced",
+			Description: "None of the media types of the Accept header can be produced by the endpoint."},
+		Definition{Code: "PRECONDITION_FAILED", Status: 412, Message: "The resource has been modified since you read it",
+			Description: "The ETag of If-Match does not match the current one, the resource must be read again."},
+		Definition{Code: "TOO_MANY_REQUESTS", Status: 429, Message: "Too many requests, retry later", Retryable: true,
+			Description: "The rate limit of the caller is reached, retry after the delay of the Retry-After header."},
+		Definition{Code: "UNSUPPORTED_API_VERSION", Status: 400, Message: "This version of the API does not exist",
+			Description: "The version asked with the Api-Version header does not exist, the supported ones are in metadata."},
+		Definition{Code: "API_VERSION_SUNSET", Status: 410, Message: "This version of the API is no longer available",
+			Description: "The version asked with the Api-Version header was removed after its sunset date."},
+		Definition{Code: "ERROR_MARSHALL_JSON", Status: 500, Message: "Error while marshaling an object to JSON",
+			Description: "The response cannot be encoded because of a bug of the endpoint."},
+		Definition{Code: "ERROR_ENCODING_RESPONSE", Status: 500, Message: "Error while encoding the response",
+			Description: "The response cannot be encoded in the negotiated media type."},
+		Definition{Code: "API_GATEWAY_NIL_RESPONSE", Status: 500, Message: "No response was made",
+			Description: "The handler made no response, a bug of the lambda framework."},
+		Definition{Code: "NO_HANDLER_FOR_VERSION", Status: 500, Message: "No handler can answer this version of the API",
+			Description: "The version is declared, but the function has no handler for it."},
+		Definition{Code: "VERSIONING_MISCONFIGURED", Status: 500, Message: "Versioning misconfigured",
+			Description: "The versions of the function are invalid."},
+		Definition{Code: "RATE_LIMIT_MISCONFIGURED", Status: 500, Message: "Rate limiter misconfigured",
+			Description: "The rate limiter of the function lacks its store, key, limit or window."},
+		Definition{Code: "RATE_LIMIT_STORE_ERROR", Status: 500, Message: "Cannot check the rate limit", Retryable: true,
+			Description: "The store of the rate limits cannot be reached."},
+	)
+
+	// Pets
+	Declare(
+		Definition{Code: "PET_NOT_FOUND", Status: 404, Message: "Pet not found",
+			Description: "No pet has this id."},
+		Definition{Code: "PET_ID_NOT_UNIQUE", Status: 422, Message: "Pet id not unique",
+			Description: "A pet already has the id given to the new one."},
+		Definition{Code: "PET_CREATION_FAILED", Status: 500, Message: "Pet creation failed", Retryable: true,
+			Description: "The pet cannot be stored."},
+		Definition{Code: "PET_GET_FAILED", Status: 500, Message: "Cannot get this pet", Retryable: true,
+			Description: "The pet cannot be read."},
+		Definition{Code: "PET_UPDATE_FAILED", Status: 500, Message: "Pet update failed", Retryable: true,
+			Description: "The pet cannot be updated."},
+		Definition{Code: "IDENTIFIER_GENERATION_ERROR", Status: 500, Message: "Cannot generate identifier", Retryable: true,
+			Description: "No id can be generated for the new resource."},
+	)
+
+	// Repositories and SQL, wrapped by the use cases before reaching a response
+	Declare(
+		Definition{Code: "NOT_FOUND", Status: 500, Internal: true, Message: "Not found",
+			Description: "The repository has no row for the id, the use case tells which resource is missing."},
+		Definition{Code: "TOO_MANY_PETS", Status: 500, Internal: true, Message: "Multiple pets found",
+			Description: "The repository found several rows for an id."},
+		Definition{Code: "INSERT_ERROR", Status: 500, Internal: true, Message: "Error while inserting",
+			Description: "The repository cannot insert the row."},
+		Definition{Code: "UPDATE_ERROR", Status: 500, Internal: true, Message: "Error while updating",
+			Description: "The repository cannot update the row."},
+		Definition{Code: "SELECT_ERROR", Status: 500, Internal: true, Message: "Error while selecting",
+			Description: "The repository cannot select the rows."},
+		Definition{Code: "UNIQUE_VIOLATION", Status: 500, Internal: true, Message: "Unique violation",
+			Description: "PostgreSQL unique_violation (23505), also used by the repositories."},
+		Definition{Code: "FOREIGN_KEY_VIOLATION", Status: 500, Internal: true, Message: "Foreign key violation",
+			Description: "PostgreSQL foreign_key_violation (23503)."},
+		Definition{Code: "PREPARED_STATEMENT_FAILED", Status: 500, Internal: true, Message: "Prepared statement cannot be created",
+			Description: "The query cannot be prepared."},
+		Definition{Code: "ROW_AFFECTED_UNKNOWN", Status: 500, Internal: true, Message: "Cannot get the number of row affected",
+			Description: "The driver cannot tell how many rows the query changed."},
+		Definition{Code: "ROW_AFFECTED_NOT_ONE", Status: 500, Internal: true, Message: "The number of row affected is not 1",
+			Description: "A query meant to change one row changed none or several."},
+		Definition{Code: "SQL_CONNECTION_ERROR", Status: 500, Internal: true, Retryable: true, Message: "Cannot connect to the database",
+			Description: "The database cannot be reached."},
+		Definition{Code: "NO_SQL_CONNECTION_MAX_IDLE_TIME", Status: 500, Internal: true, Message: "Invalid connection max idle time",
+			Description: "The max idle time of the connections is missing or invalid."},
+		Definition{Code: "NO_SQL_CONNECTION_MAX_LIFE_TIME", Status: 500, Internal: true, Message: "Invalid connection max life time",
+			Description: "The max life time of the connections is missing or invalid."},
+		Definition{Code: "NEW_TRANSACTION_ERROR", Status: 500, Internal: true, Retryable: true, Message: "Cannot create new transaction",
+			Description: "The transaction of the request cannot be started."},
+		Definition{Code: "SQL_COMMIT_ERROR", Status: 500, Internal: true, Retryable: true, Message: "Commit raised an error",
+			Description: "The transaction of the request cannot be committed."},
+		Definition{Code: "SQL_ROLLBACK_ERROR", Status: 500, Internal: true, Message: "Rollbacking main transaction raised an error",
+			Description: "The transaction of a failed request cannot be rolled back."},
+		Definition{Code: "SQL_ROLLBACK_NIL_TRANSACTION", Status: 500, Internal: true, Message: "Rollbacking main transaction is impossible because it's nil",
+			Description: "A failed request has no transaction to roll back."},
+		Definition{Code: "MOCK_DATA_NOT_FOUND", Status: 500, Internal: true, Message: "Mock data not found",
+			Description: "Tests only : the SQL mock has no data for the query."},
+	)
+}
@@ -3,6 +3,11 @@
 // It contains much more information really useful for debugging and limiting complexity through this project
 package fault
 
+import (
+	"errors"
+	"fmt"
+)
+
 type Layer int
 
 var layersString = []string{
@@ -32,5 +37,82 @@ type Fault interface {
 	Message() string
 	Metadata() map[string]any
 	Cause() error
+	Unwrap() error // The same as Cause, so errors.Is and errors.As go through the chain
 	Error() string
 }
+
+// Sentinel stands for every fault of its code, whatever its layer : errors.Is(err, sentinel) tells if a fault
+// of the chain of err has this code.
+//
+//	var ErrUniqueViolation = fault.NewSentinel("UNIQUE_VIOLATION")
+//	if errors.Is(err, sqlframework.ErrUniqueViolation) { ... }
+type Sentinel struct {
+	code string
+}
+
+func NewSentinel(code string) *Sentinel {
+	return &Sentinel{code: code}
+}
+
+func (s *Sentinel) Code() string {
+	return s.code
+}
+
+func (*Sentinel) Layer() Layer {
+	return Other
+}
+
+func (*Sentinel) Middleware() string {
+	return ""
+}
+
+func (s *Sentinel) Message() string {
+	return s.code
+}
+
+func (*Sentinel) Metadata() map[string]any {
+	return nil
+}
+
+func (*Sentinel) Cause() error {
+	return nil
+}
+
+func (*Sentinel) Unwrap() error {
+	return nil
+}
+
+func (s *Sentinel) Is(target error) bool {
+	return is(s.code, target)
+}
+
+func (s *Sentinel) Error() string {
+	return fmt.Sprintf("Sentinel [%v]", s.code)
+}
+
+// is tells if target is the Sentinel of code
+func is(code string, target error) bool {
+	s, ok := target.(*Sentinel)
+	return ok && s.code == code
+}
+
+// Find returns the first fault of the chain of err, from err to the error where it started, for which match is true
+func Find(err error, match func(Fault) bool) (Fault, bool) {
+	for err != nil {
+		if f, ok := err.(Fault); ok && match(f) {
+			return f, true
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil, false
+}
+
+// FindLayer returns the first fault of layer in the chain of err
+func FindLayer(err error, layer Layer) (Fault, bool) {
+	return Find(err, func(f Fault) bool { return f.Layer() == layer })
+}
+
+// FindCode returns the first fault of code in the chain of err
+func FindCode(err error, code string) (Fault, bool) {
+	return Find(err, func(f Fault) bool { return f.Code() == code })
+}
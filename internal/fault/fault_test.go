@@ -0,0 +1,72 @@
+package fault_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errConnectionFailed = fault.NewSentinel("CONNECTION_FAILED")
+
+func Test_Fault_Is_Sentinel(t *testing.T) {
+	flt := newChain(500)
+
+	assert.ErrorIs(t, flt, errConnectionFailed)
+	assert.ErrorIs(t, flt, fault.NewSentinel("PET_CREATE_FAILED"))
+	assert.NotErrorIs(t, flt, fault.NewSentinel("PET_NOT_FOUND"))
+	assert.ErrorIs(t, errConnectionFailed, errConnectionFailed)
+}
+
+func Test_Fault_Is_Cause(t *testing.T) {
+	flt := fault.NewUseCase("PetUseCase", "PET_GET_FAILED", "Cannot get this pet", nil,
+		fault.NewRepository("PetRepository", "SELECT_ERROR", "Error while selecting Pet", nil,
+			fault.NewSQL("UNKNOWN_PG_ERROR", "Error while executing SQL", nil, fmt.Errorf("select : %w", sql.ErrNoRows))))
+
+	assert.ErrorIs(t, flt, sql.ErrNoRows)
+	var pathError *fs.PathError
+	assert.False(t, errors.As(flt, &pathError))
+}
+
+func Test_Fault_As(t *testing.T) {
+	cause := &fs.PathError{Op: "open", Path: "config.json", Err: fs.ErrNotExist}
+	flt := fault.NewAPIGatewayFromFault(500, fault.NewRateLimit("RATE_LIMIT_STORE_ERROR", "Cannot read the rate limit bucket", nil, cause))
+
+	var pathError *fs.PathError
+	require.ErrorAs(t, flt, &pathError)
+	assert.Equal(t, "config.json", pathError.Path)
+	var rateLimit *fault.RateLimitFault
+	require.ErrorAs(t, flt, &rateLimit)
+	assert.Equal(t, "RATE_LIMIT_STORE_ERROR", rateLimit.Code())
+	assert.ErrorIs(t, flt, fs.ErrNotExist)
+}
+
+func Test_Fault_FindLayer(t *testing.T) {
+	flt := newChain(500)
+
+	found, ok := fault.FindLayer(flt, fault.Adapters)
+	require.True(t, ok)
+	assert.Equal(t, "PET_CREATE_FAILED", found.Code())
+	assert.Equal(t, "PetRepository", found.Middleware())
+
+	_, ok = fault.FindLayer(flt, fault.UseCases)
+	assert.False(t, ok)
+}
+
+func Test_Fault_FindCode(t *testing.T) {
+	flt := fmt.Errorf("handler : %w", newChain(500))
+
+	found, ok := fault.FindCode(flt, "CONNECTION_FAILED")
+	require.True(t, ok)
+	assert.Equal(t, "Sql", found.Middleware())
+
+	_, ok = fault.FindCode(flt, "PET_NOT_FOUND")
+	assert.False(t, ok)
+	_, ok = fault.FindCode(nil, "CONNECTION_FAILED")
+	assert.False(t, ok)
+}
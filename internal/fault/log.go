@@ -0,0 +1,76 @@
+package fault
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lambadass-2024/backend/internal/redact"
+	"github.com/rs/zerolog"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// chain is the faults of a request, logged as an array
+type chain []Fault
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Log writes f and the faults causing it as a single log line, once the response of the request is known :
+// at error level for a 5xx, or when statusCode is 0 as no response was made, else at warn level.
+//
+// The line has the code, layer and middleware of f, the chain of its faults with the code, layer, middleware,
+// message and metadata of each one, and the error where the chain started, redacted. Faults log nothing when made.
+func Log(logger *zerolog.Logger, f Fault, statusCode int) {
+	level := zerolog.WarnLevel
+	if statusCode == 0 || statusCode >= 500 {
+		level = zerolog.ErrorLevel
+	}
+	faults, origin := unwind(f)
+	e := logger.WithLevel(level).
+		Str("code", f.Code()).
+		Str("layer", f.Layer().String()).
+		Str("middleware", f.Middleware()).
+		Array("faults", faults)
+	if statusCode != 0 {
+		e = e.Int("status", statusCode)
+	}
+	if origin != nil {
+		e = e.AnErr("cause", redact.Err(origin))
+	}
+	e.Msg(f.Error())
+}
+
+// unwind returns the faults of the chain of f, f first, and the error which is not a fault ending it, if any
+func unwind(f Fault) (chain, error) {
+	faults := chain{f}
+	cause := f.Cause()
+	for cause != nil {
+		next, ok := cause.(Fault)
+		if !ok {
+			var wrapped Fault
+			if !errors.As(cause, &wrapped) {
+				return faults, cause
+			}
+			next = wrapped
+		}
+		faults = append(faults, next)
+		cause = next.Cause()
+	}
+	return faults, nil
+}
+
+func (c chain) MarshalZerologArray(a *zerolog.Array) {
+	for _, f := range c {
+		a.Dict(zerolog.Dict().
+			Str("code", f.Code()).
+			Str("layer", f.Layer().String()).
+			Str("middleware", f.Middleware()).
+			Str("type", fmt.Sprintf("%T", f)).
+			Str("message", f.Message()).
+			Interface("metadata", redact.Metadata(f.Metadata())))
+	}
+}
@@ -0,0 +1,77 @@
+package fault_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChain(statusCode int) fault.Fault {
+	sql := fault.NewSQL("CONNECTION_FAILED", "Cannot connect", nil, errors.New("dial tcp: connection refused"))
+	repository := fault.NewRepository("PetRepository", "PET_CREATE_FAILED", "Cannot create the pet", map[string]any{"id": "1"}, sql)
+	return fault.NewAPIGatewayFromFault(statusCode, repository)
+}
+
+func logLine(t *testing.T, f fault.Fault, statusCode int) map[string]any {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer zerolog.SetGlobalLevel(zerolog.Disabled)
+	buffer := &bytes.Buffer{}
+	logger := zerolog.New(buffer)
+
+	fault.Log(&logger, f, statusCode)
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	require.Len(t, lines, 1)
+	line := map[string]any{}
+	require.NoError(t, json.Unmarshal(lines[0], &line))
+	return line
+}
+
+func Test_Fault_Log_Chain(t *testing.T) {
+	line := logLine(t, newChain(500), 500)
+
+	assert.Equal(t, "error", line["level"])
+	assert.Equal(t, "PET_CREATE_FAILED", line["code"])
+	assert.Equal(t, "Commands", line["layer"])
+	assert.InDelta(t, 500, line["status"], 0)
+	assert.Equal(t, "dial tcp: connection refused", line["cause"])
+	faults, ok := line["faults"].([]any)
+	require.True(t, ok)
+	require.Len(t, faults, 3)
+	assert.Equal(t, map[string]any{
+		"code": "PET_CREATE_FAILED", "layer": "Adapters", "middleware": "PetRepository", "type": "*fault.RepositoryFault",
+		"message": "Cannot create the pet", "metadata": map[string]any{"id": "1"},
+	}, faults[1])
+	assert.Equal(t, "CONNECTION_FAILED", faults[2].(map[string]any)["code"])
+}
+
+func Test_Fault_Log_Level(t *testing.T) {
+	assert.Equal(t, "warn", logLine(t, newChain(422), 422)["level"])
+	assert.Equal(t, "error", logLine(t, newChain(503), 503)["level"])
+
+	line := logLine(t, fault.NewRateLimit("RATE_LIMIT_STORE_ERROR", "Cannot read the rate limit bucket", nil, nil), 0)
+	assert.Equal(t, "error", line["level"])
+	assert.NotContains(t, line, "status")
+	assert.NotContains(t, line, "cause")
+}
+
+func Test_Fault_Log_DecoderReason(t *testing.T) {
+	var pet any = struct{ Name string }{} // Not a pointer
+	err := json.Unmarshal([]byte(`{"name":"rex"}`), pet)
+	require.Error(t, err)
+
+	line := logLine(t, fault.NewValidatorFaultFromDecoder(err), 500)
+	faults, ok := line["faults"].([]any)
+	require.True(t, ok)
+	metadata, _ := faults[0].(map[string]any)["metadata"].(map[string]any)
+	unmarshall, _ := metadata["unmarshall"].(map[string]any)
+	assert.Equal(t, "INTERNAL_MARSHALING_ERROR", line["code"])
+	assert.Equal(t, "You provided a real object to the Decode function, expected a pointer", unmarshall["error_reason"])
+}
@@ -0,0 +1,61 @@
+package fault
+
+import (
+	"fmt"
+
+	"github.com/lambadass-2024/backend/internal/redact"
+)
+
+type RateLimitFault struct {
+	code     string
+	message  string
+	metadata map[string]any
+	cause    error
+	stack    Stack
+}
+
+func (e RateLimitFault) Code() string {
+	return e.code
+}
+
+func (RateLimitFault) Layer() Layer {
+	return Commands
+}
+
+func (RateLimitFault) Middleware() string {
+	return "RateLimit"
+}
+
+func (e RateLimitFault) Message() string {
+	return e.message
+}
+
+func (e RateLimitFault) Metadata() map[string]any {
+	return e.metadata
+}
+
+func (e RateLimitFault) Cause() error {
+	return e.cause
+}
+
+func (e RateLimitFault) Unwrap() error {
+	return e.cause
+}
+
+// Is tells if target is the Sentinel of the code of e
+func (e RateLimitFault) Is(target error) bool {
+	return is(e.code, target)
+}
+
+func (e RateLimitFault) StackTrace() Stack {
+	return e.stack
+}
+
+func (e RateLimitFault) Error() string {
+	return fmt.Sprintf("RateLimitFault [%v] : %v", e.code, e.message)
+}
+
+func NewRateLimit(code, message string, metadata map[string]any, cause error) Fault {
+	fault := RateLimitFault{code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
+	return &fault
+}
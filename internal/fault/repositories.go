@@ -3,8 +3,7 @@ package fault
 import (
 	"fmt"
 
-	"github.com/google/uuid"
-	"github.com/rs/zerolog"
+	"github.com/lambadass-2024/backend/internal/redact"
 )
 
 type RepositoryFault struct {
@@ -13,6 +12,7 @@ type RepositoryFault struct {
 	middleware string
 	metadata   map[string]any
 	cause      error
+	stack      Stack
 }
 
 func (e RepositoryFault) Code() string {
@@ -39,20 +39,24 @@ func (e RepositoryFault) Cause() error {
 	return e.cause
 }
 
+func (e RepositoryFault) Unwrap() error {
+	return e.cause
+}
+
+// Is tells if target is the Sentinel of the code of e
+func (e RepositoryFault) Is(target error) bool {
+	return is(e.code, target)
+}
+
+func (e RepositoryFault) StackTrace() Stack {
+	return e.stack
+}
+
 func (e RepositoryFault) Error() string {
 	return fmt.Sprintf("RepositoryFault [%v] : %v", e.code, e.message)
 }
 
-func NewRepository(logger *zerolog.Logger, middleware, code, message string, metadata map[string]any, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-	if metadata != nil {
-		if id1, exists := metadata["id"]; exists {
-			if id2, ok := id1.(uuid.UUID); ok {
-				e = e.Str("object_id", id2.String())
-			}
-		}
-	}
-	fault := RepositoryFault{middleware: middleware, code: code, message: message, metadata: metadata, cause: cause}
-	e.Err(&fault).Msg(message)
+func NewRepository(middleware, code, message string, metadata map[string]any, cause error) Fault {
+	fault := RepositoryFault{middleware: middleware, code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
 	return &fault
 }
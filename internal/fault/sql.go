@@ -2,9 +2,8 @@ package fault
 
 import (
 	"fmt"
-	"time"
 
-	"github.com/rs/zerolog"
+	"github.com/lambadass-2024/backend/internal/redact"
 )
 
 type SQLFault struct {
@@ -12,6 +11,7 @@ type SQLFault struct {
 	message  string
 	metadata map[string]any
 	cause    error
+	stack    Stack
 }
 
 func (e SQLFault) Code() string {
@@ -38,23 +38,24 @@ func (e SQLFault) Cause() error {
 	return e.cause
 }
 
+func (e SQLFault) Unwrap() error {
+	return e.cause
+}
+
+// Is tells if target is the Sentinel of the code of e
+func (e SQLFault) Is(target error) bool {
+	return is(e.code, target)
+}
+
+func (e SQLFault) StackTrace() Stack {
+	return e.stack
+}
+
 func (e SQLFault) Error() string {
 	return fmt.Sprintf("SqlFault [%v] : %v", e.code, e.message)
 }
 
-func NewSQL(logger *zerolog.Logger, code, message string, metadata map[string]any, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-	var msg string
-	if metadata != nil {
-		if dur, exists := metadata["duration"]; exists {
-			if d, ok := dur.(int64); ok {
-				e = e.Int64("duration", d)
-				durationObj := time.Duration(d) * time.Millisecond
-				msg = fmt.Sprintf("%v (%v)", message, durationObj.String())
-			}
-		}
-	}
-	fault := SQLFault{code: code, message: message, metadata: metadata, cause: cause}
-	e.Err(&fault).Msg(msg)
+func NewSQL(code, message string, metadata map[string]any, cause error) Fault {
+	fault := SQLFault{code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
 	return &fault
 }
@@ -0,0 +1,41 @@
+package fault
+
+import "runtime"
+
+const maxStackDepth = 32
+
+// Stack is the call stack where an error became a fault, see StackTracer
+type Stack []uintptr
+
+// StackTracer is a fault knowing where it was made. The faults of this package know it when their cause is an error
+// which is not a fault : the stack is where something unexpected entered the architecture. Faults made from other
+// faults, or without cause, have none, so that faults stay comparable values.
+type StackTracer interface {
+	StackTrace() Stack
+}
+
+// callers returns the stack of the caller of the constructor calling it, nil unless cause is an error but not a fault
+func callers(cause error) Stack {
+	if _, ok := cause.(Fault); ok || cause == nil {
+		return nil
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs) // runtime.Callers, callers and the constructor
+	return pcs[:n]
+}
+
+// Frames returns the frames of s, the innermost call first
+func (s Stack) Frames() []runtime.Frame {
+	if len(s) == 0 {
+		return nil
+	}
+	var frames []runtime.Frame
+	iterator := runtime.CallersFrames(s)
+	for {
+		frame, more := iterator.Next()
+		frames = append(frames, frame)
+		if !more {
+			return frames
+		}
+	}
+}
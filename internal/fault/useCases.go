@@ -3,8 +3,7 @@ package fault
 import (
 	"fmt"
 
-	"github.com/google/uuid"
-	"github.com/rs/zerolog"
+	"github.com/lambadass-2024/backend/internal/redact"
 )
 
 type UseCaseFault struct {
@@ -13,6 +12,7 @@ type UseCaseFault struct {
 	middleware string
 	metadata   map[string]any
 	cause      error
+	stack      Stack
 }
 
 func (u UseCaseFault) Code() string {
@@ -39,20 +39,24 @@ func (u UseCaseFault) Cause() error {
 	return u.cause
 }
 
+func (u UseCaseFault) Unwrap() error {
+	return u.cause
+}
+
+// Is tells if target is the Sentinel of the code of u
+func (u UseCaseFault) Is(target error) bool {
+	return is(u.code, target)
+}
+
+func (u UseCaseFault) StackTrace() Stack {
+	return u.stack
+}
+
 func (u UseCaseFault) Error() string {
 	return fmt.Sprintf("UseCaseFault [%v] : %v", u.code, u.message)
 }
 
-func NewUseCase(logger *zerolog.Logger, middleware, code, message string, metadata map[string]any, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-	if metadata != nil {
-		if id1, exists := metadata["id"]; exists {
-			if id2, ok := id1.(uuid.UUID); ok {
-				e = e.Str("object_id", id2.String())
-			}
-		}
-	}
-	fault := UseCaseFault{middleware: middleware, code: code, message: message, metadata: metadata, cause: cause}
-	e.Err(&fault).Msg(message)
+func NewUseCase(middleware, code, message string, metadata map[string]any, cause error) Fault {
+	fault := UseCaseFault{middleware: middleware, code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
 	return &fault
 }
@@ -6,7 +6,7 @@ import (
 	"strings"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/rs/zerolog"
+	"github.com/lambadass-2024/backend/internal/redact"
 )
 
 type ValidatorFault struct {
@@ -14,6 +14,7 @@ type ValidatorFault struct {
 	message  string
 	metadata map[string]any
 	cause    error
+	stack    Stack
 }
 
 type ValidationError struct {
@@ -50,20 +51,29 @@ func (u ValidatorFault) Cause() error {
 	return u.cause
 }
 
+func (u ValidatorFault) Unwrap() error {
+	return u.cause
+}
+
+// Is tells if target is the Sentinel of the code of u
+func (u ValidatorFault) Is(target error) bool {
+	return is(u.code, target)
+}
+
+func (u ValidatorFault) StackTrace() Stack {
+	return u.stack
+}
+
 func (u ValidatorFault) Error() string {
 	return fmt.Sprintf("ValidatorFault [%v] : %v", u.code, u.message)
 }
 
-func NewValidatorFault(logger *zerolog.Logger, code, message string, metadata map[string]any, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-	fault := ValidatorFault{code: code, message: message, metadata: metadata, cause: cause}
-	e.Err(&fault).Msg(message)
+func NewValidatorFault(code, message string, metadata map[string]any, cause error) Fault {
+	fault := ValidatorFault{code: code, message: message, metadata: redact.Metadata(metadata), cause: cause, stack: callers(cause)}
 	return &fault
 }
 
-func NewValidatorFaultFromStruct(logger *zerolog.Logger, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-
+func NewValidatorFaultFromStruct(cause error) Fault {
 	errs, ok := cause.(validator.ValidationErrors)
 	if ok {
 		ves := make([]ValidationError, len((errs)))
@@ -73,7 +83,7 @@ func NewValidatorFaultFromStruct(logger *zerolog.Logger, cause error) Fault {
 				Field:           err.Field(),
 				StructNamespace: err.StructNamespace(),
 				Tag:             err.Tag(),
-				Value:           err.Value(),
+				Value:           redact.Field(err.Field(), err.Value()),
 			}
 		}
 		fault := ValidatorFault{
@@ -82,21 +92,19 @@ func NewValidatorFaultFromStruct(logger *zerolog.Logger, cause error) Fault {
 			metadata: map[string]any{
 				"validation": ves,
 			}, cause: cause,
+			stack: callers(cause),
 		}
-		e.Err(&fault).Msg("Request body validation error")
 		return &fault
 	}
 
-	fault := ValidatorFault{code: "UNEXPECTED_INPUT_VALIDATION_ERROR", message: "Validation raised an unexpected error", metadata: nil, cause: cause}
-	e.Err(&fault).Msg("Request body validation error")
+	fault := ValidatorFault{code: "UNEXPECTED_INPUT_VALIDATION_ERROR", message: "Validation raised an unexpected error", metadata: nil, cause: cause, stack: callers(cause)}
 	return &fault
 }
 
-func NewValidatorFaultFromDecoder(logger *zerolog.Logger, cause error) Fault {
-	e := logger.Warn().AnErr("cause", cause)
-
+func NewValidatorFaultFromDecoder(cause error) Fault {
 	var code string
 	var message string
+	unmarshall := map[string]any{"message": cause.Error()}
 	switch {
 	case strings.Contains(cause.Error(), "json: unknown field"):
 		code = "UNKNOWN_FIELD"
@@ -116,19 +124,18 @@ func NewValidatorFaultFromDecoder(logger *zerolog.Logger, cause error) Fault {
 	case strings.Contains(cause.Error(), "json: Unmarshal(nil"):
 		code = "INTERNAL_MARSHALING_ERROR"
 		message = "Cannot unmarshall the provided JSON because of an internal error"
-		e.Str("error_reason", "You provided a nil pointer to the Decode function")
+		unmarshall["error_reason"] = "You provided a nil pointer to the Decode function"
 	case strings.Contains(cause.Error(), "json: Unmarshal(non-pointer"):
 		code = "INTERNAL_MARSHALING_ERROR"
 		message = "Cannot unmarshall the provided JSON because of an internal error"
-		e.Str("error_reason", "You provided a real object to the Decode function, expected a pointer")
+		unmarshall["error_reason"] = "You provided a real object to the Decode function, expected a pointer"
 	default:
 		code = "BAD_REQUEST"
 		message = "Cannot unmarshall the provided JSON"
 	}
 
-	fault := ValidatorFault{code: code, message: message, metadata: map[string]any{
-		"unmarshall": map[string]any{"message": cause.Error()},
-	}, cause: cause}
-	e.Err(&fault).Msg(message)
+	fault := ValidatorFault{code: code, message: message, metadata: redact.Metadata(map[string]any{
+		"unmarshall": unmarshall,
+	}), cause: cause, stack: callers(cause)}
 	return &fault
 }
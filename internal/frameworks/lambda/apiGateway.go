@@ -2,10 +2,14 @@ package lambda
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/lambadass-2024/backend/internal/fault"
+	baselogger "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
 	"github.com/rs/zerolog"
 )
 
@@ -15,6 +19,10 @@ import (
 
 type APIGatewayClient struct {
 	Lambda[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]
+	EnableETag         bool // OK adds an ETag header and answers 304 when it matches If-None-Match
+	DisableCompression bool // OnAfter will not compress responses according to Accept-Encoding
+	CompressionMinSize int  // Smaller bodies are not compressed, defaults to 1024 bytes
+	encoders           []Encoder
 }
 
 type HTTPResponseKOBody struct {
@@ -40,10 +48,15 @@ func (t APIGatewayClient) newErrorResponseBody(statusCode int, code, message str
 		}
 	}
 
+	if tc := trace.Current(); !tc.IsZero() {
+		additionalMetadata["traceId"] = tc.TraceID
+		additionalMetadata["correlationId"] = tc.RequestID
+	}
+
 	resObject := HTTPResponseKOBody{StatusCode: statusCode, Code: code, Message: message, Metadata: additionalMetadata}
 	resJSON, err := json.Marshal(resObject)
 	if err != nil {
-		return "", fault.NewAPIGateway(t.logger, 500, code, message,
+		return "", fault.NewAPIGateway(500, code, message,
 			map[string]any{"marshall": map[string]any{"message": err.Error()}}, nil)
 	}
 	return string(resJSON), nil
@@ -61,20 +74,31 @@ func (t APIGatewayClient) newErrorResponseBody(statusCode int, code, message str
 //		return trezer.OK(pet)
 //	}
 func (t APIGatewayClient) KO(statusCode int, code, message string, metadata map[string]any) (events.APIGatewayProxyResponse, fault.Fault) {
-	return events.APIGatewayProxyResponse{}, fault.NewAPIGateway(t.logger, statusCode, code, message, metadata, nil)
+	return events.APIGatewayProxyResponse{}, fault.NewAPIGateway(statusCode, code, message, metadata, nil)
 }
 
 // KOFromFault generate a (APIGatewayProxyResponse,fault.Fault) tuple for your lambda from any fault
 func (t APIGatewayClient) KOFromFault(statusCode int, flt fault.Fault) (events.APIGatewayProxyResponse, fault.Fault) {
-	return events.APIGatewayProxyResponse{}, fault.NewAPIGatewayFromFault(t.logger, statusCode, flt)
+	return events.APIGatewayProxyResponse{}, fault.NewAPIGatewayFromFault(statusCode, flt)
+}
+
+// KOFromCatalog generate a (APIGatewayProxyResponse,fault.Fault) tuple for your lambda from any fault,
+// with the status declared for its code in the fault catalog, see fault.Declare
+func (t APIGatewayClient) KOFromCatalog(flt fault.Fault) (events.APIGatewayProxyResponse, fault.Fault) {
+	return events.APIGatewayProxyResponse{}, fault.NewAPIGatewayFromCatalog(flt)
 }
 
 // KOFromValidatorFault generate a (APIGatewayProxyResponse,fault.Fault) tuple for your lambda from a validator fault
 func (t APIGatewayClient) KOFromValidatorFault(flt fault.Fault) (events.APIGatewayProxyResponse, fault.Fault) {
-	return events.APIGatewayProxyResponse{}, fault.NewAPIGatewayFromValidatorFault(t.logger, flt)
+	return events.APIGatewayProxyResponse{}, fault.NewAPIGatewayFromValidatorFault(flt)
 }
 
-// OK generate a APIGatewayProxyResponse for your lambda, marshaling your response object into JSON.
+// OK generate a APIGatewayProxyResponse for your lambda, marshaling your response object into JSON,
+// or into the format preferred by the Accept header of the request among the registered encoders (see RegisterEncoder).
+// If none of them is acceptable, the response is a 406.
+//
+// If EnableETag is set, the response has a strong ETag and is a 304 without body when the client already has it.
+// OnAfter adds the encoding to the ETag of a compressed body, an If-None-Match with either form gets the 304.
 //
 // Example :
 //
@@ -90,14 +114,44 @@ func (t APIGatewayClient) OK(obj any) (events.APIGatewayProxyResponse, fault.Fau
 		t.logger.Trace().Msg("Response object is nil (204)")
 		return events.APIGatewayProxyResponse{StatusCode: 204}, nil
 	}
-	resJSON, err := json.Marshal(obj)
+	encoder, flt := t.negotiateEncoder(obj)
+	if flt != nil {
+		return events.APIGatewayProxyResponse{}, flt
+	}
+	body, err := encoder.Encode(obj)
 	if err != nil {
+		code, message := "ERROR_ENCODING_RESPONSE", "Error while encoding an object to "+encoder.MediaType()
+		if encoder.MediaType() == mediaTypeJSON {
+			code, message = "ERROR_MARSHALL_JSON", "Error while marshaling an object to JSON"
+		}
 		return events.APIGatewayProxyResponse{},
-			fault.NewAPIGateway(t.logger, 500, "ERROR_MARSHALL_JSON", "Error while marshaling an object to JSON", map[string]any{
+			fault.NewAPIGateway(500, code, message, map[string]any{
 				"marshall": map[string]any{"message": err.Error()},
 			}, err)
 	}
-	return events.APIGatewayProxyResponse{StatusCode: 200, Body: string(resJSON)}, nil
+
+	response := events.APIGatewayProxyResponse{StatusCode: 200, Headers: map[string]string{
+		"Content-Type": encoder.MediaType(),
+		"Vary":         "Accept",
+	}}
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.IsBase64Encoded = true
+	}
+	if !t.EnableETag {
+		return response, nil
+	}
+
+	etag := newETag(obj, body, encoder.MediaType())
+	response.Headers["ETag"] = etag
+	if matched := t.notModified(etag); matched != "" {
+		t.logger.Trace().Str("etag", matched).Msg("Client already has this version (304)")
+		response.Headers["ETag"] = matched
+		return events.APIGatewayProxyResponse{StatusCode: 304, Headers: response.Headers}, nil
+	}
+	return response, nil
 }
 
 /******************************************************************************
@@ -111,16 +165,25 @@ func (t APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRe
 }
 
 // OnBefore is called before *each* API Gateway request is processed.
-func (t APIGatewayClient) OnBefore(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+func (t APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
 	t.logger.Trace().Msg("OnBefore")
+	route := request.Resource
+	if route == "" {
+		route = request.Path
+	}
+	trace.Root().SetName(request.HTTPMethod+" "+route).
+		SetAttribute("faas.trigger", "http").
+		SetAttribute("http.request.method", request.HTTPMethod).
+		SetAttribute("url.path", request.Path)
 	return nil
 }
 
 // OnAfter is called after *each* API Gateway response is generated.
+// A fault becomes the KO response it describes and is logged once, with its chain, at the level of its status code.
 func (t APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
 	t.logger.Trace().Msg("OnAfter")
 	if response == nil { // Impossible without changing Lambda::handleRequest
-		return fault.NewAPIGateway(t.logger, 500, "API_GATEWAY_NIL_RESPONSE", "APIGatewayClient::OnAfter received a nil response", nil, err)
+		return fault.NewAPIGateway(500, "API_GATEWAY_NIL_RESPONSE", "APIGatewayClient::OnAfter received a nil response", nil, err)
 	}
 
 	if response.Headers == nil {
@@ -128,25 +191,49 @@ func (t APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err
 	}
 	response.Headers["requestId"] = t.request.RequestContext.RequestID
 	response.Headers["requestTime"] = t.request.RequestContext.RequestTime
+	if tc := trace.Current(); !tc.IsZero() {
+		response.Headers[trace.HeaderRequestID] = tc.RequestID
+		response.Headers[trace.HeaderTraceparent] = tc.Traceparent()
+	}
 
 	if err != nil {
-		apigf, ok := err.(*fault.APIGatewayProxyFault)
-		if ok {
-			t.logger.Trace().Msg("Error type is an ApiGatewayFault")
-			res, _ := t.newErrorResponseBody(apigf.StatusCode, apigf.Code(), apigf.Message(), apigf.Metadata())
-			response.Body = res
-			response.StatusCode = apigf.StatusCode
-			return nil
+		t.setErrorResponse(response, err)
+		fault.Log(t.logger, err, response.StatusCode)
+		// The fault becomes a response and does not reach the logger, which still needs its code
+		baselogger.RecordFault(err.Code())
+		trace.Root().SetAttribute("fault.code", err.Code())
+		if response.StatusCode >= 500 { // The invocation failed, even if the fault became a response
+			trace.Root().Fail(err)
 		}
-		t.logger.Warn().Msg("Error type is a Fault but should be an ApiGatewayFault with a status code, so choosing 500 by default")
-		res, _ := t.newErrorResponseBody(500, err.Code(), err.Message(), err.Metadata())
-		response.Body = res
-		response.StatusCode = 500
-		return nil
 	}
+	trace.Root().SetAttribute("http.response.status_code", response.StatusCode)
+	t.compress(response)
 	return nil
 }
 
+// setErrorResponse turns response into the KO response describing err.
+// Faults other than APIGatewayProxyFaults get the status declared for their code in the fault catalog.
+func (t APIGatewayClient) setErrorResponse(response *events.APIGatewayProxyResponse, err fault.Fault) {
+	apigf, ok := err.(*fault.APIGatewayProxyFault)
+	if !ok {
+		t.logger.Trace().Msg("Error type is not an ApiGatewayFault, its status comes from the fault catalog")
+		apigf, _ = fault.NewAPIGatewayFromCatalog(err).(*fault.APIGatewayProxyFault)
+	}
+	for key, value := range apigf.Headers {
+		response.Headers[key] = value
+	}
+	res, _ := t.newErrorResponseBody(apigf.StatusCode, apigf.Code(), apigf.Message(), apigf.Metadata())
+	t.setErrorBody(response, apigf.StatusCode, res)
+}
+
+// setErrorBody replaces the body of response, which may have been encoded by OK in another format, with a JSON KO body
+func (APIGatewayClient) setErrorBody(response *events.APIGatewayProxyResponse, statusCode int, body string) {
+	response.Body = body
+	response.StatusCode = statusCode
+	response.IsBase64Encoded = false
+	response.Headers["Content-Type"] = mediaTypeJSON
+}
+
 // OnShutdown is called when the lambda is killed by AWS
 func (t APIGatewayClient) OnShutdown() {
 	t.logger.Trace().Msg("OnShutdown")
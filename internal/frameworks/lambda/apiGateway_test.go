@@ -1,9 +1,14 @@
 package lambda_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -11,6 +16,7 @@ import (
 	"github.com/lambadass-2024/backend/internal/commands/validator"
 	"github.com/lambadass-2024/backend/internal/fault"
 	"github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,6 +36,7 @@ type BodyToValidate struct {
 
 func NewAPIGateway() lambda.APIGatewayClient {
 	zerolog.SetGlobalLevel(zerolog.Disabled)
+	trace.Set(trace.Context{}) // The KO bodies have no trace metadata outside of a request
 	client := lambda.APIGatewayClient{
 		Lambda: lambda.TestNewLambda(&events.APIGatewayProxyRequest{RequestContext: apiGatewayProxyRequestContext}),
 	}
@@ -42,7 +49,6 @@ func NewAPIGateway() lambda.APIGatewayClient {
 
 func Test_APIGateway_KO_withMetadata(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
 	expectedStatusCode := 404
 	expectedCode := "PET_NOT_FOUND"
@@ -53,12 +59,11 @@ func Test_APIGateway_KO_withMetadata(t *testing.T) {
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, expectedStatusCode, expectedCode, expectedMessage, expectedMetadata, nil))
+	assert.Equal(t, err, fault.NewAPIGateway(expectedStatusCode, expectedCode, expectedMessage, expectedMetadata, nil))
 }
 
 func Test_APIGateway_KO_withoutMetadata(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
 	expectedStatusCode := 404
 	expectedCode := "PET_NOT_FOUND"
@@ -67,68 +72,63 @@ func Test_APIGateway_KO_withoutMetadata(t *testing.T) {
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, expectedStatusCode, expectedCode, expectedMessage, nil, nil))
+	assert.Equal(t, err, fault.NewAPIGateway(expectedStatusCode, expectedCode, expectedMessage, nil, nil))
 }
 
 func Test_APIGateway_KOFromFault_withMetadataAndError(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
 	metadata := metadataDefault
 
-	f1 := fault.NewUseCase(&logger, "DummyUseCase", "CODE1", "Code 1", metadata, errors.New("code 1"))
+	f1 := fault.NewUseCase("DummyUseCase", "CODE1", "Code 1", metadata, errors.New("code 1"))
 
 	response, err := apiGateway.KOFromFault(422, f1)
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, 422, f1.Code(), f1.Message(), f1.Metadata(), f1))
+	assert.Equal(t, err, fault.NewAPIGateway(422, f1.Code(), f1.Message(), f1.Metadata(), f1))
 }
 
 func Test_APIGateway_KOFromFault_withMetadataAndWithoutError(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
 	metadata := metadataDefault
 
-	f1 := fault.NewUseCase(&logger, "DummyUseCase", "CODE1", "Code 1", metadata, nil)
+	f1 := fault.NewUseCase("DummyUseCase", "CODE1", "Code 1", metadata, nil)
 
 	response, err := apiGateway.KOFromFault(422, f1)
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, 422, f1.Code(), f1.Message(), f1.Metadata(), f1))
+	assert.Equal(t, err, fault.NewAPIGateway(422, f1.Code(), f1.Message(), f1.Metadata(), f1))
 }
 
 func Test_APIGateway_KOFromFault_withoutMetadataWithError(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
-	f1 := fault.NewUseCase(&logger, "DummyUseCase", "CODE1", "Code 1", nil, errors.New("code 1"))
+	f1 := fault.NewUseCase("DummyUseCase", "CODE1", "Code 1", nil, errors.New("code 1"))
 
 	response, err := apiGateway.KOFromFault(422, f1)
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, 422, f1.Code(), f1.Message(), nil, f1))
+	assert.Equal(t, err, fault.NewAPIGateway(422, f1.Code(), f1.Message(), nil, f1))
 }
 
 func Test_APIGateway_KOFromFault_withoutMetadataWithoutError(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
-	f1 := fault.NewUseCase(&logger, "DummyUseCase2", "CODE2", "Code 2", nil, nil)
+	f1 := fault.NewUseCase("DummyUseCase2", "CODE2", "Code 2", nil, nil)
 
 	response, err := apiGateway.KOFromFault(422, f1)
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, 422, f1.Code(), f1.Message(), nil, f1))
+	assert.Equal(t, err, fault.NewAPIGateway(422, f1.Code(), f1.Message(), nil, f1))
 }
 
 func Test_APIGateway_KOFromValidatorFault(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 	v := validator.LambdaValidator[int, int]{}
 	_ = v.OnSetup(context.Background(), nil)
 	jsonString := "{\"id\": \"752cd6644267493eb8311d4587abf5b3\", \"name\":42}"
@@ -139,7 +139,7 @@ func Test_APIGateway_KOFromValidatorFault(t *testing.T) {
 	assert.NotNil(t, response)
 	assert.Equal(t, events.APIGatewayProxyResponse{}, response)
 	require.Error(t, err)
-	assert.Equal(t, err, fault.NewAPIGateway(&logger, 500, "UNEXPECTED_INPUT_VALIDATION_ERROR", "Validation raised an unexpected error", nil, f2))
+	assert.Equal(t, err, fault.NewAPIGateway(500, "UNEXPECTED_INPUT_VALIDATION_ERROR", "Validation raised an unexpected error", nil, f2))
 }
 
 /******************************************************************************
@@ -189,9 +189,8 @@ func Test_APIGateway_OK_BadObject(t *testing.T) {
 ******************************************************************************/
 func Test_APIGateway_OnAfter_ResponseNil(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
-	err := fault.NewAPIGateway(&logger, 500, "CODE", "Message", nil, nil)
+	err := fault.NewAPIGateway(500, "CODE", "Message", nil, nil)
 
 	err = apiGateway.OnAfter(nil, err)
 	require.Error(t, err)
@@ -212,10 +211,9 @@ func Test_APIGateway_OnAfter_Success(t *testing.T) {
 
 func Test_APIGateway_OnAfter_SuccessRaisedError(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
 	response := &events.APIGatewayProxyResponse{}
-	f1 := fault.NewUseCase(&logger, "DummyUseCase1", "ERROR1", "Error 1", nil, nil)
+	f1 := fault.NewUseCase("DummyUseCase1", "ERROR1", "Error 1", nil, nil)
 
 	expectedBody := "{\"statusCode\":500,\"code\":\"ERROR1\",\"message\":\"Error 1\",\"metadata\":{\"requestId\":\"123\",\"requestTime\":\"time\"}}"
 
@@ -228,10 +226,9 @@ func Test_APIGateway_OnAfter_SuccessRaisedError(t *testing.T) {
 
 func Test_APIGateway_OnAfter_SuccessRaisedAPIGatewayProxyFaultError(t *testing.T) {
 	apiGateway := NewAPIGateway()
-	logger := zerolog.Logger{}
 
 	response := &events.APIGatewayProxyResponse{}
-	f1 := fault.NewAPIGateway(&logger, 422, "ERROR1", "Error 1", nil, nil)
+	f1 := fault.NewAPIGateway(422, "ERROR1", "Error 1", nil, nil)
 
 	expectedBody := "{\"statusCode\":422,\"code\":\"ERROR1\",\"message\":\"Error 1\",\"metadata\":{\"requestId\":\"123\",\"requestTime\":\"time\"}}"
 
@@ -241,3 +238,208 @@ func Test_APIGateway_OnAfter_SuccessRaisedAPIGatewayProxyFaultError(t *testing.T
 	assert.Equal(t, 422, response.StatusCode)
 	assert.Equal(t, expectedBody, response.Body)
 }
+
+func Test_APIGateway_OnAfter_FaultHeaders(t *testing.T) {
+	apiGateway := NewAPIGateway()
+
+	response := &events.APIGatewayProxyResponse{}
+	f1 := fault.NewAPIGatewayWithHeaders(429, "TOO_MANY_REQUESTS", "Too many", map[string]string{"Retry-After": "30"}, nil, nil)
+
+	err2 := apiGateway.OnAfter(response, f1)
+	require.NoError(t, err2)
+
+	assert.Equal(t, 429, response.StatusCode)
+	assert.Equal(t, "30", response.Headers["Retry-After"])
+	assert.Equal(t, "123", response.Headers["requestId"])
+}
+
+func Test_APIGateway_OnAfter_ValidatorFault(t *testing.T) {
+	apiGateway := NewAPIGateway()
+
+	response := &events.APIGatewayProxyResponse{}
+	err := apiGateway.OnAfter(response, fault.NewValidatorFault("BAD_REQUEST", "Validation failed", nil, nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Contains(t, response.Body, `"code":"BAD_REQUEST"`)
+}
+
+func Test_APIGateway_OnAfter_CatalogFault(t *testing.T) {
+	apiGateway := NewAPIGateway()
+
+	response := &events.APIGatewayProxyResponse{}
+	err := apiGateway.OnAfter(response, fault.NewUseCase("PetUseCase", "PET_NOT_FOUND", "", nil, nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, 404, response.StatusCode)
+	assert.Contains(t, response.Body, `"code":"PET_NOT_FOUND","message":"Pet not found"`)
+}
+
+func Test_APIGateway_KOFromCatalog(t *testing.T) {
+	apiGateway := NewAPIGateway()
+
+	_, err := apiGateway.KOFromCatalog(fault.NewUseCase("PetUseCase", "PET_ID_NOT_UNIQUE", "Pet id not unique", nil, nil))
+	assert.Equal(t, 422, fault.StatusCode(err))
+	_, err = apiGateway.KOFromCatalog(fault.NewUseCase("PetUseCase", "UNDECLARED", "Undeclared", nil, nil))
+	assert.Equal(t, 500, fault.StatusCode(err))
+}
+
+/******************************************************************************
+***** ETag
+******************************************************************************/
+
+func NewAPIGatewayWithHeaders(headers map[string]string) lambda.APIGatewayClient {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	return lambda.APIGatewayClient{
+		Lambda:     lambda.TestNewLambda(&events.APIGatewayProxyRequest{RequestContext: apiGatewayProxyRequestContext, Headers: headers}),
+		EnableETag: true,
+	}
+}
+
+func Test_APIGateway_OK_ETag(t *testing.T) {
+	apiGateway := NewAPIGatewayWithHeaders(nil)
+
+	response, err := apiGateway.OK(metadataDefault)
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	etag := response.Headers["ETag"]
+	assert.Len(t, etag, 34)
+	again, _ := apiGateway.OK(map[string]any{"key": "value"})
+	assert.Equal(t, etag, again.Headers["ETag"])
+}
+
+func Test_APIGateway_OK_IfNoneMatch(t *testing.T) {
+	sent, _ := NewAPIGatewayWithHeaders(nil).OK(metadataDefault)
+	etag := sent.Headers["ETag"]
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"if-none-match": `"other", W/` + etag})
+
+	response, err := apiGateway.OK(metadataDefault)
+	require.NoError(t, err)
+	assert.Equal(t, 304, response.StatusCode)
+	assert.Empty(t, response.Body)
+	assert.Equal(t, etag, response.Headers["ETag"])
+}
+
+func Test_APIGateway_OK_IfNoneMatchOutdated(t *testing.T) {
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"If-None-Match": `"outdated"`})
+
+	response, err := apiGateway.OK(metadataDefault)
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotEmpty(t, response.Body)
+}
+
+func Test_APIGateway_CheckIfMatch(t *testing.T) {
+	etag, err := NewAPIGatewayWithHeaders(nil).ETagOf(metadataDefault)
+	require.NoError(t, err)
+	sent, _ := NewAPIGatewayWithHeaders(nil).OK(metadataDefault)
+	assert.Equal(t, sent.Headers["ETag"], etag)
+
+	assert.NoError(t, NewAPIGatewayWithHeaders(nil).CheckIfMatch(etag))
+	assert.NoError(t, NewAPIGatewayWithHeaders(map[string]string{"If-Match": etag}).CheckIfMatch(etag))
+	assert.NoError(t, NewAPIGatewayWithHeaders(map[string]string{"if-match": `"other", ` + encodedForTest(etag)}).CheckIfMatch(etag))
+	assert.NoError(t, NewAPIGatewayWithHeaders(map[string]string{"If-Match": "*"}).CheckIfMatch(etag))
+
+	for _, stale := range []string{`"stale"`, "W/" + etag} { // If-Match uses the strong comparison
+		err := NewAPIGatewayWithHeaders(map[string]string{"If-Match": stale}).CheckIfMatch(etag)
+		require.Error(t, err, stale)
+		apigf, ok := err.(*fault.APIGatewayProxyFault)
+		require.True(t, ok)
+		assert.Equal(t, 412, apigf.StatusCode)
+		assert.Equal(t, "PRECONDITION_FAILED", apigf.Code())
+		assert.Equal(t, etag, apigf.Headers["ETag"])
+	}
+}
+
+// encodedForTest returns etag as sent with a gzip body
+func encodedForTest(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-gzip"`
+}
+
+/******************************************************************************
+***** Compression
+******************************************************************************/
+
+func Test_APIGateway_OnAfter_Gzip(t *testing.T) {
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"Accept-Encoding": "deflate;q=0.5, gzip, br;q=0"})
+	body := strings.Repeat("hello test ", 200)
+
+	response := &events.APIGatewayProxyResponse{StatusCode: 200, Body: body}
+	require.NoError(t, apiGateway.OnAfter(response, nil))
+
+	assert.True(t, response.IsBase64Encoded)
+	assert.Equal(t, "gzip", response.Headers["Content-Encoding"])
+	assert.Equal(t, "Accept-Encoding", response.Headers["Vary"])
+
+	compressed, err := base64.StdEncoding.DecodeString(response.Body)
+	require.NoError(t, err)
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decompressed))
+}
+
+func Test_APIGateway_OnAfter_GzipETag(t *testing.T) {
+	pet := map[string]any{"name": strings.Repeat("Rex ", 500)}
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"Accept-Encoding": "gzip"})
+
+	response, err := apiGateway.OK(pet)
+	require.NoError(t, err)
+	etag := response.Headers["ETag"]
+	require.NoError(t, apiGateway.OnAfter(&response, nil))
+	assert.Equal(t, "gzip", response.Headers["Content-Encoding"])
+	assert.Equal(t, strings.TrimSuffix(etag, `"`)+`-gzip"`, response.Headers["ETag"])
+
+	apiGateway = NewAPIGatewayWithHeaders(map[string]string{"Accept-Encoding": "gzip", "If-None-Match": response.Headers["ETag"]})
+	notModified, err := apiGateway.OK(pet)
+	require.NoError(t, err)
+	require.NoError(t, apiGateway.OnAfter(&notModified, nil))
+	assert.Equal(t, 304, notModified.StatusCode)
+	assert.Equal(t, response.Headers["ETag"], notModified.Headers["ETag"])
+}
+
+func Test_APIGateway_OnAfter_CompressionSkipped(t *testing.T) {
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"Accept-Encoding": "gzip"})
+
+	small := &events.APIGatewayProxyResponse{StatusCode: 200, Body: "hello test"}
+	require.NoError(t, apiGateway.OnAfter(small, nil))
+	assert.False(t, small.IsBase64Encoded)
+	assert.Equal(t, "hello test", small.Body)
+	assert.NotContains(t, small.Headers, "Vary")
+
+	image, _ := apiGateway.OKBinary(bytes.Repeat([]byte{42}, 2048), "image/png")
+	require.NoError(t, apiGateway.OnAfter(&image, nil))
+	assert.True(t, image.IsBase64Encoded)
+	assert.NotContains(t, image.Headers, "Content-Encoding")
+	assert.Equal(t, "image/png", image.Headers["Content-Type"])
+
+	notAccepted := NewAPIGatewayWithHeaders(map[string]string{"Accept-Encoding": "identity"})
+	large := &events.APIGatewayProxyResponse{StatusCode: 200, Body: strings.Repeat("hello test ", 200)}
+	require.NoError(t, notAccepted.OnAfter(large, nil))
+	assert.False(t, large.IsBase64Encoded)
+}
+
+/******************************************************************************
+***** Trace
+******************************************************************************/
+
+func Test_APIGateway_OnAfter_Trace(t *testing.T) {
+	defer trace.Set(trace.Context{})
+	apiGateway := NewAPIGateway()
+	tc := trace.Start(map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "X-Request-Id": "abc-123"})
+
+	response := &events.APIGatewayProxyResponse{}
+	err := apiGateway.OnAfter(response, fault.NewAPIGateway(404, "PET_NOT_FOUND", "Cannot find your pet", nil, nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, "123", response.Headers["requestId"])
+	assert.Equal(t, "abc-123", response.Headers["X-Request-Id"])
+	assert.Equal(t, tc.Traceparent(), response.Headers["traceparent"])
+
+	var body lambda.HTTPResponseKOBody
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &body))
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", body.Metadata["traceId"])
+	assert.Equal(t, "abc-123", body.Metadata["correlationId"])
+}
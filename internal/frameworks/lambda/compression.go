@@ -0,0 +1,147 @@
+package lambda
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+// Bodies smaller than this are not worth compressing, the headers would cost more than the savings
+const defaultCompressionMinSize = 1024
+
+// Supported encodings, by order of preference when the client accepts several with the same weight
+var encoders = []struct {
+	name     string
+	newWrite func(io.Writer) io.WriteCloser
+}{
+	{"br", func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }},
+	{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+	{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// OKBinary generate a APIGatewayProxyResponse for your lambda with a binary body (image, CSV file...).
+//
+// Example :
+//
+//	func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, fault.Fault) {
+//		photo, err := photoRepository.find(request.PathParameters["id"])
+//		if err != nil {
+//			return trezer.KO(404, "PHOTO_NOT_FOUND", "Cannot find your photo", nil)
+//		}
+//		return trezer.OKBinary(photo.Content, "image/jpeg")
+//	}
+func (APIGatewayClient) OKBinary(body []byte, contentType string) (events.APIGatewayProxyResponse, fault.Fault) {
+	return events.APIGatewayProxyResponse{
+		StatusCode:      200,
+		Headers:         map[string]string{"Content-Type": contentType},
+		Body:            base64.StdEncoding.EncodeToString(body),
+		IsBase64Encoded: true,
+	}, nil
+}
+
+// compress encodes the body of response with the best encoding accepted by the client, if it is worth it
+func (t APIGatewayClient) compress(response *events.APIGatewayProxyResponse) {
+	if t.DisableCompression || response.Body == "" || response.Headers["Content-Encoding"] != "" ||
+		!compressible(response.Headers["Content-Type"]) {
+		return
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			t.logger.Warn().Err(err).Msg("Response body is not valid base64, not compressing it")
+			return
+		}
+		body = decoded
+	}
+
+	minSize := t.CompressionMinSize
+	if minSize == 0 {
+		minSize = defaultCompressionMinSize
+	}
+	if len(body) < minSize {
+		return
+	}
+
+	// Whether this body is compressed depends on Accept-Encoding from here, even if the client accepts none
+	MergeHeader(response.Headers, "Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(Header(t.request, "Accept-Encoding"))
+	if encoding < 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	w := encoders[encoding].newWrite(&buf)
+	if _, err := w.Write(body); err != nil {
+		t.logger.Warn().Err(err).Msg("Cannot compress response body")
+		return
+	}
+	if err := w.Close(); err != nil {
+		t.logger.Warn().Err(err).Msg("Cannot compress response body")
+		return
+	}
+
+	t.logger.Trace().Str("encoding", encoders[encoding].name).Int("from", len(body)).Int("to", buf.Len()).Msg("Compressed response body")
+	response.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	response.IsBase64Encoded = true
+	response.Headers["Content-Encoding"] = encoders[encoding].name
+	if etag, exists := response.Headers["ETag"]; exists {
+		response.Headers["ETag"] = encodedETag(etag, encoders[encoding].name)
+	}
+}
+
+// compressible tells if a body of this content type would shrink. An empty content type is JSON.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"), strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson", "application/msgpack", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// negotiateEncoding returns the index in encoders of the preferred encoding of an Accept-Encoding header, -1 if none
+func negotiateEncoding(acceptEncoding string) int {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if w, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = w
+			}
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best, bestWeight := -1, 0.0
+	for i, encoder := range encoders {
+		weight, exists := weights[encoder.name]
+		if !exists {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = i, weight
+		}
+	}
+	return best
+}
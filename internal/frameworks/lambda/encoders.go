@@ -0,0 +1,365 @@
+package lambda
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+const mediaTypeJSON = "application/json"
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Encoder turns the object given to OK into a response body of its media type.
+// Bodies that are not valid UTF-8 are sent base64 encoded.
+type Encoder interface {
+	MediaType() string
+	Encode(obj any) ([]byte, error)
+}
+
+// PartialEncoder can be implemented by encoders which cannot encode every object. The negotiation skips them for
+// the objects they cannot encode, as if their media type was not registered.
+type PartialEncoder interface {
+	Encoder
+	CanEncode(obj any) bool
+}
+
+// JSONEncoder is the default encoder, used when the client has no preference
+type JSONEncoder struct{}
+
+// NDJSONEncoder writes one JSON document per line for each element of a slice
+type NDJSONEncoder struct{}
+
+// CSVEncoder writes a struct or a slice of structs as a CSV file with a header line.
+//
+// Column names come from the csv tag of the fields, from their json tag otherwise.
+// Nested structs and pointers to structs are flattened with dotted names (race.id, race.name...), the columns of a nil
+// pointer being empty. Other objects cannot be encoded, the negotiation picks another encoder for them.
+type CSVEncoder struct{}
+
+/******************************************************************************
+***** Registry
+******************************************************************************/
+
+// DefaultEncoders returns the encoders used by APIGatewayClient when none are registered, JSON first.
+func DefaultEncoders() []Encoder {
+	return []Encoder{JSONEncoder{}, NDJSONEncoder{}, CSVEncoder{}, MessagePackEncoder{}}
+}
+
+// RegisterEncoder adds an encoder OK can pick through the Accept header, on top of the default ones.
+// An encoder registered for an existing media type replaces it.
+//
+// Example :
+//
+//	Lambda = lambdaframework.APIGatewayClient{}
+//	Lambda.RegisterEncoder(XMLEncoder{})
+func (t *APIGatewayClient) RegisterEncoder(encoder Encoder) *APIGatewayClient {
+	if t.encoders == nil {
+		t.encoders = DefaultEncoders()
+	}
+	for i, e := range t.encoders {
+		if e.MediaType() == encoder.MediaType() {
+			t.encoders[i] = encoder
+			return t
+		}
+	}
+	t.encoders = append(t.encoders, encoder)
+	return t
+}
+
+func (t APIGatewayClient) registeredEncoders() []Encoder {
+	if t.encoders == nil {
+		return DefaultEncoders()
+	}
+	return t.encoders
+}
+
+// negotiateEncoder picks the encoder preferred by the Accept header of the request among the ones which can encode obj,
+// or a 406 fault if none is acceptable
+func (t APIGatewayClient) negotiateEncoder(obj any) (Encoder, fault.Fault) {
+	encoders := []Encoder{}
+	for _, encoder := range t.registeredEncoders() {
+		if partial, ok := encoder.(PartialEncoder); !ok || partial.CanEncode(obj) {
+			encoders = append(encoders, encoder)
+		}
+	}
+	accept := Header(t.request, "Accept")
+	if strings.TrimSpace(accept) == "" && len(encoders) > 0 {
+		return encoders[0], nil
+	}
+
+	ranges := parseAccept(accept)
+	var best Encoder
+	bestWeight := 0.0
+	for _, encoder := range encoders {
+		if weight := acceptWeight(ranges, encoder.MediaType()); weight > bestWeight {
+			best, bestWeight = encoder, weight
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	available := make([]string, len(encoders))
+	for i, encoder := range encoders {
+		available[i] = encoder.MediaType()
+	}
+	return nil, fault.NewAPIGateway(406, "NOT_ACCEPTABLE", "None of the accepted media types can be produced", map[string]any{
+		"accept": accept, "available": available,
+	}, nil)
+}
+
+type mediaRange struct {
+	mediaType string
+	weight    float64
+}
+
+// parseAccept reads the media ranges and their weight of an Accept header
+func parseAccept(accept string) []mediaRange {
+	parts := strings.Split(accept, ",")
+	ranges := make([]mediaRange, 0, len(parts))
+	for _, part := range parts {
+		params := strings.Split(part, ";")
+		r := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), weight: 1}
+		for _, param := range params[1:] {
+			if q, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if w, err := strconv.ParseFloat(q, 64); err == nil {
+					r.weight = w
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// acceptWeight returns the weight of the most specific media range matching mediaType, 0 if none.
+// Vendor media types with a structured syntax suffix match their suffix, application/vnd.lambadass+json matching application/json.
+func acceptWeight(ranges []mediaRange, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	weight, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.mediaType == mediaType:
+			s = 3
+		case structuredSyntax(r.mediaType) == mediaType:
+			s = 2
+		case r.mediaType == mainType+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			weight, specificity = r.weight, s
+		}
+	}
+	return weight
+}
+
+// structuredSyntax returns application/json for application/vnd.lambadass+json, "" for media types without suffix
+func structuredSyntax(mediaType string) string {
+	mainType, subType, _ := strings.Cut(mediaType, "/")
+	if _, suffix, found := strings.Cut(subType, "+"); found && suffix != "" {
+		return mainType + "/" + suffix
+	}
+	return ""
+}
+
+/******************************************************************************
+***** JSON
+******************************************************************************/
+
+func (JSONEncoder) MediaType() string {
+	return mediaTypeJSON
+}
+
+func (JSONEncoder) Encode(obj any) ([]byte, error) {
+	return json.Marshal(obj)
+}
+
+/******************************************************************************
+***** NDJSON
+******************************************************************************/
+
+func (NDJSONEncoder) MediaType() string {
+	return "application/x-ndjson"
+}
+
+func (NDJSONEncoder) Encode(obj any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf) // Encode adds a new line after each document
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		err := encoder.Encode(obj)
+		return buf.Bytes(), err
+	}
+	for i := range val.Len() {
+		if err := encoder.Encode(val.Index(i).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+/******************************************************************************
+***** CSV
+******************************************************************************/
+
+func (CSVEncoder) MediaType() string {
+	return "text/csv"
+}
+
+// CanEncode tells if obj is a struct or a slice of structs, or a pointer to one of them
+func (CSVEncoder) CanEncode(obj any) bool {
+	val := reflect.Indirect(reflect.ValueOf(obj))
+	return val.IsValid() && csvRowType(val.Type()).Kind() == reflect.Struct
+}
+
+func (CSVEncoder) Encode(obj any) ([]byte, error) {
+	val := reflect.Indirect(reflect.ValueOf(obj))
+	if !val.IsValid() {
+		return nil, errors.New("csv: cannot encode a nil pointer")
+	}
+	rows := []reflect.Value{val}
+	if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+		rows = make([]reflect.Value, val.Len())
+		for i := range val.Len() {
+			rows[i] = reflect.Indirect(val.Index(i))
+		}
+	}
+
+	elemType := csvRowType(val.Type())
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: cannot encode %v, expected a struct or a slice of structs", elemType)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := csvHeader(elemType, "")
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		record := make([]string, len(header)) // nil elements are empty lines
+		if row.IsValid() {
+			record = csvRecord(row)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvRowType returns the type of the rows of a value of type t, the type of its elements for a slice or an array
+func csvRowType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// csvColumnName returns the column name of a field from its csv or json tag, "" if the field is skipped
+func csvColumnName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	tag := field.Tag.Get("csv")
+	if tag == "" {
+		tag = field.Tag.Get("json")
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// csvFlattened tells if a field of this type, a struct or a pointer to a struct, is written as several columns
+func csvFlattened(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	textMarshaler := reflect.TypeFor[encoding.TextMarshaler]()
+	return t.Kind() == reflect.Struct && !t.Implements(textMarshaler) && !reflect.PointerTo(t).Implements(textMarshaler)
+}
+
+func csvHeader(t reflect.Type, prefix string) []string {
+	header := []string{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := csvColumnName(field)
+		if name == "" {
+			continue
+		}
+		if csvFlattened(field.Type) {
+			header = append(header, csvHeader(csvRowType(field.Type), prefix+name+".")...)
+			continue
+		}
+		header = append(header, prefix+name)
+	}
+	return header
+}
+
+func csvRecord(val reflect.Value) []string {
+	record := []string{}
+	for i := range val.NumField() {
+		field := val.Type().Field(i)
+		if csvColumnName(field) == "" {
+			continue
+		}
+		if csvFlattened(field.Type) {
+			record = append(record, csvFlattenedRecord(val.Field(i))...)
+			continue
+		}
+		record = append(record, csvValue(val.Field(i)))
+	}
+	return record
+}
+
+// csvFlattenedRecord returns the columns of a struct, or of a pointer to a struct which are empty if it is nil
+func csvFlattenedRecord(val reflect.Value) []string {
+	if val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return make([]string, len(csvHeader(val.Type().Elem(), "")))
+		}
+		val = val.Elem()
+	}
+	return csvRecord(val)
+}
+
+func csvValue(val reflect.Value) string {
+	if val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return ""
+		}
+		val = val.Elem()
+	}
+	if m, ok := val.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(val.Interface())
+}
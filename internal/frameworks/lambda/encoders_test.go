@@ -0,0 +1,130 @@
+package lambda_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/entities"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pets = []entities.Pet{
+	{ID: uuid.MustParse("752cd6644267493eb8311d4587abf5b3"), Name: "bang", Race: entities.Race{ID: uuid.MustParse("752cd6644267493eb8311d4587abf000"), Name: "Cat"}},
+	{ID: uuid.MustParse("752cd6644267493eb8311d4587abf5b4"), Name: "a, \"b\"", Race: entities.Race{ID: uuid.MustParse("752cd6644267493eb8311d4587abf000")}},
+}
+
+func okWithAccept(t *testing.T, accept string, obj any) (events.APIGatewayProxyResponse, fault.Fault) {
+	t.Helper()
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"Accept": accept})
+	apiGateway.EnableETag = false
+	return apiGateway.OK(obj)
+}
+
+/******************************************************************************
+***** Negotiation
+******************************************************************************/
+
+func Test_Encoders_DefaultJSON(t *testing.T) {
+	for _, accept := range []string{"", "*/*", "application/*", "text/html;q=0.9, application/json", "application/vnd.lambadass+json;version=2"} {
+		response, err := okWithAccept(t, accept, metadataDefault)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", response.Headers["Content-Type"], accept)
+		assert.Equal(t, `{"key":"value"}`, response.Body, accept)
+	}
+}
+
+func Test_Encoders_NotAcceptable(t *testing.T) {
+	_, err := okWithAccept(t, "text/html, application/json;q=0", metadataDefault)
+	require.Error(t, err)
+
+	apigf, ok := err.(*fault.APIGatewayProxyFault)
+	require.True(t, ok)
+	assert.Equal(t, 406, apigf.StatusCode)
+	assert.Equal(t, "NOT_ACCEPTABLE", apigf.Code())
+}
+
+type textEncoder struct{}
+
+func (textEncoder) MediaType() string            { return "text/plain" }
+func (textEncoder) Encode(_ any) ([]byte, error) { return []byte("plain"), nil }
+
+func Test_Encoders_Register(t *testing.T) {
+	apiGateway := NewAPIGatewayWithHeaders(map[string]string{"Accept": "text/plain"})
+	apiGateway.RegisterEncoder(textEncoder{})
+
+	response, err := apiGateway.OK(metadataDefault)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", response.Headers["Content-Type"])
+	assert.Equal(t, "plain", response.Body)
+}
+
+/******************************************************************************
+***** Formats
+******************************************************************************/
+
+func Test_Encoders_CSV(t *testing.T) {
+	response, err := okWithAccept(t, "text/csv", pets)
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv", response.Headers["Content-Type"])
+	assert.Equal(t, "id,name,race.id,race.name\n"+
+		"752cd664-4267-493e-b831-1d4587abf5b3,bang,752cd664-4267-493e-b831-1d4587abf000,Cat\n"+
+		"752cd664-4267-493e-b831-1d4587abf5b4,\"a, \"\"b\"\"\",752cd664-4267-493e-b831-1d4587abf000,\n", response.Body)
+
+	_, err = okWithAccept(t, "text/csv", []int{1, 2})
+	require.Error(t, err)
+	assert.Equal(t, "NOT_ACCEPTABLE", err.Code(), "No encoder of the Accept header can encode the value")
+
+	response, err = okWithAccept(t, "text/csv, application/json;q=0.5", []int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", response.Headers["Content-Type"], "The encoders which cannot encode the value are skipped")
+}
+
+type owner struct {
+	Name string        `json:"name"`
+	Pet  *entities.Pet `json:"pet"`
+}
+
+func Test_Encoders_CSVPointers(t *testing.T) {
+	response, err := okWithAccept(t, "text/csv", []owner{{Name: "Ann", Pet: &pets[0]}, {Name: "Bob"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name,pet.id,pet.name,pet.race.id,pet.race.name\n"+
+		"Ann,752cd664-4267-493e-b831-1d4587abf5b3,bang,752cd664-4267-493e-b831-1d4587abf000,Cat\n"+
+		"Bob,,,,\n", response.Body)
+}
+
+func Test_Encoders_NDJSON(t *testing.T) {
+	response, err := okWithAccept(t, "application/x-ndjson", pets[:1])
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"752cd664-4267-493e-b831-1d4587abf5b3\",\"name\":\"bang\",\"race\":{\"id\":\"752cd664-4267-493e-b831-1d4587abf000\",\"name\":\"Cat\"}}\n",
+		response.Body)
+}
+
+func Test_Encoders_MessagePack(t *testing.T) {
+	response, err := okWithAccept(t, "application/msgpack", map[string]any{"a": 1, "b": []any{true, nil, -2, 1.5}})
+	require.NoError(t, err)
+	assert.Equal(t, "application/msgpack", response.Headers["Content-Type"])
+
+	expected := []byte{
+		0x82,
+		0xa1, 'a', 0x01,
+		0xa1, 'b', 0x94, 0xc3, 0xc0,
+		0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+		0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	assert.True(t, response.IsBase64Encoded)
+	body, err2 := base64.StdEncoding.DecodeString(response.Body)
+	require.NoError(t, err2)
+	assert.Equal(t, expected, body)
+}
+
+func Test_Encoders_MessagePackLongString(t *testing.T) {
+	body, err := lambda.MessagePackEncoder{}.Encode("0123456789abcdefghijklmnopqrstuvwxyz")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xdb, 0x00, 0x00, 0x00, 36}, body[:5])
+	assert.Len(t, body, 41)
+}
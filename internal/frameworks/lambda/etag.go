@@ -0,0 +1,118 @@
+package lambda
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+// Length of the hash used in ETags, 128 bits are enough to avoid collisions between versions of a resource
+const etagHashLength = 16
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Versioned can be implemented by response objects knowing their own version (revision, update date...).
+// Their ETag is then derived from Version and the media type of the response instead of the hash of the body.
+type Versioned interface {
+	Version() string
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// newETag returns a strong ETag for obj, from its version if it has one, from the hash of body otherwise
+func newETag(obj any, body []byte, mediaType string) string {
+	if v, ok := obj.(Versioned); ok {
+		body = []byte(v.Version() + ";" + mediaType)
+	}
+	hash := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(hash[:etagHashLength]) + `"`
+}
+
+// ETagOf returns the ETag OK would send for obj as JSON. Use it in mutating handlers with CheckIfMatch.
+func (t APIGatewayClient) ETagOf(obj any) (string, fault.Fault) {
+	body, err := JSONEncoder{}.Encode(obj)
+	if err != nil {
+		return "", fault.NewAPIGateway(500, "ERROR_MARSHALL_JSON", "Error while marshaling an object to JSON", map[string]any{
+			"marshall": map[string]any{"message": err.Error()},
+		}, err)
+	}
+	return newETag(obj, body, mediaTypeJSON), nil
+}
+
+// CheckIfMatch returns a 412 fault if the request has an If-Match header not matching currentETag,
+// meaning the client is about to modify a resource that changed since it read it.
+//
+// Example :
+//
+//	pet, err := PetUseCase.GetForUpdate(id)
+//	...
+//	etag, err := Lambda.ETagOf(pet)
+//	...
+//	if err := Lambda.CheckIfMatch(etag); err != nil {
+//		return events.APIGatewayProxyResponse{}, err
+//	}
+func (t APIGatewayClient) CheckIfMatch(currentETag string) fault.Fault {
+	ifMatch := Header(t.request, "If-Match")
+	if ifMatch == "" || etagListMatch(ifMatch, currentETag, false) != "" {
+		return nil
+	}
+	return fault.NewAPIGatewayWithHeaders(412, "PRECONDITION_FAILED", "The resource has been modified since you read it",
+		map[string]string{"ETag": currentETag}, map[string]any{"etag": currentETag}, nil)
+}
+
+// notModified returns the ETag of the If-None-Match header of the request matching etag, "" if none does.
+// It is the one the client has, with the suffix of its encoding if the body it got was compressed.
+func (t APIGatewayClient) notModified(etag string) string {
+	ifNoneMatch := Header(t.request, "If-None-Match")
+	if ifNoneMatch == "" {
+		return ""
+	}
+	return etagListMatch(ifNoneMatch, etag, true)
+}
+
+// etagListMatch returns the ETag of the comma separated list of a If-Match or If-None-Match header matching etag,
+// "" if none does. If-None-Match uses the weak comparison (W/ prefixes are ignored), If-Match the strong one.
+// ETags with the suffix added by the compression match too, the compressed body being the same version of the resource.
+func etagListMatch(list, etag string, weak bool) string {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return etag
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag || withoutEncoding(candidate) == etag {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// encodedETag returns the ETag of the body of etag compressed with encoding, so that caches never take
+// the compressed body for the identity one : "abc" becomes "abc-gzip"
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// withoutEncoding removes the suffix encodedETag added to etag, if any
+func withoutEncoding(etag string) string {
+	for _, encoder := range encoders {
+		if trimmed, found := strings.CutSuffix(etag, "-"+encoder.name+`"`); found {
+			return trimmed + `"`
+		}
+	}
+	return etag
+}
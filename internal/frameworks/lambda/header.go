@@ -0,0 +1,46 @@
+package lambda
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Header returns the value of the request header name, ignoring its case as API Gateway
+// forwards headers the way the client wrote them. It returns "" if the header is missing.
+func Header(request *events.APIGatewayProxyRequest, name string) string {
+	if request == nil {
+		return ""
+	}
+	if value, exists := request.Headers[name]; exists {
+		return value
+	}
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// MergeHeader adds value to the comma separated list of the response header name (Vary, Link...),
+// unless it is already there, instead of replacing what the handler or another middleware set.
+func MergeHeader(headers map[string]string, name, value string) {
+	for key, existing := range headers {
+		if !strings.EqualFold(key, name) {
+			continue
+		}
+		for _, item := range strings.Split(existing, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), value) {
+				return
+			}
+		}
+		headers[key] = existing + ", " + value
+		return
+	}
+	headers[name] = value
+}
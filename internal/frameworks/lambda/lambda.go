@@ -4,12 +4,16 @@ package lambda
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	lambdaaws "github.com/aws/aws-lambda-go/lambda"
 	"github.com/lambadass-2024/backend/internal/fault"
 	baselogger "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
 	"github.com/rs/zerolog"
 )
 
@@ -70,14 +74,14 @@ func (t *Lambda[T, U]) onSetupHandler(
 		t.logger = &zerolog.Logger{}
 		t.logger.Debug().Msg("OnSetup")
 		for i, mw := range workingMiddlewares {
+			span := trace.StartSpan("OnSetup " + middlewareName(mw))
 			err := mw.OnSetup(ctx, &request)
+			span.EndWith(err)
 			if i == 0 { // Special case : first middleware should be the logger
-				l := baselogger.APIGatewayClient{}
-				ll := l.With().Str("framework", "LAMBDA").Logger()
-				t.logger = &ll
+				t.logger = baselogger.Child("framework", "LAMBDA")
 			}
 			if err != nil {
-				t.logger.Error().Err(err).Msgf("OnSetup encountered an error (%v/%v middlewares added)", i+1, len(workingMiddlewares))
+				t.logger.Debug().Str("code", err.Code()).Msgf("OnSetup encountered an error (%v/%v middlewares added)", i+1, len(workingMiddlewares))
 				return workingMiddlewares[:i], err
 			}
 		}
@@ -92,9 +96,14 @@ func (t *Lambda[T, U]) onBeforeHandler(
 ) ([]MiddlewareInterface[T, U], fault.Fault) {
 	t.logger.Debug().Msg("onBeforeHandler")
 	for i, mw := range workingMiddlewares {
+		span := trace.StartSpan("OnBefore " + middlewareName(mw))
 		err := mw.OnBefore(ctx, &request)
+		span.EndWith(err)
+		if i == 0 { // The logger made the logger of this request
+			t.logger = baselogger.Child("framework", "LAMBDA")
+		}
 		if err != nil {
-			t.logger.Error().Err(err).Msgf("OnBefore encountered an error (%v/%v middlewares triggered)", i+1, len(workingMiddlewares))
+			t.logger.Debug().Str("code", err.Code()).Msgf("OnBefore encountered an error (%v/%v middlewares triggered)", i+1, len(workingMiddlewares))
 			return workingMiddlewares[:i], err
 		}
 	}
@@ -104,11 +113,31 @@ func (t *Lambda[T, U]) onBeforeHandler(
 func (t *Lambda[T, U]) onAfterHandler(res *U, err fault.Fault, workingMiddlewares []MiddlewareInterface[T, U]) (U, fault.Fault) {
 	t.logger.Debug().Msg("onAfterHandler")
 	for i := len(workingMiddlewares) - 1; i >= 0; i-- {
+		span := trace.StartSpan("OnAfter " + middlewareName(workingMiddlewares[i]))
 		err = workingMiddlewares[i].OnAfter(res, err)
+		span.EndWith(err)
 	}
 	return *res, err
 }
 
+// middlewareName returns logger.APIGatewayClient for a *logger.APIGatewayClient, without type parameters
+func middlewareName(mw any) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(fmt.Sprintf("%T", mw), "*"), "[")
+	return name
+}
+
+// traceHeaders returns the headers of request carrying its trace context, nil for events without headers
+func traceHeaders(request any) map[string]string {
+	switch r := request.(type) {
+	case *events.APIGatewayProxyRequest:
+		return r.Headers
+	case *events.APIGatewayV2HTTPRequest:
+		return r.Headers
+	default:
+		return nil
+	}
+}
+
 /******************************************************************************
 ***** Functions
 ******************************************************************************/
@@ -151,8 +180,22 @@ func (t *Lambda[T, U]) Start(handler HandlerFunc[T, U]) {
 // - OnAfter will be executed for each request for each middleware, in the *reverse* order they were added with Use
 //
 // - OnShutdown is not executed here, see Start
+//
+// The invocation is traced : each phase of each middleware and the handler get a span, exported when it ends.
 func (t *Lambda[T, U]) handleRequest(ctx context.Context, request T) (U, error) {
 	t.request = &request
+	trace.Start(traceHeaders(&request))
+	response, flt := t.handleTracedRequest(ctx, request)
+	if flt != nil { // No middleware made a response of it, as APIGatewayClient does
+		fault.Log(t.logger, flt, 0)
+	}
+	if err := trace.Finish(flt); err != nil {
+		t.logger.Warn().Err(err).Msg("Cannot export the spans of the request")
+	}
+	return response, flt
+}
+
+func (t *Lambda[T, U]) handleTracedRequest(ctx context.Context, request T) (U, fault.Fault) {
 	workingMiddlewares, err := t.onSetupHandler(ctx, request, t.middlewares)
 	if err != nil {
 		empty := reflect.New(reflect.TypeFor[U]()).Interface().(*U) //nolint:revive //if this doesn't work, reflection is broken and this is unrecoverable
@@ -166,7 +209,9 @@ func (t *Lambda[T, U]) handleRequest(ctx context.Context, request T) (U, error)
 	}
 
 	t.logger.Trace().Msg("Entering handler...")
+	span := trace.StartSpan("handler")
 	res, err := t.handler(ctx, request)
+	span.EndWith(err)
 	t.logger.Trace().Err(err).Interface("response", res).Msg("Exited handler")
 
 	finalResponse, finalError := t.onAfterHandler(&res, err, workingMiddlewares)
@@ -7,8 +7,11 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/lambadass-2024/backend/internal/fault"
 	"github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace/tracetest"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var logger zerolog.Logger = zerolog.Logger{}
@@ -38,7 +41,7 @@ func Test_Lambda_HandleRequest_Response200(t *testing.T) {
 
 func Test_Lambda_HandleRequest_ResponseError(t *testing.T) {
 	expectedResponse := events.APIGatewayProxyResponse{}
-	expectedError := fault.NewAPIGateway(&logger, 500, "ERROR_CODE", "Message", nil, nil)
+	expectedError := fault.NewAPIGateway(500, "ERROR_CODE", "Message", nil, nil)
 
 	trezer := lambda.TestNewLambdaWithFunc(func(_ context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, fault.Fault) {
 		return events.APIGatewayProxyResponse{}, expectedError
@@ -178,7 +181,7 @@ func (m *SampleMiddleware2) OnSetup(_ context.Context, firstRequest *events.APIG
 	assert.NotNil(m.t, firstRequest)
 	assert.Equal(m.t, agpreq1, *firstRequest)
 	m.OnSetupCalled++
-	return fault.NewUseCase(&logger, "SampleMiddleware2", "CODE1", "Code 1", nil, nil)
+	return fault.NewUseCase("SampleMiddleware2", "CODE1", "Code 1", nil, nil)
 }
 
 func (m *SampleMiddleware2) OnBefore(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
@@ -253,7 +256,7 @@ func (m *SampleMiddleware3) OnBefore(_ context.Context, request *events.APIGatew
 	assert.NotNil(m.t, request)
 	assert.Equal(m.t, agpreq1, *request)
 	m.OnBeforeCalled++
-	return fault.NewUseCase(&logger, "SampleMiddleware3", "CODE2", "Code 2", nil, nil)
+	return fault.NewUseCase("SampleMiddleware3", "CODE2", "Code 2", nil, nil)
 }
 
 func (m *SampleMiddleware3) OnAfter(_ *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
@@ -301,3 +304,68 @@ func Test_Lambda_OnBeforeFail(t *testing.T) { //nolint:dupl //it's tests, who ca
 	assert.Equal(t, 0, middlewareB.OnAfterCalled)  // as the previous middleware failed, this one will not be called, forever
 	// to release ressources, use onShutdown, not onAfter
 }
+
+/******************************************************************************
+***** Tracing
+******************************************************************************/
+
+type NoopMiddleware struct{}
+
+func (NoopMiddleware) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	return nil
+}
+func (NoopMiddleware) OnBefore(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	return nil
+}
+func (NoopMiddleware) OnAfter(_ *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	return err
+}
+func (NoopMiddleware) OnShutdown() {}
+
+func Test_Lambda_HandleRequest_Spans(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	collector := tracetest.NewCollector()
+	defer collector.Close()
+	trace.SetExporter(&trace.OTLPExporter{Endpoint: collector.URL, Service: "pet-GET"})
+	defer trace.SetExporter(nil)
+	defer trace.Set(trace.Context{})
+
+	apiGateway := lambda.APIGatewayClient{}
+	apiGateway.Use(&apiGateway).Use(&NoopMiddleware{})
+	request := events.APIGatewayProxyRequest{HTTPMethod: "GET", Resource: "/pet", Headers: map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}}
+	_, err := apiGateway.TestHandleRequest(func(_ context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, fault.Fault) {
+		trace.StartSpan("query").EndSpan()
+		return events.APIGatewayProxyResponse{}, fault.NewAPIGateway(503, "UNAVAILABLE", "Unavailable", nil, nil)
+	}, &request)
+	require.NoError(t, err)
+
+	var names []string
+	for _, span := range collector.Spans() {
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.TraceID)
+		assert.Equal(t, "pet-GET", span.Attributes["resource.service.name"])
+		names = append(names, span.Name)
+	}
+	assert.Equal(t, []string{
+		"OnSetup lambda.APIGatewayClient", "OnSetup lambda_test.NoopMiddleware",
+		"OnBefore lambda.APIGatewayClient", "OnBefore lambda_test.NoopMiddleware",
+		"query", "handler",
+		"OnAfter lambda_test.NoopMiddleware", "OnAfter lambda.APIGatewayClient",
+		"GET /pet",
+	}, names)
+
+	root, ok := collector.Span("GET /pet")
+	require.True(t, ok)
+	assert.Equal(t, "b7ad6b7169203331", root.ParentID)
+	assert.True(t, root.Failed)
+	assert.Equal(t, int64(503), root.Attributes["http.response.status_code"])
+
+	handler, ok := collector.Span("handler")
+	require.True(t, ok)
+	assert.Equal(t, root.SpanID, handler.ParentID)
+	assert.Equal(t, "UNAVAILABLE", handler.Attributes["fault.code"])
+	query, ok := collector.Span("query")
+	require.True(t, ok)
+	assert.Equal(t, handler.SpanID, query.ParentID)
+}
@@ -0,0 +1,132 @@
+package lambda
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MessagePack format markers, see https://github.com/msgpack/msgpack/blob/master/spec.md
+const (
+	msgpackNil      = 0xc0
+	msgpackFalse    = 0xc2
+	msgpackTrue     = 0xc3
+	msgpackFloat64  = 0xcb
+	msgpackInt64    = 0xd3
+	msgpackStr32    = 0xdb
+	msgpackArray32  = 0xdd
+	msgpackMap32    = 0xdf
+	msgpackFixStr   = 0xa0
+	msgpackFixArray = 0x90
+	msgpackFixMap   = 0x80
+	msgpackFixMax   = 15 // Max length of fixarray and fixmap
+	msgpackFixStrMx = 31 // Max length of fixstr
+	msgpackFixIntMx = 127
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// MessagePackEncoder writes objects as MessagePack.
+//
+// Objects go through their JSON representation first, so json tags and MarshalJSON are honored
+// and the document has the same shape as its JSON counterpart.
+type MessagePackEncoder struct{}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func (MessagePackEncoder) MediaType() string {
+	return "application/msgpack"
+}
+
+func (MessagePackEncoder) Encode(obj any) ([]byte, error) {
+	resJSON, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(resJSON))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := msgpackWrite(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// msgpackWrite writes a value decoded from JSON (nil, bool, json.Number, string, []any, map[string]any)
+func msgpackWrite(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(msgpackNil)
+	case bool:
+		if v {
+			buf.WriteByte(msgpackTrue)
+		} else {
+			buf.WriteByte(msgpackFalse)
+		}
+	case json.Number:
+		msgpackWriteNumber(buf, v)
+	case string:
+		msgpackWriteHeader(buf, len(v), msgpackFixStr, msgpackFixStrMx, msgpackStr32)
+		buf.WriteString(v)
+	case []any:
+		msgpackWriteHeader(buf, len(v), msgpackFixArray, msgpackFixMax, msgpackArray32)
+		for _, item := range v {
+			if err := msgpackWrite(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		msgpackWriteHeader(buf, len(v), msgpackFixMap, msgpackFixMax, msgpackMap32)
+		for _, key := range keys {
+			_ = msgpackWrite(buf, key)
+			if err := msgpackWrite(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unexpected type %T", value)
+	}
+	return nil
+}
+
+func msgpackWriteNumber(buf *bytes.Buffer, number json.Number) {
+	if i, err := number.Int64(); err == nil {
+		if i >= 0 && i <= msgpackFixIntMx {
+			buf.WriteByte(byte(i))
+			return
+		}
+		buf.WriteByte(msgpackInt64)
+		_ = binary.Write(buf, binary.BigEndian, i)
+		return
+	}
+	f, _ := number.Float64() // json.Decoder already checked the syntax
+	buf.WriteByte(msgpackFloat64)
+	_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+// msgpackWriteHeader writes the marker and length of a string, an array or a map
+func msgpackWriteHeader(buf *bytes.Buffer, length int, fixMarker byte, fixMax int, marker32 byte) {
+	if length <= fixMax {
+		buf.WriteByte(fixMarker | byte(length))
+		return
+	}
+	buf.WriteByte(marker32)
+	_ = binary.Write(buf, binary.BigEndian, uint32(length)) //nolint:gosec // bodies are far below 4GB
+}
@@ -2,47 +2,291 @@ package logger
 
 import (
 	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/redact"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// AccessCategory is the category of the access log lines, see APIGatewayClient
+const AccessCategory = "access"
+
+// recordedFault is the code of the fault of the current request which a middleware turned into a response,
+// see RecordFault. A Lambda handles one request at a time, the logger resets it in OnBefore.
+var recordedFault string
+
 /******************************************************************************
 ***** Structs
 ******************************************************************************/
 
+// APIGatewayClient sets up the logger for API Gateway requests and writes an access log line at the end of each one.
+//
+// Access log lines have a stable schema, aggregated by the queries of terraform/modules/lambda_http/monitoring.tf :
+// category (always "access"), method, route, path, status, faultCode, latencyMs, requestBytes, responseBytes,
+// sourceIp, userAgent, principalId, apiKeyId, coldStart, requestId and awsRequestId, plus the trace context of every
+// log line.
+// Bodies and headers are never logged there : the request is only logged at trace level, redacted by the default
+// Redactor of the redact package.
+//
+// A request logs at trace level, whatever Level is, when it carries a valid token of NewDebugToken in HeaderDebug,
+// signed with the LOG_DEBUG_SECRET environment variable, or when the claims of its authorizer set DebugClaim to true.
+//
+// With Buffer enabled, the logs of a request below its level are kept in memory and written after its access log line
+// only if it ended with a fault or took longer than Buffer.LatencyThreshold. Buffer.SamplePercent of the requests
+// write all their logs, as the requests raised to trace.
 type APIGatewayClient struct {
 	Client[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]
+	DebugClaim  string // Defaults to DefaultDebugClaim
+	Buffer      BufferConfig
+	buffer      *bufferWriter // Output of base when Buffer is enabled
+	debugSecret []byte
+	coldStart   bool // Next request is the first one of the instance
+	access      access
+}
+
+// access is what the access log line needs from the request, kept until OnAfter
+type access struct {
+	start        time.Time
+	method       string
+	route        string
+	path         string
+	requestBytes int
+	sourceIP     string
+	userAgent    string
+	principalID  string
+	apiKeyID     string
+	requestID    string
+	awsRequestID string
+	coldStart    bool
 }
 
 /******************************************************************************
-***** Middleware
+***** Functions
 ******************************************************************************/
 
-func (m *APIGatewayClient) OnSetup(_ context.Context, firstRequest *events.APIGatewayProxyRequest) fault.Fault {
-	m.preSetup()
+func newAccess(ctx context.Context, request *events.APIGatewayProxyRequest, coldStart bool) access {
+	route := request.Resource
+	if route == "" {
+		route = request.Path
+	}
+	return access{
+		start:        time.Now(),
+		method:       request.HTTPMethod,
+		route:        route,
+		path:         request.Path,
+		requestBytes: bodySize(request.Body, request.IsBase64Encoded),
+		sourceIP:     request.RequestContext.Identity.SourceIP,
+		userAgent:    request.RequestContext.Identity.UserAgent,
+		principalID:  principalID(request.RequestContext.Authorizer),
+		apiKeyID:     request.RequestContext.Identity.APIKeyID,
+		requestID:    request.RequestContext.RequestID,
+		awsRequestID: awsRequestID(ctx),
+		coldStart:    coldStart,
+	}
+}
 
-	log.Logger = log.Logger.With().
-		Str("type", "APIGatewayProxy").
-		Str("method", firstRequest.HTTPMethod).
-		Str("path", firstRequest.Path).
-		Str("request", firstRequest.RequestContext.RequestID).
-		Logger()
+// principalID returns the caller authenticated by an API Gateway authorizer : the principalId of a Lambda authorizer
+// or the sub claim of a Cognito one
+func principalID(authorizer map[string]any) string {
+	if principal, ok := authorizer["principalId"].(string); ok {
+		return principal
+	}
+	if claims, ok := authorizer["claims"].(map[string]any); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+	return ""
+}
 
-	m.Logger = m.Logger.With().
-		Str("type", "APIGatewayProxy").
-		Str("method", firstRequest.HTTPMethod).
-		Str("path", firstRequest.Path).
-		Str("request", firstRequest.RequestContext.RequestID).
-		Logger()
+// bodySize returns the size in bytes of a body, decoded if API Gateway encoded it in base64
+func bodySize(body string, isBase64Encoded bool) int {
+	if !isBase64Encoded {
+		return len(body)
+	}
+	return base64.StdEncoding.DecodedLen(len(body)) - strings.Count(body[max(0, len(body)-2):], "=")
+}
+
+// RecordFault records code as the one of the fault of the current request, for a middleware turning the fault
+// into a response : the fault does not reach the OnAfter of the logger, which writes code in the access log line
+// and flushes the buffered logs all the same.
+func RecordFault(code string) {
+	recordedFault = code
+}
+
+// FaultCode returns the code of the fault of the current request, err or the one recorded with RecordFault
+func FaultCode(err fault.Fault) string {
+	if err != nil {
+		return err.Code()
+	}
+	return recordedFault
+}
+
+// awsRequestID returns the ID of the Lambda invocation, "" outside of Lambda
+func awsRequestID(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return lc.AwsRequestID
+	}
+	return ""
+}
+
+// debug tells if the logs of request are raised to trace, by HeaderDebug or by a claim
+func (m *APIGatewayClient) debug(request *events.APIGatewayProxyRequest) bool {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, HeaderDebug) && validDebugToken(m.debugSecret, value, time.Now()) {
+			return true
+		}
+	}
+	if claims, ok := request.RequestContext.Authorizer["claims"].(map[string]any); ok {
+		switch claim := claims[m.DebugClaim].(type) {
+		case bool:
+			return claim
+		case string: // REST API authorizers give claims as strings
+			return claim == "true"
+		}
+	}
+	return false
+}
+
+// redactedRequest returns what can be logged of request, its credentials and secrets being masked
+func redactedRequest(request *events.APIGatewayProxyRequest) map[string]any {
+	body := request.Body
+	if !request.IsBase64Encoded {
+		body = redact.JSON(body)
+	} else if body != "" {
+		body = "[BASE64]" // Binary bodies are not decoded to be redacted
+	}
+	return map[string]any{
+		"method":                          request.HTTPMethod,
+		"resource":                        request.Resource,
+		"path":                            request.Path,
+		"headers":                         redact.Headers(request.Headers),
+		"multiValueHeaders":               redact.MultiValueHeaders(request.MultiValueHeaders),
+		"queryStringParameters":           redact.Value(request.QueryStringParameters),
+		"multiValueQueryStringParameters": redact.Value(request.MultiValueQueryStringParameters),
+		"pathParameters":                  redact.Value(request.PathParameters),
+		"body":                            body,
+		"isBase64Encoded":                 request.IsBase64Encoded,
+	}
+}
+
+// log writes the access log line of the request
+func (a access) log(logger *zerolog.Logger, response *events.APIGatewayProxyResponse, err fault.Fault) {
+	status, responseBytes := 0, 0
+	if response != nil {
+		status, responseBytes = response.StatusCode, bodySize(response.Body, response.IsBase64Encoded)
+	}
+	if status == 0 && err != nil { // A middleware before the Lambda one failed, AWS answers a 502
+		status = 502
+	}
+	// Written whatever the level of the request, as the EMF lines of the metrics : the monitoring counts every request
+	logger.Log().
+		Str(zerolog.LevelFieldName, zerolog.InfoLevel.String()).
+		Str("category", AccessCategory).
+		Str("method", a.method).
+		Str("route", a.route).
+		Str("path", a.path).
+		Int("status", status).
+		Str("faultCode", FaultCode(err)).
+		Float64("latencyMs", float64(time.Since(a.start).Microseconds())/1000).
+		Int("requestBytes", a.requestBytes).
+		Int("responseBytes", responseBytes).
+		Str("sourceIp", a.sourceIP).
+		Str("userAgent", a.userAgent).
+		Str("principalId", a.principalID).
+		Str("apiKeyId", a.apiKeyID).
+		Bool("coldStart", a.coldStart).
+		Str("requestId", a.requestID).
+		Str("awsRequestId", a.awsRequestID).
+		Msgf("%v %v %v", a.method, a.path, status)
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+// OnSetup sets up log.Logger, without the fields of a request which OnBefore adds for each one
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.preSetup()
+	m.base = log.Logger.With().Str("type", "APIGatewayProxy").Logger() // The access log line writes the fields of the request
+	if m.Buffer.Enabled {
+		if m.Buffer.LatencyThreshold <= 0 {
+			m.Buffer.LatencyThreshold = DefaultLatencyThreshold
+		}
+		m.buffer = newBufferWriter(m.Buffer, m.level)
+		m.base = m.base.Output(m.buffer)
+	}
+	log.Logger = m.base
+	m.Logger = log.Logger.With().Str("framework", "LOGGER").Logger()
 	m.Logger.Debug().Msg("Setup logger ok (MiddlewareAPIGateway)")
+	if m.DebugClaim == "" {
+		m.DebugClaim = DefaultDebugClaim
+	}
+	m.debugSecret = []byte(os.Getenv("LOG_DEBUG_SECRET"))
+	m.coldStart = true
 	return nil
 }
 
-// Unsafe if the request contains private informations
-func (m *APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+// OnBefore makes log.Logger a child logger of the request, with its method, path, API Gateway request ID (request)
+// and Lambda request ID (awsRequestId). The other components get their logger from it in their OnBefore, see Child.
+// It also sets the level of the request, a Lambda handling one request at a time. When buffering, every log is made
+// and the buffer decides which ones are written.
+func (m *APIGatewayClient) OnBefore(ctx context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	debug := m.debug(request)
+	sampled := m.buffer != nil && !debug && m.Buffer.sampled()
+	level := m.level
+	if debug || sampled || m.Output != nil {
+		level = zerolog.TraceLevel
+	}
+	if m.buffer != nil {
+		m.buffer.start(level)
+		zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	} else {
+		zerolog.SetGlobalLevel(level)
+	}
+	log.Logger = m.output().With().
+		Str("method", request.HTTPMethod).
+		Str("path", request.Path).
+		Str("request", request.RequestContext.RequestID).
+		Str("awsRequestId", awsRequestID(ctx)).
+		Logger()
+	m.Logger = log.Logger.With().Str("framework", "LOGGER").Logger()
+	if debug {
+		m.Logger.Info().Msg("Logs of this request are raised to trace")
+	}
+	if sampled {
+		m.Logger.Info().Msg("Logs of this request are sampled, written at trace level")
+	}
 	m.Logger.Trace().Msg("OnBefore")
-	m.Logger.Trace().Interface("request", request).Msg("Request log")
+	m.Logger.Trace().Interface("request", redactedRequest(request)).Msg("Request log")
+	m.access = newAccess(ctx, request, m.coldStart)
+	m.coldStart = false
+	recordedFault = ""
 	return nil
 }
+
+// OnAfter writes the access log line, then the buffered logs if the request failed or was slow.
+// Being the first middleware, it sees the response sent to API Gateway.
+func (m *APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.Logger.Trace().Msg("OnAfter")
+	base := m.output()
+	m.access.log(&base, response, err)
+	if m.buffer != nil {
+		flush, reason := m.Buffer.flush(FaultCode(err), time.Since(m.access.start))
+		if flush {
+			m.Logger.Info().
+				Str("reason", reason).
+				Int("droppedLines", m.buffer.overflow()).
+				Msg("Writing the buffered logs of the request")
+		}
+		m.buffer.stop(flush)
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/logger/logtest"
+	"github.com/lambadass-2024/backend/internal/redact"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLines returns the access log lines written in buffer
+func accessLines(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &fields))
+		if fields["category"] == logger.AccessCategory {
+			lines = append(lines, fields)
+		}
+	}
+	return lines
+}
+
+func newLogger() (*logger.APIGatewayClient, *bytes.Buffer) {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	buffer := &bytes.Buffer{}
+	log.Logger = zerolog.New(buffer)
+	return &logger.APIGatewayClient{}, buffer
+}
+
+func Test_Logger_Output(t *testing.T) {
+	m, buffer := newLogger()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet", RequestContext: events.APIGatewayProxyRequestContext{RequestID: "request-1"}}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+
+	sink := logtest.New(t)
+	m.Output = sink
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	logger.Child("usecase", "Test").Debug().Msg("Handling")
+	require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, nil))
+
+	sink.AssertLogged(t, zerolog.DebugLevel, "Handling", map[string]any{"request": "request-1", "usecase": "Test"})
+	sink.AssertLogged(t, zerolog.InfoLevel, "", map[string]any{"category": logger.AccessCategory, "status": 200})
+	assert.Empty(t, buffer.String(), "The output of log.Logger is not written")
+}
+
+func Test_Logger_AccessLog(t *testing.T) {
+	m, buffer := newLogger()
+	request := &events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Resource:   "/pet/{id}",
+		Path:       "/pet/1",
+		Body:       `{"name":"secret"}`,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  "request-1",
+			Identity:   events.APIGatewayRequestIdentity{SourceIP: "1.2.3.4", UserAgent: "curl", APIKeyID: "key"},
+			Authorizer: map[string]any{"claims": map[string]any{"sub": "user"}},
+		},
+	}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	for i, coldStart := range []bool{true, false} {
+		require.NoError(t, m.OnBefore(context.Background(), request))
+		require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 201, Body: "eyJpZCI6MX0=", IsBase64Encoded: true}, nil))
+
+		lines := accessLines(t, buffer)
+		require.Len(t, lines, i+1)
+		line := lines[i]
+		assert.Equal(t, "info", line["level"])
+		assert.Equal(t, "POST", line["method"])
+		assert.Equal(t, "/pet/{id}", line["route"])
+		assert.Equal(t, "/pet/1", line["path"])
+		assert.Equal(t, 201.0, line["status"])
+		assert.Equal(t, "", line["faultCode"])
+		assert.Contains(t, line, "latencyMs")
+		assert.Equal(t, 17.0, line["requestBytes"])
+		assert.Equal(t, 8.0, line["responseBytes"]) // {"id":1}
+		assert.Equal(t, "1.2.3.4", line["sourceIp"])
+		assert.Equal(t, "curl", line["userAgent"])
+		assert.Equal(t, "user", line["principalId"])
+		assert.Equal(t, "key", line["apiKeyId"])
+		assert.Equal(t, coldStart, line["coldStart"])
+		assert.Equal(t, "request-1", line["requestId"])
+	}
+	assert.NotContains(t, buffer.String(), "secret", "Bodies are never logged")
+}
+
+func Test_Logger_AccessLog_Level(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	m, buffer := newLogger()
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet"}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, nil))
+
+	lines := accessLines(t, buffer)
+	require.Len(t, lines, 1, "The access log line is written whatever the level")
+	assert.Equal(t, "info", lines[0]["level"])
+}
+
+func Test_Logger_AccessLog_Fault(t *testing.T) {
+	m, buffer := newLogger()
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet"}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	flt := fault.NewAPIGateway(500, "CONFIG_MISSING", "Config missing", nil, nil)
+	assert.Equal(t, flt, m.OnAfter(&events.APIGatewayProxyResponse{}, flt))
+
+	lines := accessLines(t, buffer)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "/pet", lines[0]["route"])
+	assert.Equal(t, 502.0, lines[0]["status"])
+	assert.Equal(t, "CONFIG_MISSING", lines[0]["faultCode"])
+}
+
+func Test_Logger_AccessLog_RecordedFault(t *testing.T) {
+	m, buffer := newLogger()
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet/1"}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+
+	// The Lambda middleware turned the fault into a response
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	logger.RecordFault("PET_NOT_FOUND")
+	require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 404}, nil))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, nil))
+
+	lines := accessLines(t, buffer)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "PET_NOT_FOUND", lines[0]["faultCode"])
+	assert.Equal(t, "", lines[1]["faultCode"])
+}
+
+func Test_Logger_RequestRedacted(t *testing.T) {
+	m, buffer := newLogger()
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer zerolog.SetGlobalLevel(zerolog.Disabled)
+	request := &events.APIGatewayProxyRequest{
+		HTTPMethod:            "POST",
+		Path:                  "/login",
+		Headers:               map[string]string{"Authorization": "Basic dXNlcjpodW50ZXIy", "Accept": "application/json"},
+		QueryStringParameters: map[string]string{"token": "t0k3n"},
+		Body:                  `{"email":"bob@example.com","password":"hunter2"}`,
+	}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+
+	assert.Contains(t, buffer.String(), "Request log")
+	assert.Contains(t, buffer.String(), "application/json")
+	redact.AssertNoLeak(t, buffer.String(), "dXNlcjpodW50ZXIy", "t0k3n", "hunter2")
+}
+
+func Test_Logger_PerRequest(t *testing.T) {
+	m, buffer := newLogger()
+	first := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet", RequestContext: events.APIGatewayProxyRequestContext{RequestID: "first"}}
+	second := &events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/pet", RequestContext: events.APIGatewayProxyRequestContext{RequestID: "second"}}
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "aws-second"})
+	require.NoError(t, m.OnSetup(context.Background(), first))
+	require.NoError(t, m.OnBefore(context.Background(), first))
+	require.NoError(t, m.OnBefore(ctx, second)) // Same container, next invocation
+	buffer.Reset()
+
+	logger.Child("usecase", "Test").Info().Msg("Handling")
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &fields))
+	assert.Equal(t, "second", fields["request"])
+	assert.Equal(t, "aws-second", fields["awsRequestId"])
+	assert.Equal(t, "POST", fields["method"])
+	assert.Equal(t, "Test", fields["usecase"])
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultLatencyThreshold = time.Second
+	DefaultBufferMaxBytes   = 1 << 20 // 1 MiB, the oldest lines are dropped beyond
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// BufferConfig makes the logs below the level of a request cheap : they are kept in memory and only written
+// if the request fails or is slow, so the detail is there when something breaks.
+type BufferConfig struct {
+	Enabled          bool
+	LatencyThreshold time.Duration // Slower requests write their buffered logs, defaults to DefaultLatencyThreshold
+	SamplePercent    float64       // Percentage of the requests writing all their logs, from 0 to 100
+	MaxBytes         int           // Bytes of logs buffered per request, defaults to DefaultBufferMaxBytes
+	Output           io.Writer     // Defaults to os.Stderr, or a console writer when ENVIRONMENT is LOCAL
+}
+
+// bufferWriter writes the log lines of their level or above, and buffers the others while a request is handled
+type bufferWriter struct {
+	sync.Mutex
+	out       io.Writer
+	level     zerolog.Level // Threshold outside of a request, lines below being dropped
+	threshold zerolog.Level // Lines below are buffered, or dropped outside of a request
+	active    bool
+	lines     [][]byte
+	size      int
+	maxBytes  int
+	dropped   int
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func newBufferWriter(config BufferConfig, level zerolog.Level) *bufferWriter {
+	out := config.Output
+	if out == nil {
+		out = os.Stderr
+		if os.Getenv("ENVIRONMENT") == "LOCAL" {
+			out = zerolog.ConsoleWriter{Out: os.Stderr}
+		}
+	}
+	maxBytes := config.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultBufferMaxBytes
+	}
+	return &bufferWriter{out: out, level: level, threshold: level, maxBytes: maxBytes}
+}
+
+// flush tells if the buffered logs of a request ending with faultCode after latency are written, and why
+func (c BufferConfig) flush(faultCode string, latency time.Duration) (bool, string) {
+	switch {
+	case faultCode != "":
+		return true, "fault"
+	case latency >= c.LatencyThreshold:
+		return true, "slow"
+	}
+	return false, ""
+}
+
+// sampled tells if a request writes all its logs, SamplePercent of them being
+func (c BufferConfig) sampled() bool {
+	return c.SamplePercent > 0 && rand.Float64()*100 < c.SamplePercent
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel writes p if level is at least the threshold, else buffers it during a request
+func (w *bufferWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if level >= w.threshold {
+		return w.out.Write(p)
+	}
+	if !w.active {
+		return len(p), nil
+	}
+	w.lines = append(w.lines, append([]byte(nil), p...)) // zerolog reuses p
+	w.size += len(p)
+	for w.size > w.maxBytes && len(w.lines) > 0 {
+		w.size -= len(w.lines[0])
+		w.lines = w.lines[1:]
+		w.dropped++
+	}
+	return len(p), nil
+}
+
+// start buffers the lines below threshold until stop
+func (w *bufferWriter) start(threshold zerolog.Level) {
+	w.Lock()
+	defer w.Unlock()
+	w.threshold, w.active = threshold, true
+	w.lines, w.size, w.dropped = nil, 0, 0
+}
+
+// overflow returns how many lines of the request were dropped to keep the buffer under maxBytes
+func (w *bufferWriter) overflow() int {
+	w.Lock()
+	defer w.Unlock()
+	return w.dropped
+}
+
+// stop ends the request, writing the buffered lines if flush is set or dropping them
+func (w *bufferWriter) stop(flush bool) {
+	w.Lock()
+	defer w.Unlock()
+	lines := w.lines
+	w.threshold, w.active, w.lines, w.size, w.dropped = w.level, false, nil, 0, 0
+	if !flush {
+		return
+	}
+	for _, line := range lines {
+		_, _ = w.out.Write(line) // As zerolog, a log line failing to be written does not fail the request
+	}
+}
@@ -0,0 +1,73 @@
+package logger_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Logger_Buffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		buffer   logger.BufferConfig
+		err      fault.Fault
+		written  bool
+		sampled  bool
+		flushing bool
+	}{
+		{name: "Success", buffer: logger.BufferConfig{Enabled: true, LatencyThreshold: time.Hour}},
+		{name: "Fault", buffer: logger.BufferConfig{Enabled: true, LatencyThreshold: time.Hour},
+			err: fault.NewAPIGateway(500, "CONFIG_MISSING", "Config missing", nil, nil), written: true, flushing: true},
+		{name: "Slow", buffer: logger.BufferConfig{Enabled: true, LatencyThreshold: time.Nanosecond}, written: true, flushing: true},
+		{name: "Sampled", buffer: logger.BufferConfig{Enabled: true, LatencyThreshold: time.Hour, SamplePercent: 100}, written: true, sampled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, buffer := newLogger()
+			defer zerolog.SetGlobalLevel(zerolog.Disabled)
+			tt.buffer.Output = buffer
+			m.Buffer = tt.buffer
+			request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet"}
+			require.NoError(t, m.OnSetup(context.Background(), request))
+			require.NoError(t, m.OnBefore(context.Background(), request))
+			logger.Child("usecase", "Test").Debug().Msg("Detail")
+			logger.Child("usecase", "Test").Info().Msg("Handled")
+			assert.Equal(t, tt.sampled, strings.Contains(buffer.String(), "Detail"), "Logs are written at the end of the request unless sampled")
+			_ = m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, tt.err)
+
+			assert.Contains(t, buffer.String(), "Handled")
+			require.Len(t, accessLines(t, buffer), 1)
+			assert.Equal(t, tt.written, strings.Contains(buffer.String(), "Detail"))
+			assert.Equal(t, tt.flushing, strings.Contains(buffer.String(), "Writing the buffered logs of the request"))
+
+			buffer.Reset()
+			logger.Child("usecase", "Test").Debug().Msg("Outside of a request")
+			assert.Empty(t, buffer.String())
+		})
+	}
+}
+
+func Test_Logger_Buffer_MaxBytes(t *testing.T) {
+	m, buffer := newLogger()
+	defer zerolog.SetGlobalLevel(zerolog.Disabled)
+	m.Buffer = logger.BufferConfig{Enabled: true, LatencyThreshold: time.Nanosecond, MaxBytes: 512, Output: buffer}
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet"}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	for i := range 20 {
+		logger.Child("usecase", "Test").Debug().Int("i", i).Msg("Detail")
+	}
+	require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, nil))
+
+	assert.Contains(t, buffer.String(), `"i":19`, "The latest logs are kept")
+	assert.NotContains(t, buffer.String(), `"i":0,`)
+	assert.Regexp(t, `"droppedLines":[1-9]`, buffer.String())
+}
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+)
+
+// UnknownType is the type of the events no extractor recognizes
+const UnknownType = "unknown"
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Event is what the logs of an invocation tell about its event
+type Event struct {
+	Type          string // Kind of event, as SQSEvent
+	Source        string // Where it comes from : route, queue, bucket, event source or table
+	CorrelationID string // Made the correlationId of the logs and of the calls to other services, if valid
+	Count         int    // Records of a batch, 0 for a single event
+}
+
+// Extractor derives the Event of the events of a source. Client tries its Extractors in order on each event.
+type Extractor interface {
+	// Extract returns the Event of event, ok being false if it is not of the source of the extractor
+	Extract(event any) (e Event, ok bool)
+}
+
+// ExtractorFunc is an Extractor of the events of type E, which Client passes as *E.
+//
+// Example, for a custom event :
+//
+//	Logger.Extractors = append(logger.DefaultExtractors(), logger.ExtractorFunc[MyEvent](func(e *MyEvent) logger.Event {
+//		return logger.Event{Type: "MyEvent", Source: e.Origin}
+//	}))
+type ExtractorFunc[E any] func(event *E) Event
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// DefaultExtractors returns the extractors of the events of API Gateway (REST and HTTP APIs), SQS, S3, EventBridge
+// and DynamoDB streams.
+// The records of a batch have a correlation ID only if the batch has one record, as the others are not related.
+func DefaultExtractors() []Extractor {
+	return []Extractor{
+		ExtractorFunc[events.APIGatewayProxyRequest](apiGatewayEvent),
+		ExtractorFunc[events.APIGatewayV2HTTPRequest](apiGatewayV2Event),
+		ExtractorFunc[events.SQSEvent](sqsEvent),
+		ExtractorFunc[events.S3Event](s3Event),
+		ExtractorFunc[events.EventBridgeEvent](eventBridgeEvent),
+		ExtractorFunc[events.DynamoDBEvent](dynamoDBEvent),
+	}
+}
+
+func (f ExtractorFunc[E]) Extract(event any) (Event, bool) {
+	switch e := event.(type) {
+	case *E:
+		return f(e), true
+	case E:
+		return f(&e), true
+	default:
+		return Event{}, false
+	}
+}
+
+// extract returns the Event of event from the first extractor recognizing it
+func extract(extractors []Extractor, event any) Event {
+	for _, extractor := range extractors {
+		if e, ok := extractor.Extract(event); ok {
+			return e
+		}
+	}
+	return Event{Type: UnknownType}
+}
+
+func apiGatewayEvent(request *events.APIGatewayProxyRequest) Event {
+	route := request.Resource
+	if route == "" {
+		route = request.Path
+	}
+	return Event{
+		Type:          "APIGatewayProxy",
+		Source:        request.HTTPMethod + " " + route,
+		CorrelationID: headerValue(request.Headers, trace.HeaderRequestID),
+	}
+}
+
+func apiGatewayV2Event(request *events.APIGatewayV2HTTPRequest) Event {
+	route := request.RouteKey
+	if route == "" {
+		route = request.RequestContext.HTTP.Method + " " + request.RawPath
+	}
+	return Event{
+		Type:          "APIGatewayV2HTTP",
+		Source:        route,
+		CorrelationID: headerValue(request.Headers, trace.HeaderRequestID),
+	}
+}
+
+// sqsEvent reads the correlation ID in the X-Request-Id or correlationId message attribute
+func sqsEvent(event *events.SQSEvent) Event {
+	e := Event{Type: "SQSEvent", Count: len(event.Records)}
+	if len(event.Records) == 0 {
+		return e
+	}
+	record := event.Records[0]
+	e.Source = arnResource(record.EventSourceARN)
+	if len(event.Records) == 1 {
+		for _, name := range []string{trace.HeaderRequestID, "correlationId"} {
+			if attribute, ok := record.MessageAttributes[name]; ok && attribute.StringValue != nil {
+				e.CorrelationID = *attribute.StringValue
+				break
+			}
+		}
+	}
+	return e
+}
+
+// s3Event correlates with the request made to S3, from its x-amz-request-id
+func s3Event(event *events.S3Event) Event {
+	e := Event{Type: "S3Event", Count: len(event.Records)}
+	if len(event.Records) == 0 {
+		return e
+	}
+	e.Source = event.Records[0].S3.Bucket.Name
+	if len(event.Records) == 1 {
+		e.CorrelationID = event.Records[0].ResponseElements["x-amz-request-id"]
+	}
+	return e
+}
+
+func eventBridgeEvent(event *events.EventBridgeEvent) Event {
+	source := event.Source
+	if event.DetailType != "" {
+		source += " " + event.DetailType
+	}
+	return Event{Type: "EventBridgeEvent", Source: source, CorrelationID: event.ID}
+}
+
+// dynamoDBEvent gives the table of the stream as source
+func dynamoDBEvent(event *events.DynamoDBEvent) Event {
+	e := Event{Type: "DynamoDBEvent", Count: len(event.Records)}
+	if len(event.Records) == 0 {
+		return e
+	}
+	table, _, _ := strings.Cut(arnResource(event.Records[0].EventSourceArn), "/stream/")
+	e.Source = strings.TrimPrefix(table, "table/")
+	if len(event.Records) == 1 {
+		e.CorrelationID = event.Records[0].EventID
+	}
+	return e
+}
+
+// arnResource returns the resource of an ARN, as the name of a queue, the ARN itself if it is not one
+func arnResource(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return arn
+	}
+	return parts[5]
+}
+
+// headerValue looks for name in headers without case sensitivity
+func headerValue(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package logger_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type customEvent struct {
+	Origin string
+}
+
+// extractEvent returns the Event of the default extractor recognizing event
+func extractEvent(event any) (logger.Event, bool) {
+	for _, extractor := range logger.DefaultExtractors() {
+		if e, ok := extractor.Extract(event); ok {
+			return e, true
+		}
+	}
+	return logger.Event{}, false
+}
+
+func Test_Logger_Extractors(t *testing.T) {
+	requestID := "message-1"
+	tests := []struct {
+		name     string
+		event    any
+		expected logger.Event
+	}{
+		{name: "API Gateway", event: &events.APIGatewayProxyRequest{HTTPMethod: "GET", Resource: "/pet/{id}", Headers: map[string]string{"x-request-id": "abc"}},
+			expected: logger.Event{Type: "APIGatewayProxy", Source: "GET /pet/{id}", CorrelationID: "abc"}},
+		{name: "API Gateway v2", event: &events.APIGatewayV2HTTPRequest{RouteKey: "POST /pet", Headers: map[string]string{"X-Request-Id": "abc"}},
+			expected: logger.Event{Type: "APIGatewayV2HTTP", Source: "POST /pet", CorrelationID: "abc"}},
+		{name: "SQS", event: &events.SQSEvent{Records: []events.SQSMessage{{
+			EventSourceARN:    "arn:aws:sqs:eu-west-3:123456789012:pets",
+			MessageAttributes: map[string]events.SQSMessageAttribute{"correlationId": {StringValue: &requestID, DataType: "String"}},
+		}}}, expected: logger.Event{Type: "SQSEvent", Source: "pets", CorrelationID: "message-1", Count: 1}},
+		{name: "SQS batch", event: &events.SQSEvent{Records: []events.SQSMessage{
+			{EventSourceARN: "arn:aws:sqs:eu-west-3:123456789012:pets"}, {EventSourceARN: "arn:aws:sqs:eu-west-3:123456789012:pets"},
+		}}, expected: logger.Event{Type: "SQSEvent", Source: "pets", Count: 2}},
+		{name: "S3", event: &events.S3Event{Records: []events.S3EventRecord{{
+			S3: events.S3Entity{Bucket: events.S3Bucket{Name: "photos"}}, ResponseElements: map[string]string{"x-amz-request-id": "C3D13FE58DE4C810"},
+		}}}, expected: logger.Event{Type: "S3Event", Source: "photos", CorrelationID: "C3D13FE58DE4C810", Count: 1}},
+		{name: "EventBridge", event: events.EventBridgeEvent{ID: "event-1", Source: "pets", DetailType: "PetCreated"},
+			expected: logger.Event{Type: "EventBridgeEvent", Source: "pets PetCreated", CorrelationID: "event-1"}},
+		{name: "DynamoDB", event: &events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{{
+			EventID: "record-1", EventSourceArn: "arn:aws:dynamodb:eu-west-3:123456789012:table/Pets/stream/2024-06-01T00:00:00.000",
+		}}}, expected: logger.Event{Type: "DynamoDBEvent", Source: "Pets", CorrelationID: "record-1", Count: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := extractEvent(tt.event)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, e)
+		})
+	}
+	_, ok := extractEvent(&customEvent{})
+	assert.False(t, ok)
+}
+
+func Test_Logger_Client_SQS(t *testing.T) {
+	_, buffer := newLogger()
+	defer trace.Set(trace.Context{})
+	requestID := "message-1"
+	event := &events.SQSEvent{Records: []events.SQSMessage{{
+		EventSourceARN:    "arn:aws:sqs:eu-west-3:123456789012:pets",
+		MessageAttributes: map[string]events.SQSMessageAttribute{"X-Request-Id": {StringValue: &requestID, DataType: "String"}},
+	}}}
+	log.Logger = log.Logger.Hook(trace.Hook{})
+	m := &logger.Client[events.SQSEvent, events.SQSEventResponse]{}
+	require.NoError(t, m.OnSetup(context.Background(), event))
+	trace.Start(nil)
+	require.NoError(t, m.OnBefore(context.Background(), event))
+	buffer.Reset()
+
+	logger.Child("usecase", "Test").Info().Msg("Handling")
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &fields))
+	assert.Equal(t, "SQSEvent", fields["type"])
+	assert.Equal(t, "pets", fields["source"])
+	assert.Equal(t, 1.0, fields["count"])
+	assert.Equal(t, "message-1", fields["correlationId"])
+}
+
+func Test_Logger_Client_CustomExtractor(t *testing.T) {
+	_, buffer := newLogger()
+	m := &logger.Client[customEvent, string]{}
+	event := &customEvent{Origin: "cron"}
+	require.NoError(t, m.OnSetup(context.Background(), event))
+	require.NoError(t, m.OnBefore(context.Background(), event))
+	logger.Child("usecase", "Test").Info().Msg("Unknown")
+	assert.Contains(t, buffer.String(), `"type":"unknown"`)
+
+	m = &logger.Client[customEvent, string]{Extractors: append(logger.DefaultExtractors(),
+		logger.ExtractorFunc[customEvent](func(e *customEvent) logger.Event {
+			return logger.Event{Type: "CustomEvent", Source: e.Origin}
+		}))}
+	require.NoError(t, m.OnSetup(context.Background(), event))
+	require.NoError(t, m.OnBefore(context.Background(), event))
+	buffer.Reset()
+	logger.Child("usecase", "Test").Info().Msg("Known")
+	assert.Contains(t, buffer.String(), `"type":"CustomEvent","source":"cron"`)
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// HeaderDebug carries a token of NewDebugToken, raising the logs of a request to trace
+	HeaderDebug = "X-Debug-Token"
+	// DefaultDebugClaim is the claim of an API Gateway authorizer raising the logs of a request to trace when true
+	DefaultDebugClaim = "debug"
+
+	maxDebugTokenTTL = 24 * time.Hour // A leaked token cannot be used forever
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Config is the part of cmd/functions/*/config.json read by the logger
+type Config struct {
+	LogLevel string `json:"log_level"`
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// LevelFromConfig returns the log_level of a config.json, "" if it has none or cannot be read.
+//
+// Example, in the main.go of a function :
+//
+//	//go:embed config.json
+//	var config []byte
+//
+//	func main() {
+//		Logger.Level = loggerframework.LevelFromConfig(config)
+//		...
+func LevelFromConfig(config []byte) string {
+	var c Config
+	if json.Unmarshal(config, &c) != nil {
+		return ""
+	}
+	return c.LogLevel
+}
+
+// NewDebugToken returns a token for HeaderDebug, valid until expiry, which is at most 24 hours away.
+// secret is the LOG_DEBUG_SECRET of the function.
+func NewDebugToken(secret []byte, expiry time.Time) string {
+	payload := strconv.FormatInt(expiry.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signDebug(secret, payload))
+}
+
+// validDebugToken tells if token was made by NewDebugToken with secret and has not expired
+func validDebugToken(secret []byte, token string, now time.Time) bool {
+	payload, signature, found := strings.Cut(token, ".")
+	if len(secret) == 0 || !found {
+		return false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decoded, signDebug(secret, payload)) {
+		return false
+	}
+	expiry, err := strconv.ParseInt(payload, 10, 64)
+	return err == nil && now.Unix() < expiry && time.Unix(expiry, 0).Sub(now) <= maxDebugTokenTTL
+}
+
+func signDebug(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("debug:" + payload))
+	return mac.Sum(nil)
+}
+
+// parseLevel reads a level of config.json or LOG_LEVEL, ok being false if level is empty or unknown
+func parseLevel(level string) (zerolog.Level, bool) {
+	if level == "" {
+		return zerolog.NoLevel, false
+	}
+	parsed, err := zerolog.ParseLevel(strings.ToLower(strings.TrimSpace(level)))
+	return parsed, err == nil && parsed != zerolog.NoLevel
+}
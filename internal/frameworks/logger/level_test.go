@@ -0,0 +1,92 @@
+package logger_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Logger_LevelFromConfig(t *testing.T) {
+	assert.Equal(t, "debug", logger.LevelFromConfig([]byte(`{"memory":128,"log_level":"debug"}`)))
+	assert.Equal(t, "", logger.LevelFromConfig([]byte(`{"memory":128}`)))
+	assert.Equal(t, "", logger.LevelFromConfig([]byte(`not json`)))
+}
+
+func Test_Logger_Level(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		env      string
+		expected zerolog.Level
+	}{
+		{name: "Config", level: "warn", expected: zerolog.WarnLevel},
+		{name: "Environment overrides config", level: "warn", env: "DEBUG", expected: zerolog.DebugLevel},
+		{name: "Unknown level", level: "verbose", expected: zerolog.InfoLevel},
+		{name: "Nothing configured", expected: zerolog.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newLogger()
+			t.Setenv("LOG_LEVEL", tt.env)
+			m.Level = tt.level
+			request := &events.APIGatewayProxyRequest{}
+			require.NoError(t, m.OnSetup(context.Background(), request))
+			require.NoError(t, m.OnBefore(context.Background(), request))
+			assert.Equal(t, tt.expected, zerolog.GlobalLevel())
+		})
+	}
+}
+
+func Test_Logger_DebugOverride(t *testing.T) {
+	secret := []byte("debug-secret")
+	t.Setenv("LOG_DEBUG_SECRET", string(secret))
+	tests := []struct {
+		name    string
+		request events.APIGatewayProxyRequest
+		debug   bool
+	}{
+		{name: "Valid token", debug: true, request: events.APIGatewayProxyRequest{
+			Headers: map[string]string{"x-debug-token": logger.NewDebugToken(secret, time.Now().Add(time.Hour))},
+		}},
+		{name: "Expired token", request: events.APIGatewayProxyRequest{
+			Headers: map[string]string{logger.HeaderDebug: logger.NewDebugToken(secret, time.Now().Add(-time.Minute))},
+		}},
+		{name: "Token too long-lived", request: events.APIGatewayProxyRequest{
+			Headers: map[string]string{logger.HeaderDebug: logger.NewDebugToken(secret, time.Now().Add(48*time.Hour))},
+		}},
+		{name: "Token of another secret", request: events.APIGatewayProxyRequest{
+			Headers: map[string]string{logger.HeaderDebug: logger.NewDebugToken([]byte("other"), time.Now().Add(time.Hour))},
+		}},
+		{name: "Malformed token", request: events.APIGatewayProxyRequest{
+			Headers: map[string]string{logger.HeaderDebug: "9999999999"},
+		}},
+		{name: "Claim", debug: true, request: events.APIGatewayProxyRequest{
+			RequestContext: events.APIGatewayProxyRequestContext{Authorizer: map[string]any{"claims": map[string]any{"debug": "true"}}},
+		}},
+		{name: "False claim", request: events.APIGatewayProxyRequest{
+			RequestContext: events.APIGatewayProxyRequestContext{Authorizer: map[string]any{"claims": map[string]any{"debug": "false"}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newLogger()
+			m.Level = "warn"
+			require.NoError(t, m.OnSetup(context.Background(), &tt.request))
+			require.NoError(t, m.OnBefore(context.Background(), &tt.request))
+			if tt.debug {
+				assert.Equal(t, zerolog.TraceLevel, zerolog.GlobalLevel())
+			} else {
+				assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+			}
+
+			require.NoError(t, m.OnBefore(context.Background(), &events.APIGatewayProxyRequest{}))
+			assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel(), "The override lasts for one request")
+		})
+	}
+}
@@ -2,9 +2,11 @@ package logger
 
 import (
 	"context"
+	"io"
 	"os"
 
 	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -13,10 +15,26 @@ import (
 ***** Structs
 ******************************************************************************/
 
+// Client sets up the logger for any event. In OnBefore, log.Logger becomes a child logger of the event, with its type,
+// source and count of records as derived by Extractors, and its Lambda request ID (awsRequestId).
+// The correlation ID of the event, if any, becomes the correlationId of the trace context.
 type Client[T any, U any] struct {
 	Logger zerolog.Logger
+	// Extractors derive the fields of the events, defaults to DefaultExtractors. A new event source only needs one.
+	Extractors []Extractor
+	// Level of the logs (trace, debug, info, warn, error...), usually the log_level of config.json, see LevelFromConfig.
+	// The LOG_LEVEL environment variable overrides it. If both are empty, the global level of zerolog is kept.
+	Level string
+	// Output receives the logs of the requests instead of the output of log.Logger, at trace level. It is meant for
+	// tests, see logtest.New.
+	Output io.Writer
+	level  zerolog.Level  // Level of the requests without a debug override
+	base   zerolog.Logger // log.Logger without the fields of an event
 }
 
+// traceHooked is set once the trace context is added to log.Logger, loggers derived from it inheriting the hook
+var traceHooked bool
+
 /******************************************************************************
 ***** Middleware
 ******************************************************************************/
@@ -26,20 +44,61 @@ func (m *Client[T, U]) preSetup() {
 	if os.Getenv("ENVIRONMENT") == "LOCAL" {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	}
+	if !traceHooked {
+		log.Logger = log.Logger.Hook(trace.Hook{})
+		traceHooked = true
+	}
 	m.Logger = log.Logger.With().Str("framework", "LOGGER").Logger()
+	m.setLevel()
 	m.Logger.Trace().Msg("OnSetup")
 }
 
-func (m *Client[T, U]) OnSetup(_ context.Context, _ *T) fault.Fault {
+// setLevel applies LOG_LEVEL, or Level, as the global level of zerolog
+func (m *Client[T, U]) setLevel() {
+	m.level = zerolog.GlobalLevel()
+	configured, source := os.Getenv("LOG_LEVEL"), "LOG_LEVEL"
+	if configured == "" {
+		configured, source = m.Level, "config"
+	}
+	if configured == "" {
+		return
+	}
+	level, ok := parseLevel(configured)
+	if !ok {
+		m.Logger.Warn().Str("level", configured).Msgf("Unknown log level in %v, keeping %v", source, m.level)
+		return
+	}
+	m.level = level
+	zerolog.SetGlobalLevel(level)
+}
+
+func (m *Client[T, U]) OnSetup(_ context.Context, firstRequest *T) fault.Fault {
 	m.preSetup()
-	m.Logger = log.Logger.With().
-		Str("type", "unknown").
-		Logger()
-	m.Logger.Debug().Msg("Setup logger ok (any)")
+	if m.Extractors == nil {
+		m.Extractors = DefaultExtractors()
+	}
+	m.base = log.Logger
+	m.Logger.Debug().Str("type", extract(m.Extractors, firstRequest).Type).Msg("Setup logger ok (any)")
 	return nil
 }
 
-func (m *Client[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+func (m *Client[T, U]) OnBefore(ctx context.Context, request *T) fault.Fault {
+	event := extract(m.Extractors, request)
+	if event.CorrelationID != "" {
+		trace.SetRequestID(event.CorrelationID)
+	}
+	if m.Output != nil {
+		zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	}
+	c := m.output().With().Str("type", event.Type)
+	if event.Source != "" {
+		c = c.Str("source", event.Source)
+	}
+	if event.Count > 0 {
+		c = c.Int("count", event.Count)
+	}
+	log.Logger = c.Str("awsRequestId", awsRequestID(ctx)).Logger()
+	m.Logger = log.Logger.With().Str("framework", "LOGGER").Logger()
 	m.Logger.Trace().Msg("OnBefore")
 	return nil
 }
@@ -58,6 +117,21 @@ func (m *Client[T, U]) OnShutdown() {
 ***** Functions
 ******************************************************************************/
 
+// output returns the logger of the requests without their fields, writing to Output if it is set
+func (m *Client[T, U]) output() zerolog.Logger {
+	if m.Output != nil {
+		return m.base.Output(m.Output)
+	}
+	return m.base
+}
+
+// Child returns a child of the logger of the current request with the field key set to value, as the logger
+// of a component. Components call it in OnBefore, so their logs carry the fields of the request they handle.
+func Child(key, value string) *zerolog.Logger {
+	ll := log.Logger.With().Str(key, value).Logger()
+	return &ll
+}
+
 // With creates a child logger with the field added to its context.
 func (*Client[T, U]) With() zerolog.Context {
 	return log.Logger.With()
@@ -0,0 +1,152 @@
+// Package logtest records the log lines of a test, to assert on them.
+//
+// A Sink belongs to one test : tests using their own sink can run in parallel, nothing global being changed.
+package logtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Sink holds the log lines written to it, see New
+type Sink struct {
+	sync.Mutex
+	lines [][]byte
+}
+
+// Entry is a recorded log line, with its fields as decoded from JSON
+type Entry map[string]any
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// New returns an empty sink, whose lines are written in the output of t if it fails.
+//
+// The logger middleware writes the logs of its requests to the sink given as its Output, at trace level whatever its
+// Level and the global level of zerolog are.
+//
+// Example :
+//
+//	sink := logtest.New(t)
+//	Logger.Output = sink
+//	response, err := lambda.TestHandleRequest(HandleRequest, &request)
+//	sink.AssertLogged(t, zerolog.WarnLevel, "Rollback main transaction", nil)
+func New(t testing.TB) *Sink {
+	s := &Sink{}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("Logs :\n%v", s.String())
+		}
+	})
+	return s
+}
+
+func (s *Sink) Write(p []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.lines = append(s.lines, append([]byte(nil), p...)) // zerolog reuses p
+	return len(p), nil
+}
+
+// Level returns the level of the entry, "" if it has none
+func (e Entry) Level() string {
+	level, _ := e[zerolog.LevelFieldName].(string)
+	return level
+}
+
+// Message returns the message of the entry
+func (e Entry) Message() string {
+	message, _ := e[zerolog.MessageFieldName].(string)
+	return message
+}
+
+// Entries returns the lines recorded so far, the ones which are not JSON being skipped
+func (s *Sink) Entries() []Entry {
+	s.Lock()
+	defer s.Unlock()
+	entries := make([]Entry, 0, len(s.lines))
+	for _, line := range s.lines {
+		var entry Entry
+		if json.Unmarshal(line, &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Find returns the entries of level with message, which have fields with the same values.
+// An empty message matches any message.
+func (s *Sink) Find(level zerolog.Level, message string, fields map[string]any) []Entry {
+	var found []Entry
+	for _, entry := range s.Entries() {
+		if entry.Level() == level.String() && (message == "" || entry.Message() == message) && entry.has(fields) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// has tells if e has fields, compared as JSON so that the numbers of the log lines match any numeric type
+func (e Entry) has(fields map[string]any) bool {
+	for key, value := range fields {
+		actual, ok := e[key]
+		if !ok {
+			return false
+		}
+		a, _ := json.Marshal(actual)
+		v, _ := json.Marshal(value)
+		if !bytes.Equal(a, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset forgets the lines recorded so far
+func (s *Sink) Reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.lines = nil
+}
+
+// String returns the lines recorded so far
+func (s *Sink) String() string {
+	s.Lock()
+	defer s.Unlock()
+	var b strings.Builder
+	for _, line := range s.lines {
+		b.Write(line)
+	}
+	return b.String()
+}
+
+// AssertLogged fails t if no entry of level with message has fields, see Find. It returns true if one has.
+func (s *Sink) AssertLogged(t testing.TB, level zerolog.Level, message string, fields map[string]any) bool {
+	t.Helper()
+	if len(s.Find(level, message, fields)) > 0 {
+		return true
+	}
+	t.Errorf("No %v log %q with %v in :\n%v", level, message, fields, s.String())
+	return false
+}
+
+// AssertNotLogged fails t if an entry of level with message has fields, see Find. It returns true if none has.
+func (s *Sink) AssertNotLogged(t testing.TB, level zerolog.Level, message string, fields map[string]any) bool {
+	t.Helper()
+	found := s.Find(level, message, fields)
+	if len(found) == 0 {
+		return true
+	}
+	t.Errorf("Unexpected %v log %q with %v : %v", level, message, fields, found)
+	return false
+}
@@ -0,0 +1,48 @@
+package logtest_test
+
+import (
+	"testing"
+
+	"github.com/lambadass-2024/backend/internal/frameworks/logger/logtest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LogTest_Sink(t *testing.T) {
+	t.Parallel()
+	sink := logtest.New(t)
+	_, err := sink.Write([]byte(`{"level":"warn","sql":"Postgres","code":"UNIQUE_VIOLATION","rows":2,"message":"Rollback main transaction"}` + "\n"))
+	require.NoError(t, err)
+	_, err = sink.Write([]byte("Not JSON\n"))
+	require.NoError(t, err)
+
+	sink.AssertLogged(t, zerolog.WarnLevel, "Rollback main transaction", map[string]any{"code": "UNIQUE_VIOLATION", "rows": 2, "sql": "Postgres"})
+	sink.AssertLogged(t, zerolog.WarnLevel, "", nil)
+	sink.AssertNotLogged(t, zerolog.ErrorLevel, "Rollback main transaction", nil)
+	assert.Empty(t, sink.Find(zerolog.WarnLevel, "Rollback main transaction", map[string]any{"rows": 3}))
+	assert.Len(t, sink.Entries(), 1, "The lines which are not JSON are skipped")
+
+	mock := &testing.T{}
+	assert.False(t, sink.AssertLogged(mock, zerolog.InfoLevel, "Commit", nil))
+	assert.False(t, sink.AssertNotLogged(mock, zerolog.WarnLevel, "", map[string]any{"code": "UNIQUE_VIOLATION"}))
+
+	sink.Reset()
+	assert.Empty(t, sink.Entries())
+}
+
+// Each test has its own sink, the lines written to one are not seen by the others
+func Test_LogTest_Sink_Parallel(t *testing.T) {
+	for _, message := range []string{"first", "second", "third"} {
+		t.Run(message, func(t *testing.T) {
+			t.Parallel()
+			sink := logtest.New(t)
+			logger := zerolog.New(sink)
+			logger.Log().Str(zerolog.LevelFieldName, "info").Msg(message)
+			entries := sink.Entries()
+			require.Len(t, entries, 1)
+			assert.Equal(t, message, entries[0].Message())
+			assert.Equal(t, "info", entries[0].Level())
+		})
+	}
+}
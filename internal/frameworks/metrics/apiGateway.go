@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultNamespace = "lambadass-2024"
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// APIGatewayClient records the metrics of each API Gateway request and flushes them, with the ones recorded
+// by the other layers, once the response is known :
+//   - Latency, in milliseconds
+//   - Status2xx, Status3xx, Status4xx and Status5xx, counting the responses by class
+//   - Faults, counting the failed requests, also sent with the FaultCode dimension
+//   - ColdStart, 1 for the first request of an instance
+//   - SQLQueries and SQLDuration, recorded by the SQL framework
+//
+// Metrics have the FunctionName and Route dimensions. The status code is a property of the EMF log lines,
+// which carry the trace context like any log line.
+//
+// Use it right before the Lambda middleware, see the middleware order in readme.md : its OnAfter sees the response
+// the Lambda middleware finished, a 304 or the KO response of a fault, whose code is the one recorded for the logger.
+type APIGatewayClient struct {
+	Namespace    string          // CloudWatch namespace of the metrics, defaults to DefaultNamespace
+	Logger       *zerolog.Logger // Writes the EMF log lines, defaults to log.Logger
+	logger       *zerolog.Logger
+	functionName string
+	coldStart    bool      // Next request is the first one of the instance
+	start        time.Time // Start of the current request
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Route returns the route of request, its resource with path parameters left as placeholders
+func Route(request *events.APIGatewayProxyRequest) string {
+	route := request.Resource
+	if route == "" {
+		route = request.Path
+	}
+	return request.HTTPMethod + " " + route
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("framework", "METRICS")
+	m.logger.Trace().Msg("OnSetup")
+	if m.Namespace == "" {
+		m.Namespace = DefaultNamespace
+	}
+	if m.Logger == nil {
+		m.Logger = &log.Logger
+	}
+	m.functionName = os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if m.functionName == "" {
+		m.functionName = "local"
+	}
+	m.coldStart = true
+	return nil
+}
+
+func (m *APIGatewayClient) OnBefore(_ context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("framework", "METRICS")
+	m.logger.Trace().Msg("OnBefore")
+	m.start = time.Now()
+	Reset()
+	SetDimension("FunctionName", m.functionName)
+	SetDimension("Route", Route(request))
+	if m.coldStart {
+		Add("ColdStart", 1)
+		m.coldStart = false
+	}
+	return nil
+}
+
+// OnAfter records the latency and the status of the request, then flushes the metrics of the invocation
+func (m *APIGatewayClient) OnAfter(response *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	Duration("Latency", m.start)
+	if response != nil {
+		status := response.StatusCode
+		if err != nil { // The fault did not become a response
+			status = fault.StatusCode(err)
+		}
+		Add("Status"+strconv.Itoa(status/100)+"xx", 1)
+		SetProperty("statusCode", status)
+	}
+	if code := loggerframework.FaultCode(err); code != "" {
+		Add("Faults", 1)
+		AddDimensionSet(map[string]string{"FunctionName": m.functionName, "FaultCode": code})
+	}
+	Flush(m.Logger, m.Namespace)
+	return err
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
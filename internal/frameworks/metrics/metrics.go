@@ -0,0 +1,231 @@
+// Package metrics records the metrics of an invocation and writes them as CloudWatch Embedded Metric Format
+// (https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html)
+// log lines, which CloudWatch turns into metrics without any call to its API.
+//
+// Like the logger, the metrics of the current invocation are kept in the package, so use cases record theirs
+// without holding the middleware :
+//
+//	metrics.Add("PetCreated", 1)
+//	metrics.Record("PetNameLength", float64(len(pet.Name)), metrics.None)
+package metrics
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type Unit string
+
+// Units of CloudWatch, see https://docs.aws.amazon.com/AmazonCloudWatch/latest/APIReference/API_MetricDatum.html
+const (
+	Count        Unit = "Count"
+	Milliseconds Unit = "Milliseconds"
+	Seconds      Unit = "Seconds"
+	Bytes        Unit = "Bytes"
+	Percent      Unit = "Percent"
+	None         Unit = "None"
+)
+
+// Limits of a single EMF document
+const (
+	maxMetricsPerDocument = 100
+	maxValuesPerMetric    = 100
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// metric is a counter, summed during the invocation, or a histogram keeping every value
+type metric struct {
+	unit   Unit
+	values []float64
+}
+
+// invocation holds what is recorded until Flush
+type invocation struct {
+	sync.Mutex
+	dimensions map[string]string
+	extraSets  [][]string        // Other combinations of dimensions the metrics are sent with
+	extraDims  map[string]string // Values of the dimensions of extraSets, which are not in every combination
+	properties map[string]any
+	metrics    map[string]*metric
+}
+
+// emfDocument is a log line of Flush : its _aws metadata and the values of its metrics, dimensions and properties
+type emfDocument struct {
+	metadata []byte
+	fields   map[string]any
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string            `json:"Namespace"`
+	Dimensions [][]string        `json:"Dimensions"`
+	Metrics    []emfMetricDefine `json:"Metrics"`
+}
+
+type emfMetricDefine struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+var current = newInvocation()
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func newInvocation() *invocation {
+	return &invocation{dimensions: map[string]string{}, extraDims: map[string]string{}, properties: map[string]any{}, metrics: map[string]*metric{}}
+}
+
+// Add adds value to the counter name, sent once per invocation with the sum of its values
+func Add(name string, value float64) {
+	current.Lock()
+	defer current.Unlock()
+	m := current.metric(name, Count)
+	if len(m.values) == 0 {
+		m.values = []float64{0}
+	}
+	m.values[0] += value
+}
+
+// Record adds value to the histogram name, CloudWatch computing its statistics (average, percentiles...)
+func Record(name string, value float64, unit Unit) {
+	current.Lock()
+	defer current.Unlock()
+	m := current.metric(name, unit)
+	m.values = append(m.values, value)
+}
+
+// Duration records the time elapsed since start in the histogram name, in milliseconds
+func Duration(name string, start time.Time) {
+	Record(name, float64(time.Since(start).Microseconds())/1000, Milliseconds)
+}
+
+// SetDimension adds a dimension to every metric of the invocation. Each value of a dimension is a distinct metric
+// billed by CloudWatch, so values must be few : a route, a status class, never an ID.
+func SetDimension(key, value string) {
+	current.Lock()
+	defer current.Unlock()
+	current.dimensions[key] = value
+}
+
+// AddDimensionSet sends every metric of the invocation with the dimensions too, on top of the ones of SetDimension.
+// The fault code of a failed invocation is such a set, so the others are not split by fault code.
+func AddDimensionSet(dimensions map[string]string) {
+	current.Lock()
+	defer current.Unlock()
+	for key, value := range dimensions {
+		current.extraDims[key] = value
+	}
+	current.extraSets = append(current.extraSets, sortedKeys(dimensions))
+}
+
+// SetProperty adds a field to the EMF log lines, not a dimension, so it can have any value (a request ID...)
+// and is searched with CloudWatch Logs Insights
+func SetProperty(key string, value any) {
+	current.Lock()
+	defer current.Unlock()
+	current.properties[key] = value
+}
+
+// Reset drops what was recorded, at the start of an invocation
+func Reset() {
+	current.Lock()
+	inv := current
+	current = newInvocation()
+	inv.Unlock()
+}
+
+// Flush writes what was recorded since the last Reset as EMF documents in namespace, through logger, and resets it.
+// Documents respect the limits of EMF, a histogram with many values being split across several of them.
+func Flush(logger *zerolog.Logger, namespace string) {
+	current.Lock()
+	inv := current
+	current = newInvocation()
+	inv.Unlock()
+
+	for _, document := range inv.documents(namespace, time.Now()) {
+		logger.Log().RawJSON("_aws", document.metadata).Fields(document.fields).Msg("")
+	}
+}
+
+func (inv *invocation) metric(name string, unit Unit) *metric {
+	m, ok := inv.metrics[name]
+	if !ok {
+		m = &metric{unit: unit}
+		inv.metrics[name] = m
+	}
+	return m
+}
+
+// documents returns the EMF documents of inv
+func (inv *invocation) documents(namespace string, now time.Time) []emfDocument {
+	names := sortedKeys(inv.metrics)
+	dimensions := [][]string{sortedKeys(inv.dimensions)}
+	for _, set := range inv.extraSets {
+		dimensions = append(dimensions, set)
+	}
+
+	var documents []emfDocument
+	for offset := 0; ; offset += maxValuesPerMetric {
+		var pending []string
+		for _, name := range names {
+			if len(inv.metrics[name].values) > offset {
+				pending = append(pending, name)
+			}
+		}
+		if len(pending) == 0 {
+			return documents
+		}
+		for start := 0; start < len(pending); start += maxMetricsPerDocument {
+			chunk := pending[start:min(len(pending), start+maxMetricsPerDocument)]
+			documents = append(documents, inv.document(namespace, now, dimensions, chunk, offset))
+		}
+	}
+}
+
+func (inv *invocation) document(namespace string, now time.Time, dimensions [][]string, names []string, offset int) emfDocument {
+	directive := emfDirective{Namespace: namespace, Dimensions: dimensions}
+	document := map[string]any{}
+	for key, value := range inv.properties {
+		document[key] = value
+	}
+	for key, value := range inv.extraDims {
+		document[key] = value
+	}
+	for key, value := range inv.dimensions {
+		document[key] = value
+	}
+	for _, name := range names {
+		m := inv.metrics[name]
+		directive.Metrics = append(directive.Metrics, emfMetricDefine{Name: name, Unit: m.unit})
+		values := m.values[offset:min(len(m.values), offset+maxValuesPerMetric)]
+		if len(values) == 1 {
+			document[name] = values[0]
+		} else {
+			document[name] = values
+		}
+	}
+	metadata, _ := json.Marshal(emfMetadata{Timestamp: now.UnixMilli(), CloudWatchMetrics: []emfDirective{directive}})
+	return emfDocument{metadata: metadata, fields: document}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
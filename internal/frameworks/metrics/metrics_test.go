@@ -0,0 +1,192 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	lambdaframework "github.com/lambadass-2024/backend/internal/frameworks/lambda"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/metrics"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type directive struct {
+	Namespace  string
+	Dimensions [][]string
+	Metrics    []struct{ Name, Unit string }
+}
+
+type document struct {
+	AWS struct {
+		Timestamp         int64
+		CloudWatchMetrics []directive
+	} `json:"_aws"`
+	Fields map[string]any `json:"-"`
+}
+
+// flushed returns the EMF documents written in buffer
+func flushed(t *testing.T, buffer *bytes.Buffer) []document {
+	t.Helper()
+	var documents []document
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		var d document
+		require.NoError(t, json.Unmarshal([]byte(line), &d))
+		require.NoError(t, json.Unmarshal([]byte(line), &d.Fields))
+		documents = append(documents, d)
+	}
+	return documents
+}
+
+func newLogger() (*zerolog.Logger, *bytes.Buffer) {
+	zerolog.SetGlobalLevel(zerolog.NoLevel) // Only the EMF log lines
+	buffer := &bytes.Buffer{}
+	logger := zerolog.New(buffer)
+	return &logger, buffer
+}
+
+func Test_Metrics_Flush(t *testing.T) {
+	logger, buffer := newLogger()
+	metrics.Reset()
+	metrics.SetDimension("Route", "GET /pet")
+	metrics.SetProperty("requestId", "abc")
+	metrics.Add("PetCreated", 1)
+	metrics.Add("PetCreated", 2)
+	metrics.Record("Size", 10, metrics.Bytes)
+	metrics.Record("Size", 20, metrics.Bytes)
+	metrics.Flush(logger, "test")
+
+	documents := flushed(t, buffer)
+	require.Len(t, documents, 1)
+	d := documents[0]
+	require.Len(t, d.AWS.CloudWatchMetrics, 1)
+	assert.InDelta(t, time.Now().UnixMilli(), d.AWS.Timestamp, 5000)
+	assert.Equal(t, "test", d.AWS.CloudWatchMetrics[0].Namespace)
+	assert.Equal(t, [][]string{{"Route"}}, d.AWS.CloudWatchMetrics[0].Dimensions)
+	assert.Equal(t, []struct{ Name, Unit string }{{"PetCreated", "Count"}, {"Size", "Bytes"}}, d.AWS.CloudWatchMetrics[0].Metrics)
+	assert.Equal(t, "GET /pet", d.Fields["Route"])
+	assert.Equal(t, "abc", d.Fields["requestId"])
+	assert.Equal(t, 3.0, d.Fields["PetCreated"])
+	assert.Equal(t, []any{10.0, 20.0}, d.Fields["Size"])
+
+	buffer.Reset()
+	metrics.Flush(logger, "test")
+	assert.Empty(t, buffer.String(), "Flush resets the metrics")
+}
+
+func Test_Metrics_Flush_Limits(t *testing.T) {
+	logger, buffer := newLogger()
+	metrics.Reset()
+	for i := range 150 {
+		metrics.Add("Counter"+strconv.Itoa(i), 1)
+	}
+	for i := range 250 {
+		metrics.Record("Histogram", float64(i), metrics.None)
+	}
+	metrics.Flush(logger, "test")
+
+	documents := flushed(t, buffer)
+	require.Len(t, documents, 4) // 151 metrics with their first value, then 100 and 50 values of Histogram
+	assert.Len(t, documents[0].AWS.CloudWatchMetrics[0].Metrics, 100)
+	assert.Len(t, documents[1].AWS.CloudWatchMetrics[0].Metrics, 51)
+	assert.Len(t, documents[1].Fields["Histogram"], 100)
+	assert.Len(t, documents[2].Fields["Histogram"], 100)
+	assert.Len(t, documents[3].Fields["Histogram"], 50)
+	assert.Equal(t, 249.0, documents[3].Fields["Histogram"].([]any)[49])
+}
+
+func Test_Metrics_APIGateway_OK(t *testing.T) {
+	logger, buffer := newLogger()
+	m := &metrics.APIGatewayClient{Namespace: "test", Logger: logger}
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Resource: "/pet/{id}", Path: "/pet/1"}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+
+	for _, coldStart := range []bool{true, false} {
+		buffer.Reset()
+		require.NoError(t, m.OnBefore(context.Background(), request))
+		metrics.Add("SQLQueries", 1)
+		require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, nil))
+
+		documents := flushed(t, buffer)
+		require.Len(t, documents, 1)
+		d := documents[0]
+		assert.Equal(t, [][]string{{"FunctionName", "Route"}}, d.AWS.CloudWatchMetrics[0].Dimensions)
+		assert.Equal(t, "local", d.Fields["FunctionName"])
+		assert.Equal(t, "GET /pet/{id}", d.Fields["Route"])
+		assert.Equal(t, 200.0, d.Fields["statusCode"])
+		assert.Equal(t, 1.0, d.Fields["Status2xx"])
+		assert.Equal(t, 1.0, d.Fields["SQLQueries"])
+		assert.Contains(t, d.Fields, "Latency")
+		assert.NotContains(t, d.Fields, "Faults")
+		if coldStart {
+			assert.Equal(t, 1.0, d.Fields["ColdStart"])
+		} else {
+			assert.NotContains(t, d.Fields, "ColdStart")
+		}
+	}
+}
+
+func Test_Metrics_APIGateway_Fault(t *testing.T) {
+	logger, buffer := newLogger()
+	m := &metrics.APIGatewayClient{Logger: logger}
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/pet"}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	flt := fault.NewValidatorFault("MALFORMED_JSON", "Malformed JSON", nil, nil)
+	assert.Equal(t, flt, m.OnAfter(&events.APIGatewayProxyResponse{}, flt))
+
+	documents := flushed(t, buffer)
+	require.Len(t, documents, 1)
+	d := documents[0]
+	assert.Equal(t, metrics.DefaultNamespace, d.AWS.CloudWatchMetrics[0].Namespace)
+	assert.Equal(t, [][]string{{"FunctionName", "Route"}, {"FaultCode", "FunctionName"}}, d.AWS.CloudWatchMetrics[0].Dimensions)
+	assert.Equal(t, "MALFORMED_JSON", d.Fields["FaultCode"])
+	assert.Equal(t, "POST /pet", d.Fields["Route"])
+	assert.Equal(t, 400.0, d.Fields["statusCode"])
+	assert.Equal(t, 1.0, d.Fields["Status4xx"])
+	assert.Equal(t, 1.0, d.Fields["Faults"])
+}
+
+// The metrics are used before the Lambda middleware, so they count the responses it finished
+func Test_Metrics_APIGateway_AfterLambda(t *testing.T) {
+	logger, buffer := newLogger()
+	m := &metrics.APIGatewayClient{Logger: logger}
+	request := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/pet/1", Headers: map[string]string{}}
+	apiGateway := lambdaframework.APIGatewayClient{Lambda: lambdaframework.TestNewLambda(request), EnableETag: true}
+	require.NoError(t, m.OnSetup(context.Background(), request))
+
+	loggerframework.RecordFault("") // The logger resets it in its OnBefore
+	sent, _ := apiGateway.OK(map[string]string{"name": "Rex"})
+	request.Headers["If-None-Match"] = sent.Headers["ETag"]
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	response, _ := apiGateway.OK(map[string]string{"name": "Rex"})
+	require.NoError(t, apiGateway.OnAfter(&response, nil))
+	require.NoError(t, m.OnAfter(&response, nil))
+
+	documents := flushed(t, buffer)
+	require.Len(t, documents, 1)
+	assert.Equal(t, 304.0, documents[0].Fields["statusCode"])
+	assert.Equal(t, 1.0, documents[0].Fields["Status3xx"])
+	assert.NotContains(t, documents[0].Fields, "Faults")
+
+	buffer.Reset()
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	response = events.APIGatewayProxyResponse{}
+	require.NoError(t, apiGateway.OnAfter(&response, fault.NewUseCase("PetUseCase", "PET_NOT_FOUND", "Pet not found", nil, nil)))
+	require.NoError(t, m.OnAfter(&response, nil))
+
+	documents = flushed(t, buffer)
+	require.Len(t, documents, 1)
+	assert.Equal(t, 404.0, documents[0].Fields["statusCode"])
+	assert.Equal(t, 1.0, documents[0].Fields["Status4xx"])
+	assert.Equal(t, 1.0, documents[0].Fields["Faults"])
+	assert.Equal(t, "PET_NOT_FOUND", documents[0].Fields["FaultCode"])
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+	"github.com/lambadass-2024/backend/internal/redact"
+	"github.com/rs/zerolog"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// APIGatewayClient reports the API Gateway requests failing with a 5xx fault to Sink.
+// An info log line links the request to its event, with the event ID and the fingerprint.
+//
+// Its OnAfter must get the faults before the Lambda middleware turns them into KO responses, and those of every
+// other middleware : it comes right after the Lambda middleware, so that its OnAfter is the last one before it.
+type APIGatewayClient struct {
+	Sink       Sink          // Defaults to NewSinkFromEnv, nothing being reported without sink
+	RateLimit  int           // Events sent per fingerprint and RateWindow, defaults to DefaultRateLimit
+	RateWindow time.Duration // Defaults to DefaultRateWindow
+	logger     *zerolog.Logger
+	limiter    *limiter
+	function   string
+	request    Request
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func newRequest(ctx context.Context, request *events.APIGatewayProxyRequest) Request {
+	route := request.Resource
+	if route == "" {
+		route = request.Path
+	}
+	r := Request{
+		Method:    request.HTTPMethod,
+		Route:     route,
+		Path:      request.Path,
+		Headers:   redact.Headers(request.Headers),
+		SourceIP:  request.RequestContext.Identity.SourceIP,
+		UserAgent: request.RequestContext.Identity.UserAgent,
+		RequestID: request.RequestContext.RequestID,
+	}
+	if len(request.QueryStringParameters) > 0 {
+		r.Query = make(map[string]string, len(request.QueryStringParameters))
+		for key, value := range request.QueryStringParameters {
+			if redacted, ok := redact.Field(key, value).(string); ok {
+				r.Query[key] = redact.String(redacted)
+			}
+		}
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		r.AwsRequestID = lc.AwsRequestID
+	}
+	return r
+}
+
+// report sends the event of flt, unless the rate limit of its fingerprint is reached
+func (m *APIGatewayClient) report(flt fault.Fault, statusCode int) {
+	tc := trace.Current()
+	m.request.CorrelationID, m.request.TraceID, m.request.SpanID = tc.RequestID, tc.TraceID, tc.SpanID
+	event := NewEvent(flt, statusCode, m.function+" "+m.request.Method+" "+m.request.Route, m.request)
+	allowed, suppressed := m.limiter.allow(event.Fingerprint, event.Timestamp)
+	if !allowed {
+		m.logger.Debug().Str("fingerprint", event.Fingerprint).Msg("Fault not reported, rate limit of its fingerprint reached")
+		return
+	}
+	event.Suppressed = suppressed
+	if err := m.Sink.Send(event); err != nil {
+		m.logger.Warn().Err(err).Str("fingerprint", event.Fingerprint).Msg("Cannot report the fault")
+		return
+	}
+	m.logger.Info().Str("eventId", event.ID).Str("fingerprint", event.Fingerprint).Msg("Fault reported")
+}
+
+/******************************************************************************
+***** Middleware
+******************************************************************************/
+
+func (m *APIGatewayClient) OnSetup(_ context.Context, _ *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("framework", "REPORT")
+	m.logger.Trace().Msg("OnSetup")
+	if m.Sink == nil {
+		m.Sink = NewSinkFromEnv()
+	}
+	if m.RateLimit <= 0 {
+		m.RateLimit = DefaultRateLimit
+	}
+	if m.RateWindow <= 0 {
+		m.RateWindow = DefaultRateWindow
+	}
+	m.limiter = newLimiter(m.RateLimit, m.RateWindow)
+	m.function = os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if m.function == "" {
+		m.function = "local"
+	}
+	return nil
+}
+
+func (m *APIGatewayClient) OnBefore(ctx context.Context, request *events.APIGatewayProxyRequest) fault.Fault {
+	m.logger = loggerframework.Child("framework", "REPORT")
+	m.logger.Trace().Msg("OnBefore")
+	m.request = newRequest(ctx, request)
+	return nil
+}
+
+// OnAfter reports err if the response is a 5xx
+func (m *APIGatewayClient) OnAfter(_ *events.APIGatewayProxyResponse, err fault.Fault) fault.Fault {
+	m.logger.Trace().Msg("OnAfter")
+	if err == nil || m.Sink == nil {
+		return err
+	}
+	if statusCode := fault.StatusCode(err); statusCode >= 500 {
+		m.report(err, statusCode)
+	}
+	return err
+}
+
+func (m *APIGatewayClient) OnShutdown() {
+	m.logger.Trace().Msg("OnShutdown")
+}
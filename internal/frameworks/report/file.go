@@ -0,0 +1,39 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// FileSink appends the events to a file, one JSON object per line, as /tmp/errors.jsonl for a local run
+type FileSink struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func (s *FileSink) Send(event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
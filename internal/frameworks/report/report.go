@@ -0,0 +1,230 @@
+// Package report sends the faults of the failed requests to an error tracker, grouped by fingerprint.
+//
+// A request failing with a 5xx fault becomes an Event : the chain of its faults, the stack where the failure
+// started, the context of the request and a fingerprint grouping the events of a same failure.
+// Events go to a Sink, a Sentry compatible error tracker or a local file, at most RateLimit times per fingerprint
+// and RateWindow.
+package report
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/redact"
+)
+
+const (
+	DefaultRateLimit  = 5
+	DefaultRateWindow = time.Minute
+
+	maxFingerprints = 1000 // The windows of the fingerprints are forgotten beyond
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Sink receives the events of the failed requests
+type Sink interface {
+	Send(event *Event) error
+}
+
+// Event is a failed request, as reported to a Sink
+type Event struct {
+	ID          string    `json:"id"` // 32 hex characters
+	Timestamp   time.Time `json:"timestamp"`
+	Fingerprint string    `json:"fingerprint"` // Hash of Code, Layer and Handler, the same for the events of a failure
+	Code        string    `json:"code"`        // Code of the fault where the failure started, the last of Chain
+	Layer       string    `json:"layer"`
+	Middleware  string    `json:"middleware"`
+	Handler     string    `json:"handler"` // Function and route of the request
+	Message     string    `json:"message"` // Message of the fault of the response
+	StatusCode  int       `json:"statusCode"`
+	Chain       []Cause   `json:"chain"`           // From the fault of the response to the error where it started
+	Stack       []Frame   `json:"stack,omitempty"` // Where an error became a fault, the innermost call first
+	Request     Request   `json:"request"`
+	Environment string    `json:"environment,omitempty"`
+	Suppressed  int       `json:"suppressed,omitempty"` // Events of the fingerprint dropped by the rate limit before this one
+}
+
+// Cause is a fault, or the error causing the last fault, of the chain of an Event
+type Cause struct {
+	Type       string         `json:"type"` // Go type, as *fault.SQLFault
+	Code       string         `json:"code,omitempty"`
+	Layer      string         `json:"layer,omitempty"`
+	Middleware string         `json:"middleware,omitempty"`
+	Message    string         `json:"message"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+}
+
+// Frame is a call of the Stack of an Event
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Request is the context of the request of an Event, its credentials and secrets being masked
+type Request struct {
+	Method        string            `json:"method"`
+	Route         string            `json:"route"`
+	Path          string            `json:"path"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Query         map[string]string `json:"query,omitempty"`
+	SourceIP      string            `json:"sourceIp,omitempty"`
+	UserAgent     string            `json:"userAgent,omitempty"`
+	RequestID     string            `json:"requestId,omitempty"`
+	AwsRequestID  string            `json:"awsRequestId,omitempty"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	TraceID       string            `json:"traceId,omitempty"`
+	SpanID        string            `json:"spanId,omitempty"`
+}
+
+// limiter lets through limit events per fingerprint and window
+type limiter struct {
+	sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*window
+}
+
+type window struct {
+	start      time.Time
+	sent       int
+	suppressed int
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// NewSinkFromEnv returns a SentrySink if SENTRY_DSN is set, else a FileSink if ERROR_REPORT_FILE is set,
+// else nil, the events not being sent.
+func NewSinkFromEnv() Sink {
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		return &SentrySink{DSN: dsn}
+	}
+	if path := os.Getenv("ERROR_REPORT_FILE"); path != "" {
+		return &FileSink{Path: path}
+	}
+	return nil
+}
+
+// NewEvent returns the event of a request failing with flt, answered with statusCode
+func NewEvent(flt fault.Fault, statusCode int, handler string, request Request) *Event {
+	chain, origin, stack := causes(flt)
+	event := &Event{
+		ID:          newID(),
+		Timestamp:   time.Now().UTC(),
+		Code:        origin.Code(),
+		Layer:       origin.Layer().String(),
+		Middleware:  origin.Middleware(),
+		Handler:     handler,
+		Message:     flt.Message(),
+		StatusCode:  statusCode,
+		Chain:       chain,
+		Stack:       stack,
+		Request:     request,
+		Environment: os.Getenv("ENVIRONMENT"),
+	}
+	event.Fingerprint = Fingerprint(event.Code, event.Layer, event.Handler)
+	return event
+}
+
+// Fingerprint returns the hash grouping the events of a same failure
+func Fingerprint(code, layer, handler string) string {
+	sum := sha256.Sum256([]byte(code + "\x00" + layer + "\x00" + handler))
+	return hex.EncodeToString(sum[:8])
+}
+
+// causes walks the chain of flt, returning its causes, the fault where the failure started and its stack
+func causes(flt fault.Fault) ([]Cause, fault.Fault, []Frame) {
+	var chain []Cause
+	var stack []Frame
+	origin := flt
+	var err error = flt
+	for err != nil {
+		f, ok := err.(fault.Fault)
+		if !ok {
+			chain = append(chain, Cause{Type: fmt.Sprintf("%T", err), Message: redact.String(err.Error())})
+			err = errors.Unwrap(err)
+			continue
+		}
+		origin = f
+		chain = append(chain, Cause{
+			Type:       fmt.Sprintf("%T", f),
+			Code:       f.Code(),
+			Layer:      f.Layer().String(),
+			Middleware: f.Middleware(),
+			Message:    f.Message(),
+			Metadata:   f.Metadata(),
+		})
+		if tracer, ok := f.(fault.StackTracer); ok && len(tracer.StackTrace()) > 0 {
+			stack = frames(tracer.StackTrace())
+		}
+		err = f.Cause()
+	}
+	return chain, origin, stack
+}
+
+func frames(stack fault.Stack) []Frame {
+	var converted []Frame
+	for _, frame := range stack.Frames() {
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			continue
+		}
+		converted = append(converted, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+	}
+	return converted
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newLimiter(limit int, length time.Duration) *limiter {
+	return &limiter{limit: limit, window: length, windows: map[string]*window{}}
+}
+
+// allow tells if an event of fingerprint can be sent at now, with the number of events suppressed before it
+func (l *limiter) allow(fingerprint string, now time.Time) (bool, int) {
+	l.Lock()
+	defer l.Unlock()
+	w, ok := l.windows[fingerprint]
+	if !ok || now.Sub(w.start) >= l.window {
+		if len(l.windows) >= maxFingerprints {
+			l.forget(now)
+		}
+		suppressed := 0
+		if ok {
+			suppressed = w.suppressed
+		}
+		l.windows[fingerprint] = &window{start: now, sent: 1}
+		return true, suppressed
+	}
+	if w.sent >= l.limit {
+		w.suppressed++
+		return false, 0
+	}
+	w.sent++
+	return true, 0
+}
+
+// forget drops the windows ended at now
+func (l *limiter) forget(now time.Time) {
+	for fingerprint, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, fingerprint)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package report_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/frameworks/report"
+	"github.com/lambadass-2024/backend/internal/frameworks/report/reporttest"
+	"github.com/lambadass-2024/backend/internal/redact"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var request = &events.APIGatewayProxyRequest{
+	HTTPMethod:            "POST",
+	Resource:              "/pet",
+	Path:                  "/pet",
+	Headers:               map[string]string{"Authorization": "Bearer s3cr3t", "Accept": "application/json"},
+	QueryStringParameters: map[string]string{"token": "t0k3n", "page": "2"},
+	RequestContext:        events.APIGatewayProxyRequestContext{RequestID: "request-1"},
+}
+
+// newFault returns the fault of a request failing in the database
+func newFault(statusCode int) fault.Fault {
+	sql := fault.NewSQL("CONNECTION_FAILED", "Cannot connect", map[string]any{"password": "hunter2"}, errors.New("dial tcp: connection refused"))
+	repository := fault.NewRepository("PetRepository", "PET_CREATE_FAILED", "Cannot create the pet", nil, sql)
+	usecase := fault.NewUseCase("PetUseCases", "PET_CREATE_FAILED", "Cannot create the pet", nil, repository)
+	return fault.NewAPIGatewayFromFault(statusCode, usecase)
+}
+
+func newReport(t *testing.T, m *report.APIGatewayClient) *report.APIGatewayClient {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	require.NoError(t, m.OnSetup(context.Background(), request))
+	return m
+}
+
+func Test_Report_Sentry(t *testing.T) {
+	stub := reporttest.NewStub()
+	defer stub.Close()
+	m := newReport(t, &report.APIGatewayClient{Sink: &report.SentrySink{DSN: stub.DSN}})
+
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	flt := newFault(500)
+	assert.Equal(t, flt, m.OnAfter(&events.APIGatewayProxyResponse{}, flt))
+
+	received := stub.Events()
+	require.Len(t, received, 1)
+	event := received[0]
+	assert.Equal(t, []string{"CONNECTION_FAILED", "Adapters", "local POST /pet"}, event.Fingerprint)
+	assert.Equal(t, "500", event.Tags["status"])
+	assert.Equal(t, "Sql", event.Tags["middleware"])
+	assert.Equal(t, "POST", event.Request.Method)
+	assert.Equal(t, redact.Mask, event.Request.Headers["Authorization"])
+	assert.Equal(t, "request-1", event.Extra["requestId"])
+
+	require.Len(t, event.Exception.Values, 5, "The error and its 4 faults")
+	assert.Equal(t, "*errors.errorString", event.Exception.Values[0].Type)
+	assert.Equal(t, "[PET_CREATE_FAILED] Cannot create the pet", event.Exception.Values[4].Value)
+	require.NotNil(t, event.Exception.Values[0].Stacktrace, "The stack where the error became a fault")
+	frames := event.Exception.Values[0].Stacktrace.Frames
+	assert.Contains(t, frames[len(frames)-1].Function, "report_test.newFault")
+	assert.True(t, frames[len(frames)-1].InApp)
+
+	require.Len(t, stub.Auth(), 1)
+	assert.Contains(t, stub.Auth()[0], "sentry_key=public")
+	encoded, err := json.Marshal(received)
+	require.NoError(t, err)
+	redact.AssertNoLeak(t, string(encoded), "s3cr3t", "t0k3n", "hunter2")
+}
+
+func Test_Report_OnlyServerErrors(t *testing.T) {
+	stub := reporttest.NewStub()
+	defer stub.Close()
+	m := newReport(t, &report.APIGatewayClient{Sink: &report.SentrySink{DSN: stub.DSN}})
+
+	require.NoError(t, m.OnBefore(context.Background(), request))
+	_ = m.OnAfter(&events.APIGatewayProxyResponse{}, newFault(422))
+	require.NoError(t, m.OnAfter(&events.APIGatewayProxyResponse{StatusCode: 200}, nil))
+	assert.Empty(t, stub.Events())
+}
+
+func Test_Report_RateLimit(t *testing.T) {
+	stub := reporttest.NewStub()
+	defer stub.Close()
+	m := newReport(t, &report.APIGatewayClient{Sink: &report.SentrySink{DSN: stub.DSN}, RateLimit: 2, RateWindow: 50 * time.Millisecond})
+
+	for range 5 {
+		require.NoError(t, m.OnBefore(context.Background(), request))
+		_ = m.OnAfter(&events.APIGatewayProxyResponse{}, newFault(500))
+	}
+	require.Len(t, stub.Events(), 2)
+
+	time.Sleep(60 * time.Millisecond)
+	_ = m.OnAfter(&events.APIGatewayProxyResponse{}, newFault(500))
+	received := stub.Events()
+	require.Len(t, received, 3)
+	assert.Equal(t, 3.0, received[2].Extra["suppressed"])
+}
+
+func Test_Report_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.jsonl")
+	t.Setenv("ERROR_REPORT_FILE", path)
+	t.Setenv("SENTRY_DSN", "")
+	m := newReport(t, &report.APIGatewayClient{})
+
+	for range 2 {
+		require.NoError(t, m.OnBefore(context.Background(), request))
+		_ = m.OnAfter(&events.APIGatewayProxyResponse{}, newFault(503))
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var reported []report.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event report.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		reported = append(reported, event)
+	}
+	require.Len(t, reported, 2)
+	assert.Equal(t, reported[0].Fingerprint, reported[1].Fingerprint)
+	assert.NotEqual(t, reported[0].ID, reported[1].ID)
+	assert.Equal(t, "CONNECTION_FAILED", reported[0].Code)
+	assert.Equal(t, 503, reported[0].StatusCode)
+	assert.Equal(t, "*fault.APIGatewayProxyFault", reported[0].Chain[0].Type)
+	assert.Equal(t, map[string]any{"password": redact.Mask}, reported[0].Chain[3].Metadata)
+	assert.True(t, strings.HasSuffix(reported[0].Stack[0].Function, "report_test.newFault"))
+}
+
+func Test_Report_Fingerprint(t *testing.T) {
+	assert.Equal(t, report.Fingerprint("CODE", "Adapters", "pet-POST POST /pet"), report.Fingerprint("CODE", "Adapters", "pet-POST POST /pet"))
+	assert.NotEqual(t, report.Fingerprint("CODE", "Adapters", "pet-POST POST /pet"), report.Fingerprint("CODE", "Adapters", "pet-GET GET /pet"))
+	assert.NotEqual(t, report.Fingerprint("CODE", "Adapters", "h"), report.Fingerprint("CODE", "UseCases", "h"))
+}
@@ -0,0 +1,107 @@
+// Package reporttest receives the faults reported by the report package, to assert on them.
+package reporttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/lambadass-2024/backend/internal/frameworks/report"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Stub is an in-process stand-in of a Sentry compatible error tracker. It accepts the envelopes of the project 1 and
+// keeps their events and X-Sentry-Auth headers, to check what a SentrySink sends and how it authenticates.
+// Malformed envelopes get a 400, as do events whose ID is not the one of their envelope.
+//
+// Example :
+//
+//	stub := reporttest.NewStub()
+//	defer stub.Close()
+//	Report.Sink = &report.SentrySink{DSN: stub.DSN}
+//	...
+//	events := stub.Events()
+type Stub struct {
+	DSN    string // DSN of the sink, for the project 1 with the public key "public"
+	server *httptest.Server
+	mutex  sync.Mutex
+	events []report.SentryEvent
+	auth   []string
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func NewStub() *Stub {
+	s := &Stub{}
+	s.server = httptest.NewServer(http.HandlerFunc(s.receive))
+	s.DSN = strings.Replace(s.server.URL, "://", "://public@", 1) + "/1"
+	return s
+}
+
+// Events returns the events received so far
+func (s *Stub) Events() []report.SentryEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]report.SentryEvent(nil), s.events...)
+}
+
+// Auth returns the X-Sentry-Auth headers received so far
+func (s *Stub) Auth() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.auth...)
+}
+
+func (s *Stub) Close() {
+	s.server.Close()
+}
+
+// receive reads an envelope : a header line, then an item header line followed by its payload for each item
+func (s *Stub) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api/1/envelope/" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var header struct {
+		EventID string `json:"event_id"`
+	}
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &header) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var events []report.SentryEvent
+	for scanner.Scan() {
+		var item struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &item) != nil || !scanner.Scan() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if item.Type != "event" {
+			continue
+		}
+		var event report.SentryEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.EventID != header.EventID {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events = append(events, event)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, events...)
+	s.auth = append(s.auth, r.Header.Get("X-Sentry-Auth"))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"id":"` + header.EventID + `"}`))
+}
@@ -0,0 +1,221 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sentryClient  = "lambadass-2024/1.0"
+	sendTimeout   = 2 * time.Second
+	sentryVersion = "7"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// SentrySink sends the events to Sentry, or to any tracker accepting its envelopes, see
+// https://develop.sentry.dev/sdk/envelopes/
+type SentrySink struct {
+	DSN    string       // As https://<public key>@o0.ingest.sentry.io/<project id>
+	Client *http.Client // Defaults to a client with a 2 seconds timeout
+}
+
+// SentryEvent is the event item of an envelope, see https://develop.sentry.dev/sdk/event-payloads/
+type SentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Environment string            `json:"environment,omitempty"`
+	Transaction string            `json:"transaction"`
+	Fingerprint []string          `json:"fingerprint"`
+	Tags        map[string]string `json:"tags"`
+	Exception   SentryExceptions  `json:"exception"`
+	Request     SentryRequest     `json:"request"`
+	Contexts    map[string]any    `json:"contexts,omitempty"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+}
+
+// SentryExceptions are the causes of an event, the one where the failure started first
+type SentryExceptions struct {
+	Values []SentryException `json:"values"`
+}
+
+type SentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Module     string            `json:"module,omitempty"`
+	Stacktrace *SentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+// SentryStacktrace lists its frames from the outermost call to the innermost one
+type SentryStacktrace struct {
+	Frames []SentryFrame `json:"frames"`
+}
+
+type SentryFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type SentryRequest struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// endpoint returns the envelope endpoint and the public key of a DSN
+func endpoint(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid Sentry DSN")
+	}
+	prefix, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if project == "" {
+		return "", "", fmt.Errorf("invalid Sentry DSN : no project")
+	}
+	return u.Scheme + "://" + u.Host + strings.TrimSuffix(prefix, "/") + "/api/" + project + "/envelope/", u.User.Username(), nil
+}
+
+func (s *SentrySink) Send(event *Event) error {
+	target, key, err := endpoint(s.DSN)
+	if err != nil {
+		return err
+	}
+	body, err := envelope(s.DSN, event)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-sentry-envelope")
+	request.Header.Set("X-Sentry-Auth", "Sentry sentry_version="+sentryVersion+", sentry_client="+sentryClient+", sentry_key="+key)
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: sendTimeout}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body) // So the connection is reused
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("error tracker answered %v", response.Status)
+	}
+	return nil
+}
+
+// envelope encodes event as an envelope with a single event item
+func envelope(dsn string, event *Event) ([]byte, error) {
+	payload, err := json.Marshal(NewSentryEvent(event))
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(map[string]string{"event_id": event.ID, "dsn": dsn, "sent_at": time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.Write(header)
+	b.WriteString("\n")
+	b.WriteString(`{"type":"event","content_type":"application/json","length":` + strconv.Itoa(len(payload)) + "}\n")
+	b.Write(payload)
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// NewSentryEvent converts event to the payload of Sentry, grouped by its fingerprint
+func NewSentryEvent(event *Event) SentryEvent {
+	s := SentryEvent{
+		EventID:     event.ID,
+		Timestamp:   event.Timestamp.Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		Environment: event.Environment,
+		Transaction: event.Handler,
+		Fingerprint: []string{event.Code, event.Layer, event.Handler},
+		Tags: map[string]string{
+			"code":       event.Code,
+			"layer":      event.Layer,
+			"middleware": event.Middleware,
+			"handler":    event.Handler,
+			"status":     strconv.Itoa(event.StatusCode),
+		},
+		Request: SentryRequest{
+			Method:      event.Request.Method,
+			URL:         event.Request.Path,
+			QueryString: query(event.Request.Query),
+			Headers:     event.Request.Headers,
+		},
+		Extra: map[string]any{
+			"fingerprint":   event.Fingerprint,
+			"route":         event.Request.Route,
+			"requestId":     event.Request.RequestID,
+			"awsRequestId":  event.Request.AwsRequestID,
+			"correlationId": event.Request.CorrelationID,
+			"sourceIp":      event.Request.SourceIP,
+			"userAgent":     event.Request.UserAgent,
+		},
+	}
+	if event.Suppressed > 0 {
+		s.Extra["suppressed"] = event.Suppressed
+	}
+	if event.Request.TraceID != "" {
+		s.Contexts = map[string]any{"trace": map[string]string{"trace_id": event.Request.TraceID, "span_id": event.Request.SpanID}}
+	}
+	for i := len(event.Chain) - 1; i >= 0; i-- { // Sentry lists the causes first
+		cause := event.Chain[i]
+		exception := SentryException{Type: cause.Type, Value: cause.Message, Module: cause.Layer}
+		if cause.Code != "" {
+			exception.Value = "[" + cause.Code + "] " + cause.Message
+		}
+		if i == len(event.Chain)-1 && len(event.Stack) > 0 {
+			exception.Stacktrace = stacktrace(event.Stack)
+		}
+		s.Exception.Values = append(s.Exception.Values, exception)
+	}
+	return s
+}
+
+// stacktrace converts stack, the innermost call first, to the frames of Sentry, the outermost call first
+func stacktrace(stack []Frame) *SentryStacktrace {
+	frames := make([]SentryFrame, len(stack))
+	for i, frame := range stack {
+		frames[len(stack)-1-i] = SentryFrame{
+			Function: frame.Function,
+			AbsPath:  frame.File,
+			Lineno:   frame.Line,
+			InApp:    strings.HasPrefix(frame.Function, "github.com/lambadass-2024/backend/"),
+		}
+	}
+	return &SentryStacktrace{Frames: frames}
+}
+
+func query(parameters map[string]string) string {
+	values := url.Values{}
+	for key, value := range parameters {
+		values.Set(key, value)
+	}
+	return values.Encode()
+}
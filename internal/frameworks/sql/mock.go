@@ -5,8 +5,8 @@ import (
 	"reflect"
 
 	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 type ExecMapKey struct {
@@ -41,6 +41,8 @@ type MockClient[T any, U any] struct {
 	ExecOneRowAffectedMapCounter map[ExecMapKey]int
 	selectMap                    map[SelectMapKey]SelectMapValue
 	selectMapCounter             map[SelectMapKey]int
+	transaction                  []string       // Queries of the main transaction of the request
+	committed                    map[string]int // Queries committed, by the main transaction or on their own
 }
 
 /******************************************************************************
@@ -54,9 +56,10 @@ func (m *MockClient[T, U]) Exec(query string, data any) (int64, fault.Fault) {
 		} else {
 			m.execMapCounter[key] = 1
 		}
+		m.transaction = append(m.transaction, query)
 		return res.RA, res.F
 	}
-	return 0, fault.NewSQL(m.logger, "MOCK_DATA_NOT_FOUND", "Mock data not found", map[string]any{"key": key}, nil)
+	return 0, fault.NewSQL("MOCK_DATA_NOT_FOUND", "Mock data not found", map[string]any{"key": key}, nil)
 }
 
 func (m *MockClient[T, U]) MockExecMap(key ExecMapKey, value ExecMapValue) {
@@ -78,9 +81,10 @@ func (m *MockClient[T, U]) ExecOneRowAffected(query string, data any) fault.Faul
 		} else {
 			m.ExecOneRowAffectedMapCounter[key] = 1
 		}
+		m.transaction = append(m.transaction, query)
 		return res.F
 	}
-	return fault.NewSQL(m.logger, "MOCK_DATA_NOT_FOUND", "Mock data not found", map[string]any{"key": key}, nil)
+	return fault.NewSQL("MOCK_DATA_NOT_FOUND", "Mock data not found", map[string]any{"key": key}, nil)
 }
 
 func (m *MockClient[T, U]) MockExecOneRowAffectedMap(key ExecMapKey, value ExecOneRowAffectedMapValue) {
@@ -96,6 +100,38 @@ func (m *MockClient[T, U]) MockExecOneRowAffectedMap(key ExecMapKey, value ExecO
 ******************************************************************************/
 
 func (m *MockClient[T, U]) Select(query string, data, destination any) fault.Fault {
+	flt, found := m.selectMock(query, data, destination)
+	if found {
+		m.transaction = append(m.transaction, query)
+	}
+	return flt
+}
+
+// SelectAutocommit is like Select, the query being committed at once
+func (m *MockClient[T, U]) SelectAutocommit(query string, data, destination any) fault.Fault {
+	flt, found := m.selectMock(query, data, destination)
+	if found {
+		m.commit(query)
+	}
+	return flt
+}
+
+// Committed returns how many times query was committed, by the main transaction of a request ending
+// without fault or by SelectAutocommit
+func (m *MockClient[T, U]) Committed(query string) int {
+	return m.committed[query]
+}
+
+func (m *MockClient[T, U]) commit(queries ...string) {
+	if m.committed == nil {
+		m.committed = make(map[string]int)
+	}
+	for _, query := range queries {
+		m.committed[query]++
+	}
+}
+
+func (m *MockClient[T, U]) selectMock(query string, data, destination any) (fault.Fault, bool) {
 	key := SelectMapKey{Q: query, DA: data}
 	if res, exists := m.selectMap[key]; exists {
 		if counter, exists := m.selectMapCounter[key]; exists {
@@ -113,9 +149,9 @@ func (m *MockClient[T, U]) Select(query string, data, destination any) fault.Fau
 				val.Elem().Set(newVal.Elem()) // Not yet tested
 			}
 		}
-		return res.F
+		return res.F, true
 	}
-	return fault.NewSQL(m.logger, "MOCK_DATA_NOT_FOUND", "Mock data not found", map[string]any{"key": key}, nil)
+	return fault.NewSQL("MOCK_DATA_NOT_FOUND", "Mock data not found", map[string]any{"key": key}, nil), false
 }
 
 func (m *MockClient[T, U]) MockSelectMap(key SelectMapKey, flt fault.Fault, destination any) {
@@ -130,16 +166,22 @@ func (m *MockClient[T, U]) MockSelectMap(key SelectMapKey, flt fault.Fault, dest
 ***** Middleware
 ******************************************************************************/
 func (m *MockClient[T, U]) OnSetup(_ context.Context, _ *T) fault.Fault {
-	ll := log.Logger.With().Str("framework", "SQL").Logger()
-	m.logger = &ll
+	m.logger = loggerframework.Child("framework", "SQL")
 	return nil
 }
 
-func (*MockClient[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+func (m *MockClient[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+	m.logger = loggerframework.Child("framework", "SQL")
+	m.transaction = nil
 	return nil
 }
 
-func (*MockClient[T, U]) OnAfter(_ *U, err fault.Fault) fault.Fault {
+// OnAfter commits the queries of the main transaction if the request ends without fault, as GenericClient does
+func (m *MockClient[T, U]) OnAfter(_ *U, err fault.Fault) fault.Fault {
+	if err == nil {
+		m.commit(m.transaction...)
+	}
+	m.transaction = nil
 	return err
 }
 
@@ -1,9 +1,19 @@
 package sql
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lambadass-2024/backend/internal/fault"
+)
+
+// Sentinels of the codes of the SQLFaults, errors.Is telling if a fault of a chain has one.
+// The PostgreSQL error itself is found with errors.As(err, &pgErr), pgErr being a *pgconn.PgError.
+var (
+	ErrUniqueViolation     = fault.NewSentinel("UNIQUE_VIOLATION")
+	ErrForeignKeyViolation = fault.NewSentinel("FOREIGN_KEY_VIOLATION")
+	ErrRowAffectedNotOne   = fault.NewSentinel("ROW_AFFECTED_NOT_ONE")
 )
 
 var pgerrors = map[string]string{
@@ -270,8 +280,8 @@ var pgerrors = map[string]string{
 }
 
 func GetPGError(err error) string {
-	pge, ok := err.(*pgconn.PgError)
-	if ok {
+	var pge *pgconn.PgError
+	if errors.As(err, &pge) {
 		v, ok := pgerrors[pge.Code]
 		if ok {
 			return strings.ToUpper(v)
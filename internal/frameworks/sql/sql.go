@@ -5,13 +5,17 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"reflect"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib" // For the database driver
 	"github.com/jmoiron/sqlx"
 	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/metrics"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+	"github.com/lambadass-2024/backend/internal/redact"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 /* ****************************************************************************
@@ -24,10 +28,16 @@ type GenericClient[T any, U any] struct {
 	logger          *zerolog.Logger
 }
 
+// namedPreparer is the main transaction, or the database for the statements outside it
+type namedPreparer interface {
+	PrepareNamed(query string) (*sqlx.NamedStmt, error)
+}
+
 type Client[T any, U any] interface {
 	Exec(query string, data any) (int64, fault.Fault)
 	ExecOneRowAffected(query string, data any) fault.Fault
 	Select(query string, data, destination any) fault.Fault
+	SelectAutocommit(query string, data, destination any) fault.Fault
 
 	OnSetup(ctx context.Context, firstRequest *T) fault.Fault
 	OnBefore(ctx context.Context, request *T) fault.Fault
@@ -41,6 +51,14 @@ type Client[T any, U any] interface {
 
 // Execute an SQL query and return how many rows were affected. Useful for INSERT, UPDATE or DELETE queries.
 func (m *GenericClient[T, U]) Exec(query string, data any) (int64, fault.Fault) {
+	span, start := startSpan("Exec", query), time.Now()
+	rowAffected, err := m.exec(query, data)
+	span.SetAttribute("db.rows_affected", rowAffected).EndWith(err)
+	recordQuery(start)
+	return rowAffected, err
+}
+
+func (m *GenericClient[T, U]) exec(query string, data any) (int64, fault.Fault) {
 	var sqlRes sql.Result
 	var stmt *sqlx.NamedStmt
 	var err error
@@ -49,14 +67,14 @@ func (m *GenericClient[T, U]) Exec(query string, data any) (int64, fault.Fault)
 	ll := m.logger.With().Str("query", query).Logger()
 	logger := &ll
 
-	logger.Debug().Msg("Executing SQL...")
+	logger.Debug().Interface("parameters", redact.Value(data)).Msg("Executing SQL...")
 
 	dur, _ := m.duration(func() {
 		stmt, err = m.mainTransaction.PrepareNamed(query)
 	})
 	if err != nil {
 		metadata["duration"] = dur
-		return 0, fault.NewSQL(logger, "PREPARED_STATEMENT_FAILED", "Prepared statement cannot be created", metadata, err)
+		return 0, fault.NewSQL("PREPARED_STATEMENT_FAILED", "Prepared statement cannot be created", metadata, err)
 	}
 
 	dur, durStr := m.duration(func() {
@@ -64,7 +82,7 @@ func (m *GenericClient[T, U]) Exec(query string, data any) (int64, fault.Fault)
 	})
 	if err != nil {
 		metadata["duration"] = dur
-		return 0, fault.NewSQL(logger, GetPGError(err), "Error while executing SQL", metadata, err)
+		return 0, fault.NewSQL(GetPGError(err), "Error while executing SQL", metadata, err)
 	}
 
 	logger.Debug().Int64("duration", dur).Msgf("Executed SQL in %v", durStr)
@@ -74,59 +92,94 @@ func (m *GenericClient[T, U]) Exec(query string, data any) (int64, fault.Fault)
 	})
 	if err != nil {
 		metadata["duration"] = dur
-		return 0, fault.NewSQL(logger, "ROW_AFFECTED_UNKNOWN", "Cannot get the number of row affected", metadata, err)
+		return 0, fault.NewSQL("ROW_AFFECTED_UNKNOWN", "Cannot get the number of row affected", metadata, err)
 	}
 	return rowAffected, nil
 }
 
 // Like Exec, but will fail if not exactly 1 row is affected. Useful for INSERTs.
 func (m *GenericClient[T, U]) ExecOneRowAffected(query string, data any) fault.Fault {
-	ll := m.logger.With().Str("query", query).Logger()
-	logger := &ll
 	rowAffected, err := m.Exec(query, data)
 	if err != nil {
 		return err
 	}
 	if rowAffected != 1 {
-		return fault.NewSQL(logger, "ROW_AFFECTED_NOT_ONE", "The number of row affected is not 1", nil, err)
+		return fault.NewSQL("ROW_AFFECTED_NOT_ONE", "The number of row affected is not 1", nil, err)
 	}
 	return nil
 }
 
+// Select runs an SQL query and scans the returned rows into destination, a pointer to a slice
 func (m *GenericClient[T, U]) Select(query string, data, destination any) fault.Fault {
+	return m.selectSpan("Select", m.mainTransaction, query, data, destination)
+}
+
+// SelectAutocommit is like Select, but runs the query outside the main transaction, in its own committed one.
+// What it changes is kept even if the request ends with a fault, and its locks are released at once.
+func (m *GenericClient[T, U]) SelectAutocommit(query string, data, destination any) fault.Fault {
+	return m.selectSpan("SelectAutocommit", m.database, query, data, destination)
+}
+
+func (m *GenericClient[T, U]) selectSpan(operation string, preparer namedPreparer, query string, data, destination any) fault.Fault {
+	span, start := startSpan(operation, query), time.Now()
+	err := m.selectRows(preparer, query, data, destination)
+	recordQuery(start)
+	if rows := reflect.ValueOf(destination); rows.Kind() == reflect.Pointer && rows.Elem().Kind() == reflect.Slice {
+		span.SetAttribute("db.rows_returned", rows.Elem().Len())
+	}
+	span.EndWith(err)
+	return err
+}
+
+func (m *GenericClient[T, U]) selectRows(preparer namedPreparer, query string, data, destination any) fault.Fault {
 	var stmt *sqlx.NamedStmt
 	var err error
 	metadata := make(map[string]any)
 	ll := m.logger.With().Str("query", query).Logger()
 	logger := &ll
 
-	logger.Debug().Msg("Executing SQL...")
+	logger.Debug().Interface("parameters", redact.Value(data)).Msg("Executing SQL...")
 
 	dur, _ := m.duration(func() {
-		stmt, err = m.mainTransaction.PrepareNamed(query)
+		stmt, err = preparer.PrepareNamed(query)
 	})
 	if err != nil {
 		metadata["duration"] = dur
-		return fault.NewSQL(logger, "PREPARED_STATEMENT_FAILED", "Prepared statement cannot be created", metadata, err)
+		return fault.NewSQL("PREPARED_STATEMENT_FAILED", "Prepared statement cannot be created", metadata, err)
 	}
+	defer stmt.Close()
 
 	dur, durStr := m.duration(func() {
 		err = stmt.Select(destination, data)
 	})
 	if err != nil {
 		metadata["duration"] = dur
-		return fault.NewSQL(logger, GetPGError(err), "Error while executing SQL", metadata, err)
+		return fault.NewSQL(GetPGError(err), "Error while executing SQL", metadata, err)
 	}
 
 	logger.Debug().Int64("duration", dur).Msgf("Executed SQL in %v", durStr)
 
 	if err != nil {
 		metadata["duration"] = dur
-		return fault.NewSQL(logger, "ROW_AFFECTED_UNKNOWN", "Cannot get the number of row affected", metadata, err)
+		return fault.NewSQL("ROW_AFFECTED_UNKNOWN", "Cannot get the number of row affected", metadata, err)
 	}
 	return nil
 }
 
+// startSpan starts the span of a query, its statement being the named query without the data
+func startSpan(operation, query string) *trace.Span {
+	return trace.StartSpan("SQL "+operation).
+		SetAttribute("db.system", "postgresql").
+		SetAttribute("db.operation", operation).
+		SetAttribute("db.statement", query)
+}
+
+// recordQuery adds a query started at start to the metrics of the invocation
+func recordQuery(start time.Time) {
+	metrics.Add("SQLQueries", 1)
+	metrics.Duration("SQLDuration", start)
+}
+
 /******************************************************************************
 ***** Middleware
 ******************************************************************************/
@@ -139,28 +192,27 @@ func (m *GenericClient[T, U]) actualSetup(ctx context.Context) fault.Fault {
 	database := os.Getenv("SQL_DATABASE")
 	connectionMaxIdleTime, err := time.ParseDuration(os.Getenv("SQL_CONNECTION_MAX_IDLE_TIME"))
 	if err != nil {
-		return fault.NewSQL(m.logger, "NO_SQL_CONNECTION_MAX_IDLE_TIME", "Cannot parse duration from SQL_CONNECTION_MAX_IDLE_TIME", nil, err)
+		return fault.NewSQL("NO_SQL_CONNECTION_MAX_IDLE_TIME", "Cannot parse duration from SQL_CONNECTION_MAX_IDLE_TIME", nil, err)
 	}
 	connectionMaxLifeTime, err := time.ParseDuration(os.Getenv("SQL_CONNECTION_MAX_LIFE_TIME"))
 	if err != nil {
-		return fault.NewSQL(m.logger, "NO_SQL_CONNECTION_MAX_LIFE_TIME", "cannot parse duration from SQL_CONNECTION_MAX_LIFE_TIME", nil, err)
+		return fault.NewSQL("NO_SQL_CONNECTION_MAX_LIFE_TIME", "cannot parse duration from SQL_CONNECTION_MAX_LIFE_TIME", nil, err)
 	}
 
 	db, err := sqlx.ConnectContext(ctx, "pgx", fmt.Sprintf("postgres://%v:%v@%v:%v/%v", user, pwd, host, port, database))
 	if err != nil {
-		return fault.NewSQL(m.logger, "SQL_CONNECTION_ERROR", "Cannot connect to the database", nil, err)
+		return fault.NewSQL("SQL_CONNECTION_ERROR", "Cannot connect to the database", nil, err)
 	}
 	m.database = db
 	m.database.SetConnMaxIdleTime(connectionMaxIdleTime)
 	m.database.SetConnMaxLifetime(connectionMaxLifeTime)
-	m.database.SetMaxIdleConns(1)
-	m.database.SetMaxOpenConns(1)
+	m.database.SetMaxIdleConns(2)
+	m.database.SetMaxOpenConns(2) // The main transaction, and a statement of SelectAutocommit outside it
 	return nil
 }
 
 func (m *GenericClient[T, U]) OnSetup(ctx context.Context, _ *T) fault.Fault {
-	ll := log.Logger.With().Str("framework", "SQL").Logger()
-	m.logger = &ll
+	m.logger = loggerframework.Child("framework", "SQL")
 	m.logger.Trace().Msg("OnSetup")
 
 	var closureErr fault.Fault
@@ -181,10 +233,11 @@ func (m *GenericClient[T, U]) OnSetup(ctx context.Context, _ *T) fault.Fault {
 }
 
 func (m *GenericClient[T, U]) OnBefore(ctx context.Context, _ *T) fault.Fault {
+	m.logger = loggerframework.Child("framework", "SQL")
 	m.logger.Trace().Msg("OnBefore")
 	txx, err := m.database.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: false})
 	if err != nil {
-		return fault.NewSQL(m.logger, "NEW_TRANSACTION_ERROR", "Cannot create new transaction", nil, err)
+		return fault.NewSQL("NEW_TRANSACTION_ERROR", "Cannot create new transaction", nil, err)
 	}
 	m.mainTransaction = txx
 	return nil
@@ -197,16 +250,16 @@ func (m *GenericClient[T, U]) OnAfter(_ *U, err fault.Fault) fault.Fault {
 		if m.mainTransaction != nil {
 			err2 := m.mainTransaction.Rollback()
 			if err2 != nil {
-				err = fault.NewSQL(m.logger, "SQL_ROLLBACK_ERROR", "Rollbacking main transaction raised an error", nil, err2)
+				err = fault.NewSQL("SQL_ROLLBACK_ERROR", "Rollbacking main transaction raised an error", nil, err2)
 			}
 		} else {
-			err = fault.NewSQL(m.logger, "SQL_ROLLBACK_NIL_TRANSACTION", "Rollbacking main transaction is impossible because it's nil", nil, err)
+			err = fault.NewSQL("SQL_ROLLBACK_NIL_TRANSACTION", "Rollbacking main transaction is impossible because it's nil", nil, err)
 		}
 	} else {
 		m.logger.Info().Msg("Commit main transaction")
 		err2 := m.mainTransaction.Commit()
 		if err2 != nil {
-			err = fault.NewSQL(m.logger, "SQL_COMMIT_ERROR", "Commit raised an error", nil, err2)
+			err = fault.NewSQL("SQL_COMMIT_ERROR", "Commit raised an error", nil, err2)
 		}
 	}
 	return err
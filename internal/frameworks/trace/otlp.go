@@ -0,0 +1,286 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	scopeName     = "github.com/lambadass-2024/backend"
+	exportTimeout = 2 * time.Second
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Exporter sends the spans of an invocation to a tracing backend
+type Exporter interface {
+	Export(spans []*Span) error
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector with OTLP/HTTP, encoded in JSON.
+// NewOTLPExporterFromEnv configures it with the standard OTEL_* variables.
+type OTLPExporter struct {
+	Endpoint string            // URL receiving the spans, as http://localhost:4318/v1/traces
+	Headers  map[string]string // Sent with each export, for the authentication of the collector
+	Service  string            // service.name of the spans
+	Client   *http.Client      // Defaults to a client with a 2 seconds timeout
+}
+
+// OTLP/HTTP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 are strings in the JSON encoding
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 1 OK, 2 ERROR
+	Message string `json:"message,omitempty"`
+}
+
+var exporterState struct {
+	sync.Mutex
+	once     sync.Once
+	exporter Exporter
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// SetExporter replaces the exporter configured from the environment, nil disabling the export
+func SetExporter(exporter Exporter) {
+	exporterState.once.Do(func() {})
+	exporterState.Lock()
+	defer exporterState.Unlock()
+	exporterState.exporter = exporter
+}
+
+func currentExporter() Exporter {
+	exporterState.once.Do(func() {
+		if exporter := NewOTLPExporterFromEnv(); exporter != nil {
+			exporterState.exporter = exporter
+		}
+	})
+	exporterState.Lock()
+	defer exporterState.Unlock()
+	return exporterState.exporter
+}
+
+// NewOTLPExporterFromEnv returns an exporter configured by OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// (or OTEL_EXPORTER_OTLP_ENDPOINT followed by /v1/traces), OTEL_EXPORTER_OTLP_HEADERS
+// and OTEL_SERVICE_NAME (or AWS_LAMBDA_FUNCTION_NAME). It returns nil if no endpoint is set.
+func NewOTLPExporterFromEnv() *OTLPExporter {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return nil
+	}
+	headers := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if key, value, found := strings.Cut(pair, "="); found {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	}
+	return &OTLPExporter{Endpoint: endpoint, Headers: headers, Service: service}
+}
+
+func (e *OTLPExporter) Export(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(e.newRequest(spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		request.Header.Set(key, value)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: exportTimeout}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body) // So the connection is reused
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP collector answered %v", response.Status)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) newRequest(spans []*Span) otlpRequest {
+	converted := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		converted[i] = otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentID,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        attributes(span.Attributes),
+			Status:            otlpStatus{Code: 1},
+		}
+		if span.Failed {
+			converted[i].Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(map[string]any{"service.name": e.Service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: converted}},
+	}}}
+}
+
+func attributes(values map[string]any) []otlpAttribute {
+	list := make([]otlpAttribute, 0, len(values))
+	for key, value := range values {
+		list = append(list, otlpAttribute{Key: key, Value: newValue(value)})
+	}
+	return list
+}
+
+func newValue(value any) otlpValue {
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		i := strconv.Itoa(v)
+		return otlpValue{IntValue: &i}
+	case int64:
+		i := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &i}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+// DecodeOTLP returns the spans of an OTLP/HTTP JSON export, as sent by OTLPExporter. The attributes of the resource of
+// a span are prefixed by "resource.". Stand-ins of a collector use it, see tracetest.Collector.
+func DecodeOTLP(r io.Reader) ([]Span, error) {
+	var request otlpRequest
+	if err := json.NewDecoder(r).Decode(&request); err != nil {
+		return nil, err
+	}
+	var spans []Span
+	for _, resourceSpans := range request.ResourceSpans {
+		resource := map[string]any{}
+		for _, attribute := range resourceSpans.Resource.Attributes {
+			resource[attribute.Key] = attribute.Value.value()
+		}
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, s := range scopeSpans.Spans {
+				spans = append(spans, decodeSpan(s, resource))
+			}
+		}
+	}
+	return spans, nil
+}
+
+// decodeSpan converts s back to a Span, the attributes of its resource being prefixed by resource.
+func decodeSpan(s otlpSpan, resource map[string]any) Span {
+	span := Span{TraceID: s.TraceID, SpanID: s.SpanID, ParentID: s.ParentSpanID, Name: s.Name, Kind: s.Kind,
+		Attributes: map[string]any{}, Failed: s.Status.Code == 2, Error: s.Status.Message}
+	for key, value := range resource {
+		span.Attributes["resource."+key] = value
+	}
+	for _, attribute := range s.Attributes {
+		span.Attributes[attribute.Key] = attribute.Value.value()
+	}
+	if start, err := strconv.ParseInt(s.StartTimeUnixNano, 10, 64); err == nil {
+		span.Start = time.Unix(0, start)
+	}
+	if end, err := strconv.ParseInt(s.EndTimeUnixNano, 10, 64); err == nil {
+		span.End = time.Unix(0, end)
+	}
+	return span
+}
+
+// value returns the Go value of a decoded attribute
+func (v otlpValue) value() any {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		i, _ := strconv.ParseInt(*v.IntValue, 10, 64)
+		return i
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// maxSpans bounds the spans kept for an invocation, so a loop of queries cannot exhaust the memory of the Lambda
+const maxSpans = 1000
+
+type SpanKind int
+
+// Kinds of the OTLP specification
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Span is a timed operation of an invocation : the invocation itself, a middleware phase, a query...
+// Its methods do nothing on a nil Span, which StartSpan returns when no request is traced.
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	Error      string // Status message of a failed span, "" if it succeeded
+	Failed     bool
+}
+
+// recorder keeps the spans of the current invocation until Finish exports them
+var recorder struct {
+	sync.Mutex
+	root     *Span
+	finished []*Span
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// StartSpan starts a span, child of the active one, and makes it the active one until it ends.
+// The logs written meanwhile carry its spanId and outbound calls use it as their parent.
+//
+// Example :
+//
+//	span := trace.StartSpan("PetUseCase.Get")
+//	defer func() { span.EndWith(err) }()
+func StartSpan(name string) *Span {
+	c := Current()
+	if c.IsZero() {
+		return nil
+	}
+	span := &Span{TraceID: c.TraceID, SpanID: randomHex(8), ParentID: c.SpanID, Name: name, Kind: KindInternal, Start: time.Now()}
+	c.SpanID = span.SpanID
+	Set(c)
+	return span
+}
+
+// Root returns the span of the invocation, nil if no request is traced
+func Root() *Span {
+	recorder.Lock()
+	defer recorder.Unlock()
+	return recorder.root
+}
+
+// startRoot records the span of the invocation c
+func startRoot(c Context) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.root = &Span{TraceID: c.TraceID, SpanID: c.SpanID, ParentID: c.ParentID, Name: "invocation", Kind: KindServer, Start: time.Now()}
+	recorder.finished = nil
+}
+
+// SetAttribute adds an attribute to s, values being strings, booleans, integers or floats
+func (s *Span) SetAttribute(key string, value any) *Span {
+	if s == nil {
+		return s
+	}
+	if s.Attributes == nil {
+		s.Attributes = map[string]any{}
+	}
+	s.Attributes[key] = value
+	return s
+}
+
+// SetName renames s, once its name is known
+func (s *Span) SetName(name string) *Span {
+	if s != nil {
+		s.Name = name
+	}
+	return s
+}
+
+// Fail marks s as failed because of err. Faults add their code to the attributes.
+func (s *Span) Fail(err error) *Span {
+	if s == nil || err == nil {
+		return s
+	}
+	s.Failed, s.Error = true, err.Error()
+	if coded, ok := err.(interface{ Code() string }); ok {
+		s.SetAttribute("fault.code", coded.Code())
+	}
+	return s
+}
+
+// EndWith ends s, marking it as failed if err is not nil
+func (s *Span) EndWith(err error) {
+	s.Fail(err).EndSpan()
+}
+
+// EndSpan ends s, its parent becoming the active span again. Ending a span twice does nothing.
+func (s *Span) EndSpan() {
+	if s == nil || !s.End.IsZero() {
+		return
+	}
+	s.End = time.Now()
+	if c := Current(); c.SpanID == s.SpanID && c.TraceID == s.TraceID && s.Kind != KindServer {
+		c.SpanID = s.ParentID
+		Set(c)
+	}
+
+	recorder.Lock()
+	defer recorder.Unlock()
+	if len(recorder.finished) < maxSpans {
+		recorder.finished = append(recorder.finished, s)
+	}
+}
+
+// Finish ends the span of the invocation with err and exports the spans of the invocation, if it is sampled.
+// Lambdas are frozen once they answer, so the export is synchronous. The current context is cleared, so that nothing
+// done between two invocations carries the trace of the previous one.
+func Finish(err error) error {
+	defer Set(Context{})
+	root := Root()
+	if root == nil {
+		return nil
+	}
+	root.EndWith(err)
+
+	recorder.Lock()
+	spans := recorder.finished
+	recorder.root, recorder.finished = nil, nil
+	recorder.Unlock()
+
+	exporter := currentExporter()
+	if exporter == nil || !Current().Sampled() {
+		return nil
+	}
+	return exporter.Export(spans)
+}
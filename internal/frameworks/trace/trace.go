@@ -0,0 +1,234 @@
+// Package trace correlates the logs and the responses of a request with its callers and the services it calls.
+//
+// Each request gets a W3C trace context (https://www.w3.org/TR/trace-context/), continued from its traceparent header
+// or started, and a correlation ID, read from its X-Request-Id header or generated.
+// A Lambda handles one request at a time, so the context of the current request is kept in the package,
+// where the logger, the responses and the outbound calls find it without threading a context.Context.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+	HeaderRequestID   = "X-Request-Id"
+
+	traceparentLength  = 55 // 00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>
+	maxRequestIDLength = 128
+	flagSampled        = "01"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Context is the trace context of a request
+type Context struct {
+	TraceID   string // 32 hex characters, shared by every service handling the request
+	SpanID    string // 16 hex characters, the active span of this function, see StartSpan
+	ParentID  string // SpanID of the caller, "" if the trace starts here
+	Flags     string // Trace flags of the caller, 01 when sampled
+	State     string // tracestate of the caller, propagated as is
+	RequestID string // X-Request-Id of the caller, generated if it has none
+}
+
+// Hook adds the trace context of the current request to every log line
+type Hook struct{}
+
+// Transport is a http.RoundTripper propagating the trace context of the current request to the services it calls.
+//
+// Example :
+//
+//	client := &http.Client{Transport: trace.Transport{}}
+type Transport struct {
+	Base http.RoundTripper // Defaults to http.DefaultTransport
+}
+
+var current atomic.Pointer[Context]
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+// Start continues the trace of the caller from the traceparent, tracestate and X-Request-Id headers of a request,
+// or starts a new one, and makes it the current context. Its SpanID is the one of the span of the invocation, see Root.
+func Start(headers map[string]string) Context {
+	c, ok := Parse(header(headers, HeaderTraceparent))
+	if ok {
+		c.State = header(headers, HeaderTracestate)
+	} else {
+		c = Context{TraceID: randomHex(16), Flags: flagSampled}
+	}
+	c.SpanID = randomHex(8)
+	c.RequestID = header(headers, HeaderRequestID)
+	if !validRequestID(c.RequestID) {
+		c.RequestID = uuid.NewString()
+	}
+	Set(c)
+	startRoot(c)
+	return c
+}
+
+// Parse reads a traceparent header. Its span is the parent of the returned context, which has no SpanID yet.
+// Versions after 00 are read as 00, as the specification requires.
+func Parse(traceparent string) (Context, bool) {
+	traceparent = strings.TrimSpace(traceparent)
+	if len(traceparent) < traceparentLength || (len(traceparent) > traceparentLength && traceparent[traceparentLength] != '-') {
+		return Context{}, false
+	}
+	parts := strings.Split(traceparent[:traceparentLength], "-")
+	if len(parts) != 4 || !isHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(traceparent) != traceparentLength) ||
+		!isHex(parts[1], 32) || isZero(parts[1]) || !isHex(parts[2], 16) || isZero(parts[2]) || !isHex(parts[3], 2) {
+		return Context{}, false
+	}
+	return Context{TraceID: parts[1], ParentID: parts[2], Flags: parts[3]}, true
+}
+
+// Current returns the context of the request being handled, a zero Context if none started
+func Current() Context {
+	if c := current.Load(); c != nil {
+		return *c
+	}
+	return Context{}
+}
+
+// Set makes c the current context, a zero Context clears it
+func Set(c Context) {
+	current.Store(&c)
+}
+
+// SetRequestID makes id the correlation ID of the current request, for the events carrying it elsewhere than in
+// an X-Request-Id header. It returns false, keeping the current one, if no request started or id is not valid.
+func SetRequestID(id string) bool {
+	c := Current()
+	if c.IsZero() || !validRequestID(id) {
+		return false
+	}
+	c.RequestID = id
+	Set(c)
+	return true
+}
+
+// Sampled tells if the caller records the trace, its spans are only exported then
+func (c Context) Sampled() bool {
+	flags, err := strconv.ParseUint(c.Flags, 16, 8)
+	return err == nil && flags&1 == 1
+}
+
+// IsZero tells if c is the context of no request
+func (c Context) IsZero() bool {
+	return c.TraceID == ""
+}
+
+// Traceparent returns the traceparent header sent by this function, to its callers and to the services it calls
+func (c Context) Traceparent() string {
+	return "00-" + c.TraceID + "-" + c.SpanID + "-" + c.Flags
+}
+
+// Headers returns the headers propagating c, for outbound calls not made with Transport (queues, SDKs...)
+func (c Context) Headers() map[string]string {
+	if c.IsZero() {
+		return map[string]string{}
+	}
+	h := map[string]string{HeaderTraceparent: c.Traceparent(), HeaderRequestID: c.RequestID}
+	if c.State != "" {
+		h[HeaderTracestate] = c.State
+	}
+	return h
+}
+
+// Inject sets the headers of the current context on an outbound request, keeping the ones already set
+func Inject(h http.Header) {
+	for key, value := range Current().Headers() {
+		if h.Get(key) == "" {
+			h.Set(key, value)
+		}
+	}
+}
+
+func (Hook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	c := Current()
+	if c.IsZero() {
+		return
+	}
+	e.Str("traceId", c.TraceID).Str("spanId", c.SpanID).Str("correlationId", c.RequestID)
+}
+
+// RoundTrip sends request in a client span, which is the parent of the spans of the called service
+func (t Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	span := StartSpan(request.Method + " " + request.URL.Host)
+	if span != nil {
+		span.Kind = KindClient
+		span.SetAttribute("http.request.method", request.Method).SetAttribute("url.full", request.URL.Redacted())
+	}
+	request = request.Clone(request.Context()) // A RoundTripper must not modify the request
+	Inject(request.Header)
+	response, err := base.RoundTrip(request)
+	if err == nil {
+		span.SetAttribute("http.response.status_code", response.StatusCode)
+	}
+	span.EndWith(err)
+	return response, err
+}
+
+// header looks for name in headers without case sensitivity, as API Gateway forwards them as the client wrote them
+func header(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// validRequestID accepts the printable ASCII IDs of a reasonable length, so a client cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // Never fails, see crypto/rand
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := range len(s) {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,170 @@
+package trace_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace/tracetest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+func Test_Trace_Parse(t *testing.T) {
+	c, ok := trace.Parse(traceparent)
+	require.True(t, ok)
+	assert.Equal(t, trace.Context{TraceID: "0af7651916cd43dd8448eb211c80319c", ParentID: "b7ad6b7169203331", Flags: "01"}, c)
+
+	_, ok = trace.Parse("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00-future")
+	assert.True(t, ok)
+
+	for _, invalid := range []string{
+		"",
+		traceparent + "-extra",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+		"00_0af7651916cd43dd8448eb211c80319c_b7ad6b7169203331_01",
+	} {
+		_, ok := trace.Parse(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func Test_Trace_Start(t *testing.T) {
+	defer trace.Set(trace.Context{})
+
+	c := trace.Start(map[string]string{"Traceparent": traceparent, "tracestate": "rojo=00f067aa0ba902b7", "x-request-id": "abc-123"})
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", c.TraceID)
+	assert.Equal(t, "b7ad6b7169203331", c.ParentID)
+	assert.Len(t, c.SpanID, 16)
+	assert.NotEqual(t, c.ParentID, c.SpanID)
+	assert.Equal(t, "abc-123", c.RequestID)
+	assert.Equal(t, c, trace.Current())
+	assert.Equal(t, map[string]string{
+		"traceparent":  "00-0af7651916cd43dd8448eb211c80319c-" + c.SpanID + "-01",
+		"tracestate":   "rojo=00f067aa0ba902b7",
+		"X-Request-Id": "abc-123",
+	}, c.Headers())
+
+	c = trace.Start(map[string]string{"X-Request-Id": "forged\n{\"level\":\"error\"}"})
+	assert.Len(t, c.TraceID, 32)
+	assert.Empty(t, c.ParentID)
+	assert.Equal(t, "01", c.Flags)
+	assert.Len(t, c.RequestID, 36)
+	_, ok := trace.Parse(c.Traceparent())
+	assert.True(t, ok)
+}
+
+func Test_Trace_SetRequestID(t *testing.T) {
+	defer trace.Set(trace.Context{})
+	assert.False(t, trace.SetRequestID("message-1"), "No request started")
+
+	c := trace.Start(nil)
+	assert.True(t, trace.SetRequestID("message-1"))
+	assert.False(t, trace.SetRequestID("forged\n"))
+	assert.Equal(t, "message-1", trace.Current().RequestID)
+	assert.Equal(t, c.TraceID, trace.Current().TraceID)
+}
+
+func Test_Trace_Transport(t *testing.T) {
+	defer trace.Set(trace.Context{})
+	c := trace.Start(map[string]string{"traceparent": traceparent, "X-Request-Id": "abc-123"})
+
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: trace.Transport{}}
+	request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	response, err := client.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+
+	// The called service is the child of the client span of the call, which ended with it
+	sent, ok := trace.Parse(received.Get("traceparent"))
+	require.True(t, ok)
+	assert.Equal(t, c.TraceID, sent.TraceID)
+	assert.NotEqual(t, c.SpanID, sent.ParentID)
+	assert.Equal(t, c.SpanID, trace.Current().SpanID)
+	assert.Equal(t, "abc-123", received.Get("X-Request-Id"))
+	assert.Empty(t, request.Header.Get("traceparent"))
+}
+
+func Test_Trace_Hook(t *testing.T) {
+	defer trace.Set(trace.Context{})
+	var buffer bytes.Buffer
+	logger := zerolog.New(&buffer).Hook(trace.Hook{})
+
+	logger.Info().Msg("before")
+	assert.NotContains(t, buffer.String(), "traceId")
+
+	c := trace.Start(map[string]string{"X-Request-Id": "abc-123"})
+	logger.Info().Msg("during")
+	assert.Contains(t, buffer.String(), `"traceId":"`+c.TraceID+`","spanId":"`+c.SpanID+`","correlationId":"abc-123"`)
+}
+
+func Test_Trace_Spans(t *testing.T) {
+	defer trace.Set(trace.Context{})
+	assert.Nil(t, trace.StartSpan("untraced"))
+
+	c := trace.Start(map[string]string{"traceparent": traceparent})
+	span := trace.StartSpan("use case")
+	assert.Equal(t, span.SpanID, trace.Current().SpanID)
+	child := trace.StartSpan("query").SetAttribute("db.statement", "SELECT 1")
+	assert.Equal(t, span.SpanID, child.ParentID)
+	child.EndWith(errors.New("broken"))
+	assert.Equal(t, span.SpanID, trace.Current().SpanID)
+	span.EndSpan()
+	span.EndSpan()
+	assert.Equal(t, c.SpanID, trace.Current().SpanID)
+
+	assert.True(t, child.Failed)
+	assert.Equal(t, "broken", child.Error)
+	assert.Equal(t, c.SpanID, trace.Root().SpanID)
+}
+
+func Test_Trace_Export(t *testing.T) {
+	collector := tracetest.NewCollector()
+	defer collector.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", strings.TrimSuffix(collector.URL, "/v1/traces"))
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer token")
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "pet-GET")
+	exporter := trace.NewOTLPExporterFromEnv()
+	assert.Equal(t, &trace.OTLPExporter{Endpoint: collector.URL, Headers: map[string]string{"Authorization": "Bearer token"}, Service: "pet-GET"}, exporter)
+	trace.SetExporter(exporter)
+	defer trace.SetExporter(nil)
+
+	trace.Start(map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"})
+	trace.StartSpan("not sampled").EndSpan()
+	require.NoError(t, trace.Finish(nil))
+	assert.Empty(t, collector.Spans())
+
+	c := trace.Start(map[string]string{"traceparent": traceparent})
+	trace.StartSpan("query").SetAttribute("db.rows_returned", 2).SetAttribute("cached", true).EndSpan()
+	require.NoError(t, trace.Finish(errors.New("failed")))
+
+	spans := collector.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "query", spans[0].Name)
+	assert.Equal(t, c.SpanID, spans[0].ParentID)
+	assert.Equal(t, trace.KindInternal, spans[0].Kind)
+	assert.Equal(t, map[string]any{"resource.service.name": "pet-GET", "db.rows_returned": int64(2), "cached": true}, spans[0].Attributes)
+	assert.Equal(t, "invocation", spans[1].Name)
+	assert.Equal(t, trace.KindServer, spans[1].Kind)
+	assert.True(t, spans[1].Failed)
+	assert.False(t, spans[1].End.Before(spans[0].End))
+	assert.Nil(t, trace.Root())
+	assert.True(t, trace.Current().IsZero(), "The context of the invocation is cleared")
+}
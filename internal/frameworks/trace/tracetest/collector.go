@@ -0,0 +1,79 @@
+// Package tracetest receives the spans exported by the trace package, to assert on them.
+package tracetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Collector is an in-process stand-in of an OpenTelemetry collector, receiving OTLP/HTTP JSON exports.
+//
+// Example :
+//
+//	collector := tracetest.NewCollector()
+//	defer collector.Close()
+//	trace.SetExporter(&trace.OTLPExporter{Endpoint: collector.URL})
+//	...
+//	spans := collector.Spans()
+type Collector struct {
+	URL    string // Endpoint of the exporter
+	server *httptest.Server
+	mutex  sync.Mutex
+	spans  []trace.Span
+}
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func NewCollector() *Collector {
+	c := &Collector{}
+	c.server = httptest.NewServer(http.HandlerFunc(c.receive))
+	c.URL = c.server.URL + "/v1/traces"
+	return c
+}
+
+// Spans returns the spans received so far, in the order they ended
+func (c *Collector) Spans() []trace.Span {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]trace.Span(nil), c.spans...)
+}
+
+// Span returns the first received span named name
+func (c *Collector) Span(name string) (trace.Span, bool) {
+	for _, span := range c.Spans() {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return trace.Span{}, false
+}
+
+func (c *Collector) Close() {
+	c.server.Close()
+}
+
+func (c *Collector) receive(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	spans, err := trace.DecodeOTLP(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.spans = append(c.spans, spans...)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("{}"))
+}
@@ -0,0 +1,32 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+/******************************************************************************
+***** Test
+******************************************************************************/
+
+// AssertNoLeak fails t if output, log lines or a response body, contains one of secrets
+// or a part matching the patterns of the default Redactor. It returns true if nothing leaked.
+//
+// This function should only be used in tests
+func AssertNoLeak(t testing.TB, output string, secrets ...string) bool {
+	t.Helper()
+	ok := true
+	for _, secret := range secrets {
+		if secret != "" && strings.Contains(output, secret) {
+			t.Errorf("Sensitive value %q leaked in :\n%v", secret, output)
+			ok = false
+		}
+	}
+	for _, pattern := range Default().Patterns {
+		if leak := pattern.FindString(output); leak != "" {
+			t.Errorf("Sensitive value %q, matching %v, leaked in :\n%v", leak, pattern, output)
+			ok = false
+		}
+	}
+	return ok
+}
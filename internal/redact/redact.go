@@ -0,0 +1,441 @@
+// Package redact masks the sensitive parts of what is logged : credentials in headers, secrets in bodies and
+// personal data in fault metadata or SQL parameters.
+//
+// A Redactor masks :
+//   - the values of the headers it lists, whatever their case
+//   - the values at the JSON paths it lists, in bodies, maps and structs
+//   - the fields of structs tagged `log:"redact"`
+//   - the parts of strings matching its patterns, as emails and tokens
+//
+// The package functions use the default Redactor, which the logger middleware, the fault constructors and the SQL
+// framework share. Configure it once, at the start of the function :
+//
+//	r := redact.NewDefault()
+//	r.Paths = append(r.Paths, "$.owner.phone")
+//	redact.SetDefault(r)
+//
+// It only depends on the standard library, as the fault package which uses it, so it is not a framework.
+package redact
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Mask replaces what is redacted
+const Mask = "[REDACTED]"
+
+// Headers, paths and patterns of NewDefault
+var (
+	DefaultHeaders = []string{
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Amz-Security-Token", "X-Debug-Token",
+	}
+	DefaultPaths = []string{
+		"password", "secret", "token", "accessToken", "refreshToken", "apiKey", "authorization", "cardNumber", "cvv",
+	}
+	DefaultPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),      // Email
+		regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),                // Bearer token
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), // JWT
+		regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                       // AWS access key ID
+		regexp.MustCompile(`(?i)\b(?:password|secret|token)=[^\s&;,]+`),           // Credentials in a query or DSN
+	}
+)
+
+/******************************************************************************
+***** Structs
+******************************************************************************/
+
+// Redactor describes what is sensitive
+type Redactor struct {
+	// HeaderNames are the headers masked, whatever their case
+	HeaderNames []string
+	// Paths are dot separated keys, * matching any key. They match at any depth, unless they start with $.
+	// Keys are compared without case, arrays are crossed without adding a key.
+	//
+	// Example : "password" masks every password, "$.owner.*" every field of the owner at the root
+	Paths []string
+	// Patterns mask the parts of strings they match
+	Patterns []*regexp.Regexp
+}
+
+// redactedError is an error whose message is redacted, the original one being kept for errors.Is and errors.As
+type redactedError struct {
+	message string
+	err     error
+}
+
+var current atomic.Pointer[Redactor]
+
+/******************************************************************************
+***** Functions
+******************************************************************************/
+
+func init() {
+	current.Store(NewDefault())
+}
+
+// NewDefault returns a Redactor with DefaultHeaders, DefaultPaths and DefaultPatterns, ready to be extended
+func NewDefault() *Redactor {
+	return &Redactor{
+		HeaderNames: append([]string(nil), DefaultHeaders...),
+		Paths:       append([]string(nil), DefaultPaths...),
+		Patterns:    append([]*regexp.Regexp(nil), DefaultPatterns...),
+	}
+}
+
+// Default returns the Redactor of the package functions
+func Default() *Redactor {
+	return current.Load()
+}
+
+// SetDefault replaces the Redactor of the package functions
+func SetDefault(r *Redactor) {
+	current.Store(r)
+}
+
+// Value returns v redacted by the default Redactor, see Redactor.Value
+func Value(v any) any {
+	return Default().Value(v)
+}
+
+// Metadata returns metadata redacted by the default Redactor, see Redactor.Metadata
+func Metadata(metadata map[string]any) map[string]any {
+	return Default().Metadata(metadata)
+}
+
+// Field returns the value of the field name redacted by the default Redactor, see Redactor.Field
+func Field(name string, value any) any {
+	return Default().Field(name, value)
+}
+
+// String returns s redacted by the default Redactor, see Redactor.String
+func String(s string) string {
+	return Default().String(s)
+}
+
+// Err returns err redacted by the default Redactor, see Redactor.Err
+func Err(err error) error {
+	return Default().Err(err)
+}
+
+// Headers returns headers redacted by the default Redactor, see Redactor.Headers
+func Headers(headers map[string]string) map[string]string {
+	return Default().Headers(headers)
+}
+
+// MultiValueHeaders returns headers redacted by the default Redactor, see Redactor.MultiValueHeaders
+func MultiValueHeaders(headers map[string][]string) map[string][]string {
+	return Default().MultiValueHeaders(headers)
+}
+
+// JSON returns body redacted by the default Redactor, see Redactor.JSON
+func JSON(body string) string {
+	return Default().JSON(body)
+}
+
+// String masks the parts of s matching the patterns of r
+func (r *Redactor) String(s string) string {
+	for _, pattern := range r.Patterns {
+		s = pattern.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// Err returns an error whose message is redacted, nil if err is nil. It still wraps err.
+func (r *Redactor) Err(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := r.String(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{message: message, err: err}
+}
+
+// Headers returns a copy of headers, the ones listed by r being masked and the others redacted by its patterns
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if r.sensitiveHeader(key) {
+			redacted[key] = Mask
+		} else {
+			redacted[key] = r.String(value)
+		}
+	}
+	return redacted
+}
+
+// MultiValueHeaders is Headers for the headers with several values
+func (r *Redactor) MultiValueHeaders(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		redacted[key] = make([]string, len(values))
+		for i, value := range values {
+			if r.sensitiveHeader(key) {
+				redacted[key][i] = Mask
+			} else {
+				redacted[key][i] = r.String(value)
+			}
+		}
+	}
+	return redacted
+}
+
+// JSON redacts a JSON body. Bodies which are not JSON are redacted as strings.
+func (r *Redactor) JSON(body string) string {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var v any
+	if decoder.Decode(&v) != nil || decoder.More() {
+		return r.String(body)
+	}
+	redacted, changed := r.walk(reflect.ValueOf(v), nil)
+	if !changed {
+		return body
+	}
+	encoded, err := json.Marshal(redacted)
+	if err != nil {
+		return Mask
+	}
+	return string(encoded)
+}
+
+// Metadata redacts the metadata of a fault, see Value
+func (r *Redactor) Metadata(metadata map[string]any) map[string]any {
+	if metadata == nil {
+		return nil
+	}
+	redacted, _ := r.Value(metadata).(map[string]any)
+	return redacted
+}
+
+// Field redacts the value of a field named name, masking it if a path of r matches name alone
+func (r *Redactor) Field(name string, value any) any {
+	if r.sensitivePath([]string{name}) {
+		return Mask
+	}
+	return r.Value(value)
+}
+
+// Value returns v with its sensitive parts masked : values at the paths of r, struct fields tagged `log:"redact"`
+// and parts of strings matching its patterns. What has nothing to redact is returned as is, so it keeps its type.
+// Structs with redacted fields become maps, keyed like their JSON encoding.
+func (r *Redactor) Value(v any) any {
+	if v == nil {
+		return nil
+	}
+	redacted, changed := r.walk(reflect.ValueOf(v), nil)
+	if !changed {
+		return v
+	}
+	return redacted
+}
+
+// walk redacts v found at path, telling if something was redacted
+func (r *Redactor) walk(v reflect.Value, path []string) (any, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	if err, ok := asError(v); ok {
+		if message := r.String(err.Error()); message != err.Error() {
+			return &redactedError{message: message, err: err}, true
+		}
+		return v.Interface(), false
+	}
+	if text, ok := asText(v); ok {
+		if redacted := r.String(text); redacted != text {
+			return redacted, true
+		}
+		return v.Interface(), false
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if redacted := r.String(v.String()); redacted != v.String() {
+			return redacted, true
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			if redacted, changed := r.walk(v.Elem(), path); changed {
+				return redacted, true
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			return r.walkMap(v, path)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 { // Bytes are encoded in base64, not walked
+			return r.walkSlice(v, path)
+		}
+	case reflect.Struct:
+		return r.walkStruct(v, path)
+	}
+	return v.Interface(), false
+}
+
+func (r *Redactor) walkMap(v reflect.Value, path []string) (any, bool) {
+	redacted := make(map[string]any, v.Len())
+	changed := false
+	iter := v.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		value, c := r.walkKey(iter.Value(), append(path, key))
+		redacted[key] = value
+		changed = changed || c
+	}
+	if !changed {
+		return v.Interface(), false
+	}
+	return redacted, true
+}
+
+func (r *Redactor) walkSlice(v reflect.Value, path []string) (any, bool) {
+	redacted := make([]any, v.Len())
+	changed := false
+	for i := range v.Len() {
+		value, c := r.walk(v.Index(i), path)
+		redacted[i] = value
+		changed = changed || c
+	}
+	if !changed {
+		return v.Interface(), false
+	}
+	return redacted, true
+}
+
+func (r *Redactor) walkStruct(v reflect.Value, path []string) (any, bool) {
+	redacted := map[string]any{}
+	if !r.walkFields(v, path, redacted) {
+		return v.Interface(), false
+	}
+	return redacted, true
+}
+
+// walkFields adds the fields of the struct v to redacted, the ones of its embedded structs included,
+// telling if one was redacted
+func (r *Redactor) walkFields(v reflect.Value, path []string, redacted map[string]any) bool {
+	changed := false
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, omitEmpty, skip := jsonName(field)
+		if skip || (omitEmpty && v.Field(i).IsZero()) {
+			continue
+		}
+		if field.Tag.Get("log") == "redact" && !v.Field(i).IsZero() { // Empty values reveal nothing
+			redacted[name] = Mask
+			changed = true
+			continue
+		}
+		if embedded := reflect.Indirect(v.Field(i)); field.Anonymous && field.Tag.Get("json") == "" && embedded.Kind() == reflect.Struct {
+			changed = r.walkFields(embedded, path, redacted) || changed
+			continue
+		}
+		value, c := r.walkKey(v.Field(i), append(path, name))
+		redacted[name] = value
+		changed = changed || c
+	}
+	return changed
+}
+
+// walkKey redacts the value v of the last key of path, masking it if path is sensitive
+func (r *Redactor) walkKey(v reflect.Value, path []string) (any, bool) {
+	if r.sensitivePath(path) {
+		return Mask, true
+	}
+	return r.walk(v, path)
+}
+
+// sensitivePath tells if a path of r matches path
+func (r *Redactor) sensitivePath(path []string) bool {
+	for _, p := range r.Paths {
+		segments := strings.Split(p, ".")
+		anchored := segments[0] == "$"
+		if anchored {
+			segments = segments[1:]
+		}
+		if len(segments) > len(path) || (anchored && len(segments) != len(path)) {
+			continue
+		}
+		if matchSegments(segments, path[len(path)-len(segments):]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(segments, keys []string) bool {
+	for i, segment := range segments {
+		if segment != "*" && !strings.EqualFold(segment, keys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Redactor) sensitiveHeader(name string) bool {
+	for _, header := range r.HeaderNames {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonName returns the key of field in its JSON encoding
+func jsonName(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(options, "omitempty"), false
+}
+
+// asError returns the error v holds, if any
+func asError(v reflect.Value) (error, bool) {
+	if !v.CanInterface() || (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, false
+	}
+	err, ok := v.Interface().(error)
+	return err, ok
+}
+
+// asText returns the text of the values encoded as text in JSON, like UUIDs and times
+func asText(v reflect.Value) (string, bool) {
+	if !v.CanInterface() || (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "", false
+	}
+	marshaler, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		return "", false
+	}
+	text, err := marshaler.MarshalText()
+	return string(text), err == nil
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
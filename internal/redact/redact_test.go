@@ -0,0 +1,130 @@
+package redact_test
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lambadass-2024/backend/internal/fault"
+	"github.com/lambadass-2024/backend/internal/redact"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Owner struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone" log:"redact"`
+}
+
+type Account struct {
+	Owner
+	ID       uuid.UUID `json:"id"`
+	Password string    `json:"password,omitempty"`
+	Note     string
+	internal string
+}
+
+func Test_Redact_Headers(t *testing.T) {
+	headers := map[string]string{"authorization": "Basic dXNlcjpwd2Q=", "Accept": "application/json", "X-Note": "Bearer abc.def"}
+	assert.Equal(t, map[string]string{
+		"authorization": redact.Mask,
+		"Accept":        "application/json",
+		"X-Note":        redact.Mask,
+	}, redact.Headers(headers))
+	assert.Equal(t, map[string][]string{"Cookie": {redact.Mask, redact.Mask}}, redact.MultiValueHeaders(map[string][]string{"Cookie": {"a=1", "b=2"}}))
+	assert.Nil(t, redact.Headers(nil))
+}
+
+func Test_Redact_JSON_Paths(t *testing.T) {
+	r := &redact.Redactor{Paths: []string{"password", "$.owner.*", "card.number"}}
+	body := `{"name":"rex","password":"hunter2","owner":{"name":"bob","age":3},"users":[{"Password":"x","card":{"number":"4242","exp":"12/30"}}],"nested":{"owner":{"name":"kept"}}}`
+	assert.JSONEq(t, `{
+		"name":"rex",
+		"password":"[REDACTED]",
+		"owner":{"name":"[REDACTED]","age":"[REDACTED]"},
+		"users":[{"Password":"[REDACTED]","card":{"number":"[REDACTED]","exp":"12/30"}}],
+		"nested":{"owner":{"name":"kept"}}
+	}`, r.JSON(body))
+}
+
+func Test_Redact_JSON_Unchanged(t *testing.T) {
+	body := `{"b":1.50, "a":"kept"}`
+	assert.Equal(t, body, redact.JSON(body), "Bodies without sensitive values are not re-encoded")
+	assert.Equal(t, "mail [REDACTED] please", redact.JSON("mail bob@example.com please"))
+}
+
+func Test_Redact_Value_Struct(t *testing.T) {
+	id := uuid.New()
+	account := Account{Owner: Owner{Name: "bob", Phone: "0600000000"}, ID: id, Password: "hunter2", Note: "bob@example.com"}
+	assert.Equal(t, map[string]any{
+		"name":     "bob",
+		"phone":    redact.Mask,
+		"id":       id,
+		"password": redact.Mask,
+		"Note":     redact.Mask,
+	}, redact.Value(account))
+	assert.Equal(t, map[string]any{
+		"name":  "bob",
+		"phone": redact.Mask,
+		"id":    id,
+		"Note":  "",
+	}, redact.Value(&Account{Owner: Owner{Name: "bob", Phone: "1"}, ID: id}))
+}
+
+func Test_Redact_Value_Unchanged(t *testing.T) {
+	id := uuid.New()
+	metadata := map[string]any{"id": id, "names": []string{"rex"}, "owner": Owner{Name: "bob"}}
+	redacted := redact.Metadata(metadata)
+	assert.Equal(t, metadata, redacted)
+	assert.IsType(t, uuid.UUID{}, redacted["id"], "Values keep their type when nothing is redacted")
+	assert.IsType(t, Owner{}, redacted["owner"])
+}
+
+func Test_Redact_Err(t *testing.T) {
+	cause := errors.New("duplicate key (email)=(bob@example.com)")
+	err := redact.Err(cause)
+	assert.Equal(t, "duplicate key (email)=([REDACTED])", err.Error())
+	require.ErrorIs(t, err, cause)
+	plain := errors.New("timeout")
+	assert.Equal(t, plain, redact.Err(plain))
+	assert.NoError(t, redact.Err(nil))
+}
+
+func Test_Redact_Field(t *testing.T) {
+	assert.Equal(t, redact.Mask, redact.Field("Password", "hunter2"))
+	assert.Equal(t, "rex", redact.Field("Name", "rex"))
+}
+
+func Test_Redact_SetDefault(t *testing.T) {
+	r := redact.NewDefault()
+	r.Patterns = append(r.Patterns, regexp.MustCompile(`\+33\d{9}`))
+	redact.SetDefault(r)
+	defer redact.SetDefault(redact.NewDefault())
+
+	assert.Equal(t, "call [REDACTED]", redact.String("call +33600000000"))
+}
+
+func Test_Redact_Faults(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer zerolog.SetGlobalLevel(zerolog.Disabled)
+	buffer := &bytes.Buffer{}
+	logger := zerolog.New(buffer)
+
+	flt := fault.NewSQL("UNIQUE_VIOLATION", "Error while executing SQL",
+		map[string]any{"token": "t0k3n", "query": "password=hunter2"}, errors.New("Key (email)=(bob@example.com) already exists"))
+	fault.Log(&logger, fault.NewAPIGatewayFromFault(409, flt), 409)
+
+	assert.Equal(t, map[string]any{"token": redact.Mask, "query": redact.Mask}, flt.Metadata())
+	redact.AssertNoLeak(t, buffer.String(), "t0k3n", "hunter2")
+	assert.Contains(t, buffer.String(), "Key (email)=([REDACTED]) already exists")
+}
+
+func Test_Redact_AssertNoLeak(t *testing.T) {
+	mock := &testing.T{}
+	assert.False(t, redact.AssertNoLeak(mock, "token eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig"))
+	assert.False(t, redact.AssertNoLeak(mock, "the secret is rex", "rex"))
+	assert.True(t, redact.AssertNoLeak(t, "nothing "+redact.Mask, "rex"))
+}
@@ -2,14 +2,17 @@ package usecases
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/lambadass-2024/backend/internal/adapters/repositories"
 	"github.com/lambadass-2024/backend/internal/entities"
 	"github.com/lambadass-2024/backend/internal/fault"
+	loggerframework "github.com/lambadass-2024/backend/internal/frameworks/logger"
+	"github.com/lambadass-2024/backend/internal/frameworks/metrics"
+	"github.com/lambadass-2024/backend/internal/frameworks/trace"
 	"github.com/lambadass-2024/backend/internal/utils"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 /******************************************************************************
@@ -27,7 +30,7 @@ type PetUseCase[T any, U any] struct {
 ******************************************************************************/
 
 func (u PetUseCase[T, U]) newError(code, message string, metadata map[string]any, cause error) fault.Fault {
-	return fault.NewUseCase(u.logger, "PetUseCase", code, message, metadata, cause)
+	return fault.NewUseCase("PetUseCase", code, message, metadata, cause)
 }
 
 /******************************************************************************
@@ -46,8 +49,10 @@ func (u PetUseCase[T, U]) preparePetCreation(id uuid.UUID, name string, raceID u
 	return entities.Pet{ID: id, Name: name, Race: entities.Race{ID: raceID}}, nil
 }
 
-func (u PetUseCase[T, U]) Create(id uuid.UUID, name string, raceID uuid.UUID) (entities.Pet, fault.Fault) {
+func (u PetUseCase[T, U]) Create(id uuid.UUID, name string, raceID uuid.UUID) (pet entities.Pet, err fault.Fault) {
 	u.logger.Trace().Msg("Create")
+	span := trace.StartSpan("PetUseCase.Create")
+	defer func() { span.EndWith(err) }()
 	metadata := map[string]any{
 		"id": id,
 	}
@@ -59,32 +64,62 @@ func (u PetUseCase[T, U]) Create(id uuid.UUID, name string, raceID uuid.UUID) (e
 
 	p, err = u.Repository.Create(p)
 	if err == nil {
+		metrics.Add("PetCreated", 1)
 		return p, nil
 	}
-	switch err.Code() {
-	case "UNIQUE_VIOLATION":
+	if errors.Is(err, repositories.ErrPetNotUnique) {
 		return p, u.newError("PET_ID_NOT_UNIQUE", "Pet id not unique", metadata, err)
-	default:
-		return p, u.newError("PET_CREATION_FAILED", "Pet creation failed", metadata, err)
 	}
+	return p, u.newError("PET_CREATION_FAILED", "Pet creation failed", metadata, err)
 }
 
-func (u PetUseCase[T, U]) Get(id uuid.UUID) (entities.Pet, fault.Fault) {
+func (u PetUseCase[T, U]) Get(id uuid.UUID) (pet entities.Pet, err fault.Fault) {
 	u.logger.Trace().Msg("Get")
+	span := trace.StartSpan("PetUseCase.Get")
+	defer func() { span.EndWith(err) }()
+	return u.get(id, u.Repository.Get)
+}
+
+// GetForUpdate is Get, the pet being locked until the end of the request so that it can be checked before Update
+func (u PetUseCase[T, U]) GetForUpdate(id uuid.UUID) (pet entities.Pet, err fault.Fault) {
+	u.logger.Trace().Msg("GetForUpdate")
+	span := trace.StartSpan("PetUseCase.GetForUpdate")
+	defer func() { span.EndWith(err) }()
+	return u.get(id, u.Repository.GetForUpdate)
+}
+
+func (u PetUseCase[T, U]) get(id uuid.UUID, get func(uuid.UUID) (entities.Pet, fault.Fault)) (entities.Pet, fault.Fault) {
+	metadata := map[string]any{
+		"id": id,
+	}
+
+	p, err := get(id)
+	if err == nil {
+		return p, nil
+	}
+	if errors.Is(err, repositories.ErrPetNotFound) {
+		return p, u.newError("PET_NOT_FOUND", "Pet not found", metadata, err)
+	}
+	return p, u.newError("PET_GET_FAILED", "Cannot get this pet", metadata, err)
+}
+
+func (u PetUseCase[T, U]) Update(id uuid.UUID, name string, raceID uuid.UUID) (pet entities.Pet, err fault.Fault) {
+	u.logger.Trace().Msg("Update")
+	span := trace.StartSpan("PetUseCase.Update")
+	defer func() { span.EndWith(err) }()
 	metadata := map[string]any{
 		"id": id,
 	}
 
-	p, err := u.Repository.Get(id)
+	p, err := u.Repository.Update(entities.Pet{ID: id, Name: name, Race: entities.Race{ID: raceID}})
 	if err == nil {
+		metrics.Add("PetUpdated", 1)
 		return p, nil
 	}
-	switch err.Code() {
-	case "NOT_FOUND":
+	if errors.Is(err, repositories.ErrPetNotFound) {
 		return p, u.newError("PET_NOT_FOUND", "Pet not found", metadata, err)
-	default:
-		return p, u.newError("PET_GET_FAILED", "Cannot get this pet", metadata, err)
 	}
+	return p, u.newError("PET_UPDATE_FAILED", "Pet update failed", metadata, err)
 }
 
 /******************************************************************************
@@ -93,15 +128,15 @@ func (u PetUseCase[T, U]) Get(id uuid.UUID) (entities.Pet, fault.Fault) {
 
 // Setup logger and UUID generator
 func (u *PetUseCase[T, U]) OnSetup(_ context.Context, _ *T) fault.Fault {
-	ll := log.Logger.With().Str("usecase", "PetUseCases").Logger()
-	u.logger = &ll
+	u.logger = loggerframework.Child("usecase", "PetUseCases")
 	u.logger.Trace().Msg("OnSetup")
 	u.UUIDGenerator = &utils.GoogleUUIDGenerator{}
 	u.logger.Trace().Str("UUIDGenerator", "GoogleUUIDGenerator").Msg("UUIDGenerator is set")
 	return nil
 }
 
-func (u PetUseCase[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+func (u *PetUseCase[T, U]) OnBefore(_ context.Context, _ *T) fault.Fault {
+	u.logger = loggerframework.Child("usecase", "PetUseCases")
 	u.logger.Trace().Msg("OnBefore")
 	return nil
 }
@@ -203,6 +203,20 @@ CREATE TABLE public.race (
 
 ALTER TABLE public.race OWNER TO pguser;
 
+--
+-- Name: rate_limit; Type: TABLE; Schema: public; Owner: pguser
+--
+
+CREATE TABLE public.rate_limit (
+    key text NOT NULL,
+    tokens double precision NOT NULL,
+    allowed boolean NOT NULL,
+    updated_at timestamp with time zone NOT NULL
+);
+
+
+ALTER TABLE public.rate_limit OWNER TO pguser;
+
 --
 -- Name: test; Type: TABLE; Schema: public; Owner: pguser
 --
@@ -276,6 +290,14 @@ ALTER TABLE ONLY public.race
     ADD CONSTRAINT race_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: rate_limit rate_limit_pkey; Type: CONSTRAINT; Schema: public; Owner: pguser
+--
+
+ALTER TABLE ONLY public.rate_limit
+    ADD CONSTRAINT rate_limit_pkey PRIMARY KEY (key);
+
+
 --
 -- Name: pet fk_race; Type: FK CONSTRAINT; Schema: public; Owner: pguser
 --
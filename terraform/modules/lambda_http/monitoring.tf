@@ -14,3 +14,24 @@ fields @timestamp, log.sequence, awsRequestId, level, category, action, msg, @me
 | limit 1000
 EOF
 }
+
+// Aggregates the access log lines, see logger.APIGatewayClient for their schema
+resource "aws_cloudwatch_query_definition" "access" {
+  name = "${local.function_name}-access"
+
+  log_group_names = [
+    "${aws_cloudwatch_log_group.default.name}"
+  ]
+
+  query_string = <<EOF
+filter category = "access"
+| stats count(*) as requests,
+    avg(latencyMs) as avgLatencyMs,
+    pct(latencyMs, 95) as p95LatencyMs,
+    pct(latencyMs, 99) as p99LatencyMs,
+    sum(responseBytes) as responseBytes
+  by method, route, status, faultCode
+| sort requests desc
+| limit 1000
+EOF
+}